@@ -0,0 +1,207 @@
+package chip8
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// Default values used by the audio generators.
+const (
+	DefaultSampleRate = 44100
+	DefaultPitch      = 440.0
+	DefaultVolume     = 0.25
+	DefaultAttack     = 5 * time.Millisecond
+	DefaultRelease    = 5 * time.Millisecond
+)
+
+// BytesPerSample is the size, in bytes, of a single PCM sample produced
+// by the audio generators. All samples are signed 16-bit little endian,
+// single channel (mono).
+const BytesPerSample = 2
+
+// envelope is a simple linear attack/release envelope, used to avoid
+// 'clicks' when the sound is turned on or off.
+type envelope struct {
+	level float64
+}
+
+// step advances the envelope by a single sample and returns the
+// current level, in the range 0..1.
+func (e *envelope) step(gate bool, attack, release time.Duration, sampleRate int) float64 {
+	if gate {
+		if attack <= 0 {
+			e.level = 1
+		} else {
+			e.level += 1 / (attack.Seconds() * float64(sampleRate))
+		}
+	} else {
+		if release <= 0 {
+			e.level = 0
+		} else {
+			e.level -= 1 / (release.Seconds() * float64(sampleRate))
+		}
+	}
+
+	if e.level > 1 {
+		e.level = 1
+	} else if e.level < 0 {
+		e.level = 0
+	}
+
+	return e.level
+}
+
+// toSample converts a value in the range -1..1 to a PCM sample.
+func toSample(value float64) int16 {
+	if value > 1 {
+		value = 1
+	} else if value < -1 {
+		value = -1
+	}
+
+	return int16(value * 32767)
+}
+
+// readSamples fills p with as many whole samples as possible, using
+// next to generate each one.
+func readSamples(p []byte, next func() int16) (int, error) {
+	if len(p) < BytesPerSample {
+		return 0, io.ErrShortBuffer
+	}
+
+	n := len(p) - len(p)%BytesPerSample
+	for i := 0; i < n; i += BytesPerSample {
+		binary.LittleEndian.PutUint16(p[i:], uint16(next()))
+	}
+
+	return n, nil
+}
+
+// Beeper generates the classic CHIP-8 'beep': a square wave that is
+// played while the sound timer (ST) of the emulator is nonzero.
+//
+// The Beeper is an io.Reader of PCM samples (see BytesPerSample); every
+// sample read advances the audio time by 1/SampleRate seconds, so the
+// caller is responsible for reading the samples at the same pace the
+// emulator runs (for example, SampleRate/60 samples after each call to
+// Tick).
+type Beeper struct {
+	SampleRate int           // output sample rate, in Hz
+	Pitch      float64       // frequency of the square wave, in Hz
+	Volume     float64       // volume, in the range 0..1
+	Attack     time.Duration // time to go from silence to full volume
+	Release    time.Duration // time to go from full volume to silence
+
+	sys   System
+	env   envelope
+	phase float64
+}
+
+// NewBeeper creates a new Beeper for the given system (usually, an
+// Emulator), using the default sample rate, pitch, volume and envelope.
+func NewBeeper(s System) *Beeper {
+	return &Beeper{
+		SampleRate: DefaultSampleRate,
+		Pitch:      DefaultPitch,
+		Volume:     DefaultVolume,
+		Attack:     DefaultAttack,
+		Release:    DefaultRelease,
+		sys:        s,
+	}
+}
+
+// Read fills p with PCM samples. It never returns io.EOF; when the
+// sound is off, the samples are silent.
+func (b *Beeper) Read(p []byte) (int, error) {
+	return readSamples(p, b.next)
+}
+
+func (b *Beeper) next() int16 {
+	level := b.env.step(b.sys.SoundOn(), b.Attack, b.Release, b.SampleRate)
+	if level == 0 {
+		b.phase = 0
+		return 0
+	}
+
+	value := 1.0
+	if b.phase >= 0.5 {
+		value = -1
+	}
+
+	b.phase += b.Pitch / float64(b.SampleRate)
+	for b.phase >= 1 {
+		b.phase--
+	}
+
+	return toSample(value * level * b.Volume)
+}
+
+// DefaultPatternPitch is the initial value of the XO-CHIP pitch register,
+// which plays the audio pattern at 4000 bits per second.
+const DefaultPatternPitch = 64
+
+// PatternRate returns the playback rate, in bits per second, of the
+// XO-CHIP audio pattern for the given value of the pitch register.
+func PatternRate(pitch byte) float64 {
+	return 4000 * math.Pow(2, (float64(pitch)-64)/48)
+}
+
+// PatternPlayer plays the 128-bit XO-CHIP audio pattern buffer while the
+// sound timer (ST) of the emulator is nonzero. Each bit of the pattern is
+// a 'high' or 'low' level of the output, played at the rate given by the
+// pitch register (see PatternRate) and resampled to SampleRate.
+//
+// Like the Beeper, the PatternPlayer is an io.Reader of PCM samples.
+type PatternPlayer struct {
+	SampleRate int           // output sample rate, in Hz
+	Volume     float64       // volume, in the range 0..1
+	Attack     time.Duration // time to go from silence to full volume
+	Release    time.Duration // time to go from full volume to silence
+
+	emu      *Emulator
+	env      envelope
+	position float64 // current position on the pattern, in bits
+}
+
+// NewPatternPlayer creates a new PatternPlayer for the given emulator,
+// using the default sample rate, volume and envelope.
+func NewPatternPlayer(c *Emulator) *PatternPlayer {
+	return &PatternPlayer{
+		SampleRate: DefaultSampleRate,
+		Volume:     DefaultVolume,
+		Attack:     DefaultAttack,
+		Release:    DefaultRelease,
+		emu:        c,
+	}
+}
+
+// Read fills b with PCM samples. It never returns io.EOF; when the
+// sound timer is zero, the samples are silent.
+func (p *PatternPlayer) Read(b []byte) (int, error) {
+	return readSamples(b, p.next)
+}
+
+func (p *PatternPlayer) next() int16 {
+	patternBits := float64(len(p.emu.Pattern) * 8)
+
+	level := p.env.step(p.emu.ST > 0, p.Attack, p.Release, p.SampleRate)
+	if level == 0 {
+		p.position = 0
+		return 0
+	}
+
+	bit := int(p.position)
+	value := -1.0
+	if p.emu.Pattern[bit/8]&(0x80>>(bit%8)) != 0 {
+		value = 1
+	}
+
+	p.position += PatternRate(p.emu.Pitch) / float64(p.SampleRate)
+	for p.position >= patternBits {
+		p.position -= patternBits
+	}
+
+	return toSample(value * level * p.Volume)
+}
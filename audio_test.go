@@ -0,0 +1,258 @@
+package chip8_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ibraimgm/chip8"
+)
+
+var update = flag.Bool("update", false, "update the golden files on testdata")
+
+func readSamples(t *testing.T, r interface{ Read([]byte) (int, error) }, count int) []int16 {
+	t.Helper()
+
+	buffer := make([]byte, count*chip8.BytesPerSample)
+	n, err := r.Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != len(buffer) {
+		t.Fatalf("expected to read %d bytes, but read %d", len(buffer), n)
+	}
+
+	samples := make([]int16, count)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(buffer[i*chip8.BytesPerSample:]))
+	}
+
+	return samples
+}
+
+func TestBeeperSilence(t *testing.T) {
+	var c chip8.Emulator
+	b := chip8.NewBeeper(&c)
+
+	for i, sample := range readSamples(t, b, 1000) {
+		if sample != 0 {
+			t.Fatalf("expected sample %d to be silent, but was %d", i, sample)
+		}
+	}
+}
+
+func TestBeeperSquareWave(t *testing.T) {
+	var c chip8.Emulator
+	c.ST = 10
+
+	b := chip8.NewBeeper(&c)
+	b.SampleRate = 8000
+	b.Pitch = 1000
+	b.Volume = 1
+	b.Attack = 0
+
+	// 8 samples per period: 4 high, 4 low
+	samples := readSamples(t, b, 16)
+	for i, sample := range samples {
+		expected := int16(32767)
+		if i%8 >= 4 {
+			expected = -32767
+		}
+
+		if sample != expected {
+			t.Fatalf("expected sample %d to be %d, but was %d", i, expected, sample)
+		}
+	}
+}
+
+func TestBeeperEnvelope(t *testing.T) {
+	var c chip8.Emulator
+	c.ST = 10
+
+	b := chip8.NewBeeper(&c)
+	b.SampleRate = 1000
+	b.Pitch = 1
+	b.Volume = 1
+	b.Attack = 100 * time.Millisecond
+	b.Release = 100 * time.Millisecond
+
+	// attack: every sample should be louder than the previous one
+	samples := readSamples(t, b, 100)
+	for i := 1; i < len(samples); i++ {
+		if samples[i] <= samples[i-1] {
+			t.Fatalf("expected sample %d (%d) to be louder than %d", i, samples[i], samples[i-1])
+		}
+	}
+
+	if sample := readSamples(t, b, 1)[0]; sample != 32767 {
+		t.Fatalf("expected full volume after attack, but found %d", sample)
+	}
+
+	// release: should fade to silence
+	c.ST = 0
+	samples = readSamples(t, b, 100)
+	for i := 1; i < len(samples); i++ {
+		if samples[i] >= samples[i-1] {
+			t.Fatalf("expected sample %d (%d) to be quieter than %d", i, samples[i], samples[i-1])
+		}
+	}
+
+	if samples[len(samples)-1] != 0 {
+		t.Fatalf("expected silence after release, but found %d", samples[len(samples)-1])
+	}
+}
+
+func TestBeeperShortBuffer(t *testing.T) {
+	var c chip8.Emulator
+	b := chip8.NewBeeper(&c)
+
+	if _, err := b.Read(make([]byte, 1)); err == nil {
+		t.Fatal("expected error when reading less than a sample")
+	}
+
+	n, err := b.Read(make([]byte, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 4 {
+		t.Fatalf("expected to read only whole samples (4 bytes), but read %d", n)
+	}
+}
+
+func TestPatternRate(t *testing.T) {
+	tests := []struct {
+		pitch    byte
+		expected float64
+	}{
+		{pitch: 64, expected: 4000},
+		{pitch: 112, expected: 8000},
+		{pitch: 16, expected: 2000},
+		{pitch: 160, expected: 16000},
+	}
+
+	for _, test := range tests {
+		if actual := chip8.PatternRate(test.pitch); actual != test.expected {
+			t.Fatalf("expected rate for pitch %d to be %v, but was %v", test.pitch, test.expected, actual)
+		}
+	}
+}
+
+func TestPatternPlayerSilence(t *testing.T) {
+	var c chip8.Emulator
+	c.Pattern[0] = 0xFF
+	c.Pitch = chip8.DefaultPatternPitch
+
+	for i, sample := range readSamples(t, chip8.NewPatternPlayer(&c), 1000) {
+		if sample != 0 {
+			t.Fatalf("expected sample %d to be silent, but was %d", i, sample)
+		}
+	}
+}
+
+func TestPatternPlayerSamples(t *testing.T) {
+	pattern := [16]byte{0x00, 0xFF, 0x0F}
+	c := chip8.Emulator{Pattern: pattern, Pitch: 64, ST: 1}
+
+	p := chip8.NewPatternPlayer(&c)
+	p.SampleRate = 8000
+	p.Volume = 1
+	p.Attack = 0
+	p.Release = 0
+
+	// at 4000 bits per second and 8000 samples per second, each bit of the
+	// pattern lasts two samples: 0x00 is 16 low samples, 0xFF is 16 high
+	// samples and 0x0F is 8 low samples followed by 8 high ones
+	var expected []int16
+	for _, run := range []struct {
+		count int
+		value int16
+	}{{16, -32767}, {16, 32767}, {8, -32767}, {8, 32767}} {
+		for i := 0; i < run.count; i++ {
+			expected = append(expected, run.value)
+		}
+	}
+
+	samples := readSamples(t, p, len(expected))
+	for i := range expected {
+		if samples[i] != expected[i] {
+			t.Fatalf("expected sample %d to be %d, but was %d", i, expected[i], samples[i])
+		}
+	}
+
+	// the golden file of the same pitch starts with the same bytes
+	golden, err := ioutil.ReadFile(filepath.Join("testdata", "pattern-4000-8000.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const headerSize = 44
+	for i := range expected {
+		if sample := int16(binary.LittleEndian.Uint16(golden[headerSize+i*chip8.BytesPerSample:])); sample != expected[i] {
+			t.Fatalf("expected golden sample %d to be %d, but was %d", i, expected[i], sample)
+		}
+	}
+}
+
+func TestPatternPlayerGolden(t *testing.T) {
+	pattern := [16]byte{
+		0x00, 0xFF, 0x0F, 0xF0, 0x33, 0xCC, 0x55, 0xAA,
+		0x01, 0x80, 0x7E, 0x81, 0xFF, 0x00, 0xF0, 0x0F,
+	}
+
+	tests := []struct {
+		name       string
+		pitch      byte
+		sampleRate int
+	}{
+		{name: "pattern-4000-8000", pitch: 64, sampleRate: 8000},
+		{name: "pattern-8000-8000", pitch: 112, sampleRate: 8000},
+		{name: "pattern-2000-8000", pitch: 16, sampleRate: 8000},
+		{name: "pattern-4000-22050", pitch: 64, sampleRate: 22050},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			c := chip8.Emulator{Pattern: pattern, Pitch: test.pitch, ST: 1}
+
+			p := chip8.NewPatternPlayer(&c)
+			p.SampleRate = test.sampleRate
+			p.Volume = 1
+			p.Attack = 0
+			p.Release = 0
+
+			pcm := make([]byte, 512*chip8.BytesPerSample)
+			if _, err := p.Read(pcm); err != nil {
+				t.Fatal(err)
+			}
+
+			var wav bytes.Buffer
+			if err := chip8.WriteWAV(&wav, test.sampleRate, pcm); err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", test.name+".wav")
+			if *update {
+				if err := ioutil.WriteFile(golden, wav.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			expected, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(wav.Bytes(), expected) {
+				t.Fatalf("generated audio does not match golden file '%s'", golden)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package chip8
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrDeviceMap is returned when mapping a device on an invalid memory
+// range, or over another device.
+var ErrDeviceMap = errors.New("invalid device mapping")
+
+// Bus reads and writes the bytes of an address space.
+//
+// The Emulator is the bus seen by the programs: every access of the
+// instructions to memory goes through its Read and Write methods. The
+// devices mapped on it (see Map) are buses too, with addresses relative
+// to the start of the mapping.
+type Bus interface {
+	Read(addr int) byte
+	Write(addr int, value byte)
+}
+
+// mapping is a device mapped on the memory of the emulator.
+type mapping struct {
+	Region
+	device Bus
+}
+
+// Map maps a device on the memory range from start (inclusive) to end
+// (exclusive), which may go past the addressable memory of the machine
+// (up to 64K). The reads and writes of the program on the range go to
+// the device instead of Memory, and are never write protected; the
+// instructions executed from the range are decoded every time, since the
+// device may change them at any moment.
+//
+// The devices are kept when the emulator is reset.
+func (c *Emulator) Map(name string, start, end int, device Bus) error {
+	if start < 0 || end > 0x10000 || start >= end || device == nil {
+		return ErrDeviceMap
+	}
+
+	for _, m := range c.devices {
+		if start < m.End && end > m.Start {
+			return ErrDeviceMap
+		}
+	}
+
+	c.devices = append(c.devices, mapping{Region: Region{Name: name, Start: start, End: end}, device: device})
+	sort.Slice(c.devices, func(i, j int) bool { return c.devices[i].Start < c.devices[j].Start })
+
+	c.InvalidateCache(start, end-start)
+	return nil
+}
+
+// Unmap removes the device mapped at the start address, if any.
+func (c *Emulator) Unmap(start int) {
+	for i, m := range c.devices {
+		if m.Start == start {
+			c.devices = append(c.devices[:i], c.devices[i+1:]...)
+			c.InvalidateCache(m.Start, m.End-m.Start)
+			return
+		}
+	}
+}
+
+// Devices returns the regions where devices are mapped.
+func (c *Emulator) Devices() []Region {
+	regions := make([]Region, len(c.devices))
+	for i, m := range c.devices {
+		regions[i] = m.Region
+	}
+
+	return regions
+}
+
+// Read reads a byte of the address space of the program: from a mapped
+// device or from Memory, growing it if needed. Addresses outside of both
+// read as zero.
+func (c *Emulator) Read(addr int) byte {
+	if m := c.deviceAt(addr); m != nil {
+		return m.device.Read(addr - m.Start)
+	}
+
+	if addr < 0 || !c.grow(addr+1) {
+		return 0
+	}
+
+	return c.Memory[addr]
+}
+
+// Write writes a byte of the address space of the program, to a mapped
+// device or to Memory, growing it if needed; unlike the instructions, it
+// ignores the memory protection. Writes outside of both are ignored.
+func (c *Emulator) Write(addr int, value byte) {
+	if m := c.deviceAt(addr); m != nil {
+		m.device.Write(addr-m.Start, value)
+		return
+	}
+
+	if addr < 0 || !c.grow(addr+1) {
+		return
+	}
+
+	c.Memory[addr] = value
+	c.InvalidateCache(addr, 1)
+}
+
+// deviceAt returns the device mapped at the address, or nil.
+func (c *Emulator) deviceAt(addr int) *mapping {
+	for i := range c.devices {
+		if c.devices[i].Contains(addr) {
+			return &c.devices[i]
+		}
+	}
+
+	return nil
+}
+
+// mapped reports whether any address of the range is mapped to a device.
+func (c *Emulator) mapped(addr, size int) bool {
+	for _, m := range c.devices {
+		if addr < m.End && addr+size > m.Start {
+			return true
+		}
+	}
+
+	return false
+}
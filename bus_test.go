@@ -0,0 +1,208 @@
+package chip8_test
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+// console is a debug console device, which records the bytes written to it.
+type console struct {
+	bytes.Buffer
+}
+
+func (d *console) Read(addr int) byte { return 0 }
+
+func (d *console) Write(addr int, value byte) { d.WriteByte(value) }
+
+// bank is a switchable ROM bank device; writes select the bank.
+type bank struct {
+	banks   [][]byte
+	current int
+}
+
+func (d *bank) Read(addr int) byte { return d.banks[d.current][addr] }
+
+func (d *bank) Write(addr int, value byte) { d.current = int(value) % len(d.banks) }
+
+func TestBusConsole(t *testing.T) {
+	var out console
+
+	c := chip8.NewEmulator(chip8.COSMACVIP)
+	if err := c.Map("console", 0x800, 0x801, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	// V0 = 'H', V1 = 'i', I = 0x800, then LD [I], V0 twice
+	rom := []byte{0x60, 'H', 0x61, 'i', 0xA8, 0x00, 0xF0, 0x55, 0x80, 0x10, 0xA8, 0x00, 0xF0, 0x55}
+	if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Execute(7); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "Hi" {
+		t.Fatalf("expected the console to print %q, but got %q", "Hi", out.String())
+	}
+
+	if c.Memory[0x800] != 0 {
+		t.Fatalf("expected the memory to be unchanged, but got 0x%02X", c.Memory[0x800])
+	}
+}
+
+func TestBusBank(t *testing.T) {
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			rom := &bank{banks: [][]byte{
+				{0x62, 0x01, 0x13, 0x02}, // V2 = 1; JP 0x302
+				{0x62, 0x02, 0x13, 0x02}, // V2 = 2; JP 0x302
+			}}
+
+			c := chip8.NewEmulator(chip8.COSMACVIP)
+			c.Engine = engine.engine
+			if err := c.Map("bank", 0x300, 0x304, rom); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := c.LoadROM(bytes.NewReader([]byte{0x13, 0x00})); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := c.Execute(3); err != nil {
+				t.Fatal(err)
+			}
+
+			if c.V[2] != 1 || c.PC != 0x302 {
+				t.Fatalf("expected V2 = 1 at 0x302, but got V2 = %d at 0x%03X", c.V[2], c.PC)
+			}
+
+			// switch banks and run the bank again
+			c.Write(0x300, 1)
+			c.PC = 0x300
+			if _, err := c.Execute(1); err != nil {
+				t.Fatal(err)
+			}
+
+			if c.V[2] != 2 {
+				t.Fatalf("expected V2 = 2 after switching banks, but got %d", c.V[2])
+			}
+
+			if dis := c.Disassemble(0x300); dis != "LD V2, 0x02" {
+				t.Fatalf("expected the disassembly of the bank, but got %q", dis)
+			}
+		})
+	}
+}
+
+func TestBusMap(t *testing.T) {
+	var out console
+
+	tests := []struct {
+		name       string
+		start, end int
+		device     chip8.Bus
+		err        error
+	}{
+		{"Valid", 0x800, 0x810, &out, nil},
+		{"PastMemory", 0xF000, 0x10000, &out, nil},
+		{"Overlap", 0x80F, 0x820, &out, chip8.ErrDeviceMap},
+		{"Inside", 0x804, 0x808, &out, chip8.ErrDeviceMap},
+		{"Empty", 0x900, 0x900, &out, chip8.ErrDeviceMap},
+		{"Negative", -1, 0x10, &out, chip8.ErrDeviceMap},
+		{"TooLarge", 0xFFFF, 0x10001, &out, chip8.ErrDeviceMap},
+		{"NilDevice", 0xA00, 0xA10, nil, chip8.ErrDeviceMap},
+	}
+
+	c := chip8.NewEmulator(chip8.COSMACVIP)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := c.Map(test.name, test.start, test.end, test.device); !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, but got %v", test.err, err)
+			}
+		})
+	}
+
+	devices := c.Devices()
+	if len(devices) != 2 || devices[0].Name != "Valid" || devices[1].Name != "PastMemory" {
+		t.Fatalf("expected two devices, but got %v", devices)
+	}
+
+	c.Reset()
+	if len(c.Devices()) != 2 {
+		t.Fatal("expected the devices to be kept on reset")
+	}
+
+	c.Unmap(0x800)
+	if devices := c.Devices(); len(devices) != 1 || devices[0].Start != 0xF000 {
+		t.Fatalf("expected one device after unmap, but got %v", devices)
+	}
+
+	c.Write(0x800, 0xAB)
+	if c.Read(0x800) != 0xAB || c.Memory[0x800] != 0xAB {
+		t.Fatal("expected the unmapped address to be on memory")
+	}
+}
+
+func TestBusProtection(t *testing.T) {
+	var out console
+
+	// the interpreter area is reserved, but the device is writable
+	c := chip8.NewEmulator(chip8.COSMACVIP)
+	if err := c.Map("console", 0x100, 0x101, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.LoadROM(bytes.NewReader([]byte{0x60, '!', 0xA1, 0x00, 0xF0, 0x55})); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Execute(3); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "!" {
+		t.Fatalf("expected the console to print %q, but got %q", "!", out.String())
+	}
+}
+
+func TestBusMegaChip(t *testing.T) {
+	var out console
+
+	c := chip8.NewEmulator(chip8.MEGACHIP)
+	if err := c.Map("console", 0x800, 0x801, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	// MEGAON, I = 0x020000, LDPAL 1
+	rom := []byte{0x00, 0x11, 0x01, 0x02, 0x00, 0x00, 0x02, 0x01}
+	if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the addresses past the memory allocated by Reset are still valid
+	for i, value := range []byte{0xFF, 0x11, 0x22, 0x33} {
+		c.Write(0x020000+i, value)
+		if c.Read(0x020000+i) != value {
+			t.Fatalf("expected 0x%02X at 0x%06X, but got 0x%02X", value, 0x020000+i, c.Read(0x020000+i))
+		}
+	}
+
+	if _, err := c.Execute(3); err != nil {
+		t.Fatal(err)
+	}
+
+	if p := c.Mega.Palette[1]; p != (color.RGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xFF}) {
+		t.Fatalf("expected the color written through the bus, but got %v", p)
+	}
+
+	c.Write(0x1000000, 0xAB)
+	if value := c.Read(0x1000000); value != 0 {
+		t.Fatalf("expected the address past the memory to read as zero, but got 0x%02X", value)
+	}
+}
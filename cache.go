@@ -0,0 +1,115 @@
+package chip8
+
+// Engine selects how the emulator executes the program.
+type Engine byte
+
+// Available execution engines.
+const (
+	// EngineInterpreter decodes every instruction from memory before
+	// executing it. This is the default engine.
+	EngineInterpreter Engine = iota
+
+	// EngineCache decodes each memory address once and keeps the decoded
+	// instruction until the memory is written by the program. Frontends
+	// and debuggers that change Memory directly must call InvalidateCache.
+	EngineCache
+
+	// EngineRecompiler translates each basic block of the program to a
+	// sequence of Go functions (see recompiler.go).
+	EngineRecompiler
+)
+
+// number of memory addresses on each page of the instruction cache
+const cachePageSize = 256
+
+// cachedInstruction is an instruction decoded from memory.
+type cachedInstruction struct {
+	ins   *Instruction
+	a, b  byte
+	valid bool
+}
+
+// cachePage holds the decoded instructions of cachePageSize addresses.
+// The pages are allocated on the first execution of one of their
+// addresses, so machines with large memories do not waste space.
+type cachePage [cachePageSize]cachedInstruction
+
+// fetch returns the decoded instruction pointed by PC. The instruction
+// is nil if the opcode is unknown. Instructions on mapped devices are
+// never cached. It returns ErrInvalidAddress if the instruction does not
+// fit in memory.
+func (c *Emulator) fetch(d *decoder) (*cachedInstruction, error) {
+	pc := int(c.PC)
+
+	if c.devices != nil && c.mapped(pc, 2) {
+		entry := &d.current
+		entry.a, entry.b = c.Read(pc), c.Read(pc+1)
+		entry.ins = d.lookup(uint16(entry.a)<<8 | uint16(entry.b))
+		return entry, nil
+	}
+
+	if !c.grow(pc + 2) {
+		return nil, ErrInvalidAddress
+	}
+
+	if c.Engine == EngineInterpreter {
+		entry := &d.current
+		entry.a, entry.b = c.Memory[pc], c.Memory[pc+1]
+		entry.ins = d.lookup(uint16(entry.a)<<8 | uint16(entry.b))
+		return entry, nil
+	}
+
+	if pc/cachePageSize >= len(d.pages) {
+		d.pages = append(d.pages, make([]*cachePage, len(c.Memory)/cachePageSize+1-len(d.pages))...)
+	}
+
+	page := d.pages[pc/cachePageSize]
+	if page == nil {
+		page = &cachePage{}
+		d.pages[pc/cachePageSize] = page
+	}
+
+	entry := &page[pc%cachePageSize]
+	if !entry.valid {
+		entry.a, entry.b = c.Memory[pc], c.Memory[pc+1]
+		entry.ins = d.lookup(uint16(entry.a)<<8 | uint16(entry.b))
+		entry.valid = true
+	}
+
+	return entry, nil
+}
+
+// InvalidateCache discards the decoded instructions of the given memory
+// range. The emulator does this on its own when the program writes to
+// memory; frontends and debuggers that change Memory directly, after the
+// program started running, must call it to see the changes executed.
+func (c *Emulator) InvalidateCache(addr, size int) {
+	if c.decoder == nil {
+		return
+	}
+
+	c.invalidateBlocks(addr, size)
+
+	// instructions starting on the byte before addr include its first byte
+	for i := addr - 1; i < addr+size; i++ {
+		if i < 0 {
+			continue
+		}
+
+		if i/cachePageSize >= len(c.decoder.pages) {
+			break
+		}
+
+		if page := c.decoder.pages[i/cachePageSize]; page != nil {
+			page[i%cachePageSize].valid = false
+		}
+	}
+}
+
+// flushCache discards all decoded instructions and compiled blocks.
+func (c *Emulator) flushCache() {
+	if c.decoder != nil {
+		c.decoder.pages = nil
+		c.decoder.blocks = blockCache{generation: c.decoder.blocks.generation + 1}
+	}
+}
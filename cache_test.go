@@ -0,0 +1,238 @@
+package chip8_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ibraimgm/chip8"
+)
+
+var engines = []struct {
+	name   string
+	engine chip8.Engine
+}{
+	{"cache", chip8.EngineCache},
+	{"interpreter", chip8.EngineInterpreter},
+	{"recompiler", chip8.EngineRecompiler},
+}
+
+func TestSelfModifyingCode(t *testing.T) {
+	rom := []byte{
+		0x61, 0x63, // V1 = 0x63
+		0x62, 0x2A, // V2 = 0x2A
+		0x51, 0x22, // LD [I], V1-V2 (writes 'V3 = 0x2A' on the next instruction)
+		0x63, 0x11, // V3 = 0x11
+	}
+
+	for _, test := range engines {
+		t.Run(test.name, func(t *testing.T) {
+			c := newExtendedEmulator(t, rom, chip8.CHIP8E)
+			c.Engine = test.engine
+
+			// run the last instruction once, so it is decoded
+			c.PC = chip8.AddrStart + 6
+			if _, err := c.Execute(1); err != nil {
+				t.Fatal(err)
+			}
+
+			if c.V[3] != 0x11 {
+				t.Fatalf("expected V3 to be 0x11, but was 0x%02X", c.V[3])
+			}
+
+			c.PC = chip8.AddrStart
+			c.I = chip8.AddrStart + 6
+			if _, err := c.Execute(4); err != nil {
+				t.Fatal(err)
+			}
+
+			if c.V[3] != 0x2A {
+				t.Fatalf("expected modified instruction to set V3 to 0x2A, but was 0x%02X", c.V[3])
+			}
+		})
+	}
+}
+
+func TestInvalidateCache(t *testing.T) {
+	c := chip8.NewEmulator(chip8.COSMACVIP)
+	c.Engine = chip8.EngineCache
+	if err := c.LoadROM(bytes.NewReader([]byte{0x63, 0x11})); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Execute(1); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Memory[chip8.AddrStart+1] = 0x22
+	c.InvalidateCache(chip8.AddrStart+1, 1)
+
+	c.PC = chip8.AddrStart
+	if _, err := c.Execute(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.V[3] != 0x22 {
+		t.Fatalf("expected V3 to be 0x22, but was 0x%02X", c.V[3])
+	}
+}
+
+func TestLoadROMFlushesCache(t *testing.T) {
+	c := chip8.NewEmulator(chip8.COSMACVIP)
+	c.Engine = chip8.EngineCache
+
+	for _, value := range []byte{0x11, 0x22} {
+		if err := c.LoadROM(bytes.NewReader([]byte{0x63, value})); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := c.Execute(1); err != nil {
+			t.Fatal(err)
+		}
+
+		if c.V[3] != value {
+			t.Fatalf("expected V3 to be 0x%02X, but was 0x%02X", value, c.V[3])
+		}
+	}
+}
+
+func TestDefaultEngineReadsMemory(t *testing.T) {
+	c := chip8.NewEmulator(chip8.COSMACVIP)
+	if err := c.LoadROM(bytes.NewReader([]byte{0x63, 0x11})); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Execute(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// without InvalidateCache
+	c.Memory[chip8.AddrStart+1] = 0x22
+	c.PC = chip8.AddrStart
+	if _, err := c.Execute(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.V[3] != 0x22 {
+		t.Fatalf("expected V3 to be 0x22, but was 0x%02X", c.V[3])
+	}
+}
+
+func TestExecuteEndOfMemory(t *testing.T) {
+	rom := []byte{0x1F, 0xFE} // JP 0xFFE
+
+	for _, test := range engines {
+		t.Run(test.name, func(t *testing.T) {
+			c := chip8.NewEmulator(chip8.COSMACVIP)
+			c.Engine = test.engine
+			c.Unprotected = true
+			if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+				t.Fatal(err)
+			}
+
+			c.Memory[0xFFE], c.Memory[0xFFF] = 0x60, 0x01 // V0 = 0x01
+
+			executed, err := c.Execute(3)
+
+			var execErr *chip8.ExecError
+			if !errors.As(err, &execErr) || !errors.Is(err, chip8.ErrInvalidAddress) {
+				t.Fatalf("expected an ExecError with ErrInvalidAddress, but got %v", err)
+			}
+
+			if executed != 2 || execErr.PC != 0x1000 || c.V[0] != 0x01 {
+				t.Fatalf("expected to fail at 0x1000 after 2 instructions, but failed at 0x%03X after %d (V0=0x%02X)", execErr.PC, executed, c.V[0])
+			}
+		})
+	}
+}
+
+// benchROM fills the program memory with instructions that never stop
+// the emulator.
+func benchROM() []byte {
+	return repeatOps(
+		[]byte{0x61, 0x2A}, // V1 = 0x2A
+		[]byte{0x82, 0x10}, // V2 = V1
+		[]byte{0xF2, 0x15}, // DT = V2
+		[]byte{0xF3, 0x07}, // V3 = DT
+		[]byte{0xE3, 0x9E}, // SKP V3 (no key is pressed)
+	)
+}
+
+// straightROM is like benchROM, but without any branches.
+func straightROM() []byte {
+	return repeatOps(
+		[]byte{0x61, 0x2A}, // V1 = 0x2A
+		[]byte{0x82, 0x10}, // V2 = V1
+		[]byte{0xF2, 0x15}, // DT = V2
+		[]byte{0xF3, 0x07}, // V3 = DT
+	)
+}
+
+// repeatOps fills the program memory with the given instructions.
+func repeatOps(ops ...[]byte) []byte {
+	rom := make([]byte, 0, 0x1000-chip8.AddrStart)
+	for i := 0; len(rom) < cap(rom); i++ {
+		rom = append(rom, ops[i%len(ops)]...)
+	}
+
+	return rom
+}
+
+func BenchmarkExecute(b *testing.B) {
+	benchmarkEngines(b, benchROM())
+}
+
+func BenchmarkExecuteStraight(b *testing.B) {
+	benchmarkEngines(b, straightROM())
+}
+
+func benchmarkEngines(b *testing.B, rom []byte) {
+	for _, test := range engines {
+		b.Run(test.name, func(b *testing.B) {
+			c := chip8.NewEmulator(chip8.COSMACVIP)
+			c.Engine = test.engine
+			if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+				b.Fatal(err)
+			}
+
+			batch := len(rom) / 2
+
+			b.ResetTimer()
+			start := time.Now()
+
+			for executed := 0; executed < b.N; executed += batch {
+				c.PC = chip8.AddrStart
+				if _, err := c.Execute(min(batch, b.N-executed)); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "instr/s")
+		})
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// make sure the benchmark ROM is valid on every engine
+func TestBenchROM(t *testing.T) {
+	for _, test := range engines {
+		c := chip8.NewEmulator(chip8.COSMACVIP)
+		c.Engine = test.engine
+		if err := c.LoadROM(bytes.NewReader(benchROM())); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := c.Execute(len(benchROM()) / 2); err != nil {
+			t.Fatal(fmt.Errorf("%s: %w", test.name, err))
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"strings"
+)
+
+// ErrCartridge is returned when reading a GIF without a valid Octo
+// cartridge payload.
+var ErrCartridge = errors.New("invalid Octo cartridge")
+
+// Size of the cartridges written by WriteCartridge, in pixels. The
+// height grows as needed to hold the payload.
+const (
+	cartridgeWidth  = 128
+	cartridgeHeight = 64
+)
+
+// OctoOptions are the options of an Octo program, as stored in its
+// cartridges.
+type OctoOptions struct {
+	Tickrate        int    `json:"tickrate,omitempty"`
+	FillColor       string `json:"fillColor,omitempty"`
+	FillColor2      string `json:"fillColor2,omitempty"`
+	BlendColor      string `json:"blendColor,omitempty"`
+	BackgroundColor string `json:"backgroundColor,omitempty"`
+	BuzzColor       string `json:"buzzColor,omitempty"`
+	QuietColor      string `json:"quietColor,omitempty"`
+	ShiftQuirks     bool   `json:"shiftQuirks"`
+	LoadStoreQuirks bool   `json:"loadStoreQuirks"`
+	ClipQuirks      bool   `json:"clipQuirks"`
+	JumpQuirks      bool   `json:"jumpQuirks"`
+	LogicQuirks     bool   `json:"logicQuirks"`
+	VBlankQuirks    bool   `json:"vBlankQuirks"`
+	MaxSize         int    `json:"maxSize,omitempty"`
+	ScreenRotation  int    `json:"screenRotation"`
+	FontStyle       string `json:"fontStyle,omitempty"`
+	TouchInputMode  string `json:"touchInputMode,omitempty"`
+}
+
+// Cartridge is an Octo cartridge: a GIF image with a program and its
+// options hidden on its pixels.
+//
+// The payload is a JSON object with the options and the program, stored
+// after its length (4 bytes, big endian) on the pixels of the frames, in
+// order: each byte is split on the lowest 2 bits of the color indexes of
+// 4 pixels, the highest bits first. The palette repeats each color of
+// the label 4 times, so the payload does not change the picture.
+type Cartridge struct {
+	Options OctoOptions
+	Source  string // Octo source of the program
+	ROM     []byte // the compiled program
+}
+
+// the payload of the cartridges
+type cartridgePayload struct {
+	Options OctoOptions `json:"options"`
+	Program string      `json:"program"`
+}
+
+// ReadCartridge reads an Octo cartridge from a GIF image, compiling its
+// program (see CompileOcto). If the program does not compile, it returns
+// the cartridge with the source and the options, but without the ROM,
+// along with the ErrOctoSource error.
+func ReadCartridge(r io.Reader) (*Cartridge, error) {
+	anim, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCartridge, err)
+	}
+
+	// the 2 bit pieces of the payload, in order
+	var pieces []byte
+	for _, frame := range anim.Image {
+		b := frame.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				pieces = append(pieces, frame.ColorIndexAt(x, y)&0x03)
+			}
+		}
+	}
+
+	data := make([]byte, len(pieces)/4)
+	for i := range data {
+		p := pieces[i*4:]
+		data[i] = p[0]<<6 | p[1]<<4 | p[2]<<2 | p[3]
+	}
+
+	if len(data) < 4 {
+		return nil, ErrCartridge
+	}
+
+	size := binary.BigEndian.Uint32(data)
+	if uint64(size) > uint64(len(data)-4) {
+		return nil, ErrCartridge
+	}
+
+	var payload cartridgePayload
+	if err := json.Unmarshal(data[4:4+size], &payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCartridge, err)
+	}
+
+	cart := &Cartridge{Options: payload.Options, Source: payload.Program}
+	if cart.ROM, err = CompileOcto(payload.Program); err != nil {
+		return cart, err
+	}
+
+	return cart, nil
+}
+
+// WriteCartridge writes the ROM of the cartridge, and its options, as an
+// Octo cartridge. The program is written as Octo source of byte
+// literals, so Octo assembles it back to the same ROM.
+func WriteCartridge(w io.Writer, cart *Cartridge) error {
+	payload, err := json.Marshal(cartridgePayload{Options: cart.Options, Program: disassembleBytes(cart.ROM)})
+	if err != nil {
+		return err
+	}
+
+	data := make([]byte, 4, 4+len(payload))
+	binary.BigEndian.PutUint32(data, uint32(len(payload)))
+	data = append(data, payload...)
+
+	height := cartridgeHeight
+	if rows := (len(data)*4 + cartridgeWidth - 1) / cartridgeWidth; rows > height {
+		height = rows
+	}
+
+	// each color of the label is repeated for the 4 values of the payload
+	label := []color.Color{parseColorOr(cart.Options.BackgroundColor, DefaultPalette.Background), parseColorOr(cart.Options.FillColor, DefaultPalette.Foreground)}
+	palette := make(color.Palette, 0, len(label)*4)
+	for _, c := range label {
+		palette = append(palette, c, c, c, c)
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, cartridgeWidth, height), palette)
+	for y := 0; y < height; y++ {
+		for x := 0; x < cartridgeWidth; x++ {
+			i := y*cartridgeWidth + x
+
+			var base byte
+			if x == 0 || y == 0 || x == cartridgeWidth-1 || y == height-1 {
+				base = 4 // border
+			}
+
+			var piece byte
+			if i/4 < len(data) {
+				piece = data[i/4] >> uint(6-i%4*2) & 0x03
+			}
+
+			img.SetColorIndex(x, y, base|piece)
+		}
+	}
+
+	return gif.Encode(w, img, &gif.Options{NumColors: len(palette)})
+}
+
+// LoadCartridge reads an Octo cartridge, configures the emulator with
+// its options (see OctoOptions.Machine and OctoOptions.Palette) and
+// loads its program.
+func LoadCartridge(c *Emulator, r io.Reader) (*Cartridge, error) {
+	cart, err := ReadCartridge(r)
+	if err != nil {
+		return cart, err
+	}
+
+	c.Machine = cart.Options.Machine()
+	if p := cart.Options.Palette(); p != nil {
+		c.Palette = p
+	}
+
+	c.Reset()
+	return cart, c.LoadROM(bytes.NewReader(cart.ROM))
+}
+
+// Machine returns the machine that runs the program like Octo does with
+// the options: the machine is chosen by the maximum size of the program,
+// with the quirks and the font of the options.
+func (o *OctoOptions) Machine() *Machine {
+	var m Machine
+	switch {
+	case o.MaxSize > 0 && o.MaxSize <= 3232: // below the variables of the VIP interpreter
+		m = *COSMACVIP
+	case o.MaxSize > 0 && o.MaxSize <= 3584: // the 4K memory
+		m = *SCHIP
+	default:
+		m = *XOCHIP
+	}
+
+	m.Quirks = Quirks{
+		ShiftVy:     !o.ShiftQuirks,
+		LoadStoreI:  !o.LoadStoreQuirks,
+		JumpVx:      o.JumpQuirks,
+		ResetVF:     o.LogicQuirks,
+		ClipSprites: o.ClipQuirks,
+		WaitVBlank:  o.VBlankQuirks,
+	}
+
+	if f := dbFonts[o.FontStyle]; f != nil {
+		m.Font = f
+	}
+
+	return &m
+}
+
+// Palette returns the palette of the options, or nil if they have no
+// valid colors.
+func (o *OctoOptions) Palette() *Palette {
+	bg, err := parseColor(o.BackgroundColor)
+	if err != nil {
+		return nil
+	}
+
+	fg, err := parseColor(o.FillColor)
+	if err != nil {
+		return nil
+	}
+
+	return &Palette{
+		Background:  bg,
+		Foreground:  fg,
+		Foreground2: parseColorOr(o.FillColor2, color.RGBA{}),
+		Blend:       parseColorOr(o.BlendColor, color.RGBA{}),
+	}
+}
+
+// parseColorOr parses a "#RRGGBB" color, returning def if it is invalid.
+func parseColorOr(s string, def color.RGBA) color.RGBA {
+	if c, err := parseColor(s); err == nil {
+		return c
+	}
+
+	return def
+}
+
+// disassembleBytes writes the ROM as Octo source of byte literals.
+func disassembleBytes(rom []byte) string {
+	var sb strings.Builder
+	sb.WriteString(": main\n")
+
+	for i, b := range rom {
+		fmt.Fprintf(&sb, "0x%02X", b)
+		if i%16 == 15 || i == len(rom)-1 {
+			sb.WriteByte('\n')
+		} else {
+			sb.WriteByte(' ')
+		}
+	}
+
+	return sb.String()
+}
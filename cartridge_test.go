@@ -0,0 +1,231 @@
+package chip8_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+func TestCartridge(t *testing.T) {
+	tests := []struct {
+		name string
+		rom  []byte
+	}{
+		{"Empty", nil},
+		{"Small", []byte{0x00, 0xE0, 0x12, 0x02}},
+		{"Large", bytes.Repeat([]byte{0xA2, 0x00, 0xD0, 0x1F}, 1000)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := chip8.OctoOptions{Tickrate: 20, FillColor: "#FF6600", BackgroundColor: "#996600", ShiftQuirks: true, MaxSize: 3584}
+
+			var out bytes.Buffer
+			if err := chip8.WriteCartridge(&out, &chip8.Cartridge{Options: opts, ROM: test.rom}); err != nil {
+				t.Fatal(err)
+			}
+
+			cart, err := chip8.ReadCartridge(&out)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(cart.ROM, test.rom) || cart.Options != opts {
+				t.Fatalf("expected the ROM and options to be kept, but got %+v", cart.Options)
+			}
+		})
+	}
+}
+
+func TestCartridgeSource(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		rom    []byte
+		err    error
+	}{
+		{"Bytes", ": main\n0x00 0xE0 # clear\n18 0b00000010\n", []byte{0x00, 0xE0, 18, 0x02}, nil},
+		{"Labels", ": main 0x00 0xE0 : back 0x12 0x02", []byte{0x00, 0xE0, 0x12, 0x02}, nil},
+		{"Instructions", ": main\nclear\nloop again\n", []byte{0x00, 0xE0, 0x12, 0x02}, nil},
+		{"JumpToMain", ": data 0xAB\n: main\ni := data\n", []byte{0x12, 0x03, 0xAB, 0xA2, 0x02}, nil},
+		{"Word", ": main 0x1202", nil, chip8.ErrOctoSource},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img := octoCartridge(t, `{"options": {"tickrate": 7}, "program": `+quote(test.source)+`}`)
+
+			cart, err := chip8.ReadCartridge(bytes.NewReader(img))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, but got %v", test.err, err)
+			}
+
+			if cart.Source != test.source || cart.Options.Tickrate != 7 || !bytes.Equal(cart.ROM, test.rom) {
+				t.Fatalf("unexpected cartridge: %+v", cart)
+			}
+		})
+	}
+}
+
+func TestCartridgeOcto(t *testing.T) {
+	// a cartridge laid out like the ones saved by Octo: an animated label,
+	// the payload split on two frames, and the source of the program
+	path := filepath.Join("testdata", "octo-bounce.gif")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cart, err := chip8.ReadCartridge(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(cart.Source, ": main\n\thires\n") || !strings.HasSuffix(cart.Source, "\n") {
+		t.Fatalf("expected the source of the program, but got %q", cart.Source)
+	}
+
+	// jump main, the ball and paddle sprites, and move-paddle
+	prefix := []byte{
+		0x12, 0x38,
+		0x60, 0xF0, 0xF0, 0x60,
+		0xFF, 0xFF,
+		0x65, 0x07, 0xE5, 0xA1, 0x74, 0xFE, 0x65, 0x09, 0xE5, 0xA1, 0x74, 0x02, 0x00, 0xEE,
+	}
+
+	if !bytes.HasPrefix(cart.ROM, prefix) || len(cart.ROM) != 106 {
+		t.Fatalf("expected the compiled program, but got % X", cart.ROM)
+	}
+
+	expected := chip8.OctoOptions{
+		Tickrate:        20,
+		FillColor:       "#FFCC00",
+		FillColor2:      "#FF6600",
+		BlendColor:      "#662200",
+		BackgroundColor: "#996600",
+		BuzzColor:       "#FFAA00",
+		QuietColor:      "#000000",
+		ClipQuirks:      true,
+		MaxSize:         3216,
+		FontStyle:       "octo",
+		TouchInputMode:  "none",
+	}
+
+	if cart.Options != expected {
+		t.Fatalf("expected options %+v, but got %+v", expected, cart.Options)
+	}
+
+	if m := cart.Options.Machine(); m.ID != chip8.COSMACVIP.ID || m.Font != chip8.XOCHIPFont {
+		t.Fatalf("expected a VIP with the Octo font, but got %s", m.ID)
+	}
+
+	f, err := chip8.ReadROMFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(f.ROM, cart.ROM) || f.Format() != chip8.FormatCartridge {
+		t.Fatalf("expected the compiled program, but got % X", f.ROM)
+	}
+}
+
+func TestCartridgeInvalid(t *testing.T) {
+	if _, err := chip8.ReadCartridge(bytes.NewReader([]byte("not a gif"))); !errors.Is(err, chip8.ErrCartridge) {
+		t.Fatalf("expected ErrCartridge, but got %v", err)
+	}
+
+	// a picture without a payload
+	var out bytes.Buffer
+	img := image.NewPaletted(image.Rect(0, 0, 16, 16), color.Palette{color.White, color.Black})
+	for i := range img.Pix {
+		img.Pix[i] = 1
+	}
+
+	if err := gif.Encode(&out, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := chip8.ReadCartridge(&out); !errors.Is(err, chip8.ErrCartridge) {
+		t.Fatalf("expected ErrCartridge, but got %v", err)
+	}
+}
+
+func TestLoadCartridge(t *testing.T) {
+	opts := chip8.OctoOptions{
+		FillColor:       "#FFCC00",
+		BackgroundColor: "#996600",
+		ShiftQuirks:     true,
+		LoadStoreQuirks: true,
+		ClipQuirks:      true,
+		MaxSize:         3232,
+		FontStyle:       "vip",
+	}
+
+	var out bytes.Buffer
+	if err := chip8.WriteCartridge(&out, &chip8.Cartridge{Options: opts, ROM: []byte{0x00, 0xE0}}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := chip8.NewEmulator(nil)
+	if _, err := chip8.LoadCartridge(c, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := chip8.Quirks{ClipSprites: true}
+	if c.Machine.ID != chip8.COSMACVIP.ID || c.Machine.Quirks != expected || c.Machine.Font != chip8.VIPFont {
+		t.Fatalf("expected a VIP with quirks %+v and VIP font, but got %s with %+v", expected, c.Machine.ID, c.Machine.Quirks)
+	}
+
+	if c.Palette == nil || c.Palette.Foreground != (color.RGBA{R: 0xFF, G: 0xCC, B: 0x00, A: 0xFF}) {
+		t.Fatalf("unexpected palette: %v", c.Palette)
+	}
+
+	if c.Memory[chip8.AddrStart] != 0x00 || c.Memory[chip8.AddrStart+1] != 0xE0 {
+		t.Fatal("expected the ROM to be loaded")
+	}
+}
+
+// quote returns s as a JSON string.
+func quote(s string) string {
+	var out bytes.Buffer
+	out.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case '\n':
+			out.WriteString(`\n`)
+		default:
+			out.WriteRune(r)
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
+// octoCartridge encodes the payload as a cartridge GIF.
+func octoCartridge(t *testing.T, payload string) []byte {
+	data := append([]byte{0, 0, 0, byte(len(payload))}, payload...)
+
+	palette := color.Palette{color.Black, color.Black, color.Black, color.Black}
+	img := image.NewPaletted(image.Rect(0, 0, 64, len(data)*4/64+1), palette)
+	for i := 0; i < len(data)*4; i++ {
+		img.Pix[i] = data[i/4] >> uint(6-i%4*2) & 0x03
+	}
+
+	var out bytes.Buffer
+	if err := gif.Encode(&out, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	return out.Bytes()
+}
@@ -22,15 +22,30 @@ const (
 	KeyF
 )
 
+// Keypad2 is added to the keys above to represent the keys of the
+// second keypad, available on the CHIP-8X (ex: Keypad2 + KeyA).
+const Keypad2 = 16
+
 // ErrStackOverflow is returned when the memory reserved for the call
 // stack is exceeded due to a programming error.
 var ErrStackOverflow = errors.New("stack overflow")
 
+// ErrStackUnderflow is returned when the program returns from a
+// subroutine (00EE) with the stack empty.
+var ErrStackUnderflow = errors.New("stack underflow")
+
 // ErrInputHalt is returned when the emulator is stopped due to waiting for
 // a key press from the user. This happens when the instruction Fx0A is requested
 // to execute, since it halts the emulation until a user input is received.
+// It is also returned when waiting for data on the I/O port (see Port).
 var ErrInputHalt = errors.New("awaiting for key input")
 
+// ErrStopped is returned when the program executes an instruction that
+// stops the interpreter, like the 00FD of the SUPER-CHIP or the 00ED of
+// the CHIP-8E. The program counter stays on the instruction, so the
+// emulator keeps returning this error.
+var ErrStopped = errors.New("program stopped")
+
 // Emulator is the main CHIP-8 CPU emulator.
 // It holds the memory, register, stack and all ohter
 // components of the CHIP-8 spec.
@@ -38,28 +53,102 @@ var ErrInputHalt = errors.New("awaiting for key input")
 // All the CHIP-8 internals are exported, so you can easily check
 // or even modify memory, registers, etc.
 //
+// The memory layout, display and instructions available depend on the
+// emulated Machine; use NewEmulator to create an emulator for a specific
+// machine. The zero value is an emulator for the COSMAC VIP, whose memory
+// is allocated on the first call to Reset (or LoadROM).
+//
 // Be wary that only the 'logic' of CHIP-8 is emulated; the
 // IO (ex: graphics and keyboard) must be implemented separately.
 type Emulator struct {
-	Memory [4096]byte // main memory
-	V      [16]byte   // Vx registers
-	I      uint16     // register to store memory address
-	DT     byte       // delay timer
-	ST     byte       // sound timer
-	PC     uint16     // program counter
-	SP     int8       // stack pointer
-	Stack  [16]uint16 // the stack itself
+	Memory []byte   // main memory (see Machine.MemorySize)
+	V      [16]byte // Vx registers
+	I      uint16   // register to store memory address
+	DT     byte     // delay timer
+	ST     byte     // sound timer
+	PC     uint16   // program counter
+	SP     int      // stack pointer (number of calls on the stack)
+	Stack  []uint16 // the stack itself, unless kept in memory
+
+	Pattern [16]byte // XO-CHIP audio pattern buffer
+	Pitch   byte     // XO-CHIP audio pitch register
+	Planes  byte     // XO-CHIP bitplanes selected by Fn01, one bit per plane
+	Flags   [16]byte // SUPER-CHIP flag registers (Fx75 and Fx85), kept by Reset
+
+	Machine *Machine    // emulated machine (nil means COSMACVIP)
+	Display DisplayMode // current display mode
+	Palette *Palette    // display colors (nil means DefaultPalette)
+	Keys    uint32      // pressed keys, one bit per key (see Keypad2)
+	Port    Port        // I/O port (nil means no device attached)
+
+	Background byte        // CHIP-8X background color
+	Zones      [32][8]byte // CHIP-8X foreground colors, for each row of 8 pixels
+
+	Mega MegaChip // MegaChip state
+
+	// Trace, if not nil, is called before executing each instruction,
+	// with its address and opcode.
+	Trace func(pc uint16, ins *Instruction, a byte, b byte)
+
+	Engine Engine      // how the program is executed (see EngineInterpreter)
+	Cycles uint64      // elapsed machine cycles (see RunCycles)
+	Speed  int         // instructions per frame on machines without a Timing (0 means DefaultSpeed)
+	Policy ErrorPolicy // how failed instructions are handled
+
+	// Unprotected allows programs to write to the memory reserved for the
+	// interpreter (see Machine.MemoryMap).
+	Unprotected bool
+
+	// MemoryStack keeps the stack in the emulated memory, below the
+	// StackAddr of the machine, like the COSMAC VIP interpreter does;
+	// programs may then read and change it. It has no effect on machines
+	// without a StackAddr.
+	MemoryStack bool
+
+	delaying    bool      // waiting for the delay timer (CHIP-8E)
+	devices     []mapping // devices mapped on the memory (see Map)
+	decoder     *decoder  // decoded instructions of the machine
+	video       []byte    // video memory, if after the addressable memory
+	frameCycles int       // machine cycles since the last 60 Hz interrupt
+}
+
+// Tick updates the delay and sound timers. It should be called at a
+// rate of 60Hz, independently of how many cycles are executed.
+func (c *Emulator) Tick() {
+	if c.DT > 0 {
+		c.DT--
+	}
+
+	if c.ST > 0 {
+		c.ST--
+	}
 }
 
 // PressKey signal to the emulator that a given key is pressed.
 // the key will keep being counted as pressed until a call to
 // ReleaseKey. Pressing an already pressed key is a noop.
-func (e *Emulator) PressKey(key int) {
-
+func (c *Emulator) PressKey(key int) {
+	c.Keys |= 1 << uint(key)
 }
 
 // ReleaseKey signal to the emulator that a given key is released.
 // Releasing an unpressed key is a noop.
-func (e *Emulator) ReleaseKey(key int) {
+func (c *Emulator) ReleaseKey(key int) {
+	c.Keys &^= 1 << uint(key)
+}
+
+// IsPressed reports whether the given key is pressed.
+func (c *Emulator) IsPressed(key int) bool {
+	return c.Keys&(1<<uint(key)) != 0
+}
+
+// Port is an I/O port, used by the machines that have input and
+// output instructions.
+type Port interface {
+	// Out sends a byte to the port.
+	Out(value byte)
 
+	// In receives a byte from the port. If no data is available,
+	// ok must be false.
+	In() (value byte, ok bool)
 }
@@ -0,0 +1,53 @@
+package chip8_test
+
+import (
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+func TestTick(t *testing.T) {
+	c := chip8.Emulator{DT: 2, ST: 1}
+
+	c.Tick()
+	if c.DT != 1 || c.ST != 0 {
+		t.Fatalf("expected DT=1 and ST=0 after first tick, but found DT=%d and ST=%d", c.DT, c.ST)
+	}
+
+	c.Tick()
+	c.Tick()
+	if c.DT != 0 || c.ST != 0 {
+		t.Fatalf("timers should stop at zero, but found DT=%d and ST=%d", c.DT, c.ST)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	var c chip8.Emulator
+
+	c.PressKey(chip8.KeyA)
+	c.PressKey(chip8.KeyA)
+	c.PressKey(chip8.Keypad2 + chip8.Key3)
+
+	if !c.IsPressed(chip8.KeyA) {
+		t.Fatal("expected key A to be pressed")
+	}
+
+	if !c.IsPressed(chip8.Keypad2 + chip8.Key3) {
+		t.Fatal("expected key 3 of the second keypad to be pressed")
+	}
+
+	if c.IsPressed(chip8.Key3) {
+		t.Fatal("key 3 of the first keypad should not be pressed")
+	}
+
+	c.ReleaseKey(chip8.KeyA)
+	c.ReleaseKey(chip8.KeyB)
+
+	if c.IsPressed(chip8.KeyA) {
+		t.Fatal("expected key A to be released")
+	}
+
+	if c.Keys != 1<<(chip8.Keypad2+chip8.Key3) {
+		t.Fatalf("unexpected key state 0x%08X", c.Keys)
+	}
+}
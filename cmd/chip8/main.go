@@ -1,5 +1,464 @@
 package main
 
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ibraimgm/chip8"
+)
+
+const framesPerSecond = 60
+
+func machineIDs() string {
+	ids := make([]string, len(chip8.Machines))
+	for i, m := range chip8.Machines {
+		ids[i] = m.ID
+	}
+
+	return strings.Join(ids, ", ")
+}
+
+func fontIDs() string {
+	ids := make([]string, len(chip8.Fonts))
+	for i, f := range chip8.Fonts {
+		ids[i] = f.ID
+	}
+
+	return strings.Join(ids, ", ")
+}
+
 func main() {
-	println("OK")
+	if len(os.Args) > 1 && os.Args[1] == "patch" {
+		patchMain(os.Args[2:])
+		return
+	}
+
+	wavFile := flag.String("wav", "", "write the sound output to a WAV `file`")
+	pngFile := flag.String("screenshot", "", "write the final state of the display to a PNG `file`")
+	seconds := flag.Float64("seconds", 10, "how many seconds of emulation to run")
+	speed := flag.Int("speed", chip8.DefaultSpeed, "instructions executed per frame")
+	pitch := flag.Float64("pitch", chip8.DefaultPitch, "beeper pitch, in Hz")
+	volume := flag.Float64("volume", chip8.DefaultVolume, "sound volume, from 0 to 1")
+	machine := flag.String("machine", chip8.COSMACVIP.ID, "emulated machine ("+machineIDs()+")")
+	exts := flag.String("ext", "", "comma separated list of instruction set extensions (chip8e, chip8i)")
+	pattern := flag.Bool("pattern", false, "play the XO-CHIP audio pattern instead of the beeper")
+	timed := flag.Bool("timed", false, "run by the machine timing model (ignores -speed)")
+	unprotected := flag.Bool("unprotected", false, "allow the program to write to the interpreter memory")
+	unknown := flag.String("unknown", "halt", "action on unknown instructions (halt, skip, log)")
+	font := flag.String("font", "", "built-in font ("+fontIDs()+") or font `file`")
+	fontAddr := flag.Int("fontaddr", -1, "font `address` (default: the address of the machine)")
+	dbFile := flag.String("db", "", "ROM database `file` (chip-8-database programs.json) overriding the built-in one")
+	patchFile := flag.String("patch", "", "IPS or BPS patch `file` to apply to the ROM before running it")
+	palette := flag.String("palette", "", "display `colors`, as #RRGGBB: background,foreground[,plane2,blend]")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [options] rom\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s patch [-o file] rom patch...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	m, err := buildMachine(*machine, *exts)
+	if err == nil {
+		m, err = withFont(m, *font, *fontAddr)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var colors *chip8.Palette
+	if *palette != "" {
+		if colors, err = chip8.ParsePalette(*palette); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	action, ok := errorActions[*unknown]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown action: %s\n", *unknown)
+		os.Exit(2)
+	}
+
+	opts := options{
+		machine: m,
+		wavFile: *wavFile,
+		pngFile: *pngFile,
+		seconds: *seconds,
+		speed:   *speed,
+		pitch:   *pitch,
+		volume:  *volume,
+		pattern: *pattern,
+		timed:   *timed,
+		unknown: action,
+		palette: colors,
+
+		unprotected: *unprotected,
+		dbFile:      *dbFile,
+		patchFile:   *patchFile,
+	}
+
+	// the flags given by the user take precedence over the ROM database
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "machine", "ext", "font", "fontaddr":
+			opts.machineSet = true
+		case "speed":
+			opts.speedSet = true
+		}
+	})
+
+	if err := run(flag.Arg(0), opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// buildMachine finds the machine with the given ID, adding the
+// (comma separated) extensions to it.
+func buildMachine(id string, exts string) (*chip8.Machine, error) {
+	m := chip8.FindMachine(id)
+	if m == nil {
+		return nil, fmt.Errorf("unknown machine: %s", id)
+	}
+
+	if exts == "" {
+		return m, nil
+	}
+
+	for _, extID := range strings.Split(exts, ",") {
+		ext := chip8.FindExtension(strings.TrimSpace(extID))
+		if ext == nil {
+			return nil, fmt.Errorf("unknown extension: %s", extID)
+		}
+
+		m = m.WithExtensions(ext)
+	}
+
+	return m, nil
+}
+
+// withFont changes the font of the machine to a built-in font or to a
+// font file, at the given address (or at the address of the machine, if
+// negative).
+func withFont(m *chip8.Machine, font string, addr int) (*chip8.Machine, error) {
+	if font == "" && addr < 0 {
+		return m, nil
+	}
+
+	f := m.Font
+	if font != "" {
+		if f = chip8.FindFont(font); f == nil {
+			file, err := os.Open(font)
+			if err != nil {
+				return nil, fmt.Errorf("unknown font: %s", font)
+			}
+			defer file.Close()
+
+			if f, err = chip8.LoadFont(font, file); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if addr < 0 {
+		addr = m.SpriteAddr
+	}
+
+	if f != nil && addr+len(f.Small)+len(f.Big) > m.StartAddr {
+		return nil, fmt.Errorf("font at 0x%X overlaps the program area", addr)
+	}
+
+	return m.WithFont(f, addr), nil
+}
+
+type options struct {
+	machine *chip8.Machine
+	wavFile string
+	pngFile string
+	seconds float64
+	speed   int
+	pitch   float64
+	volume  float64
+	pattern bool
+	timed   bool
+	unknown chip8.ErrorAction
+	palette *chip8.Palette
+
+	unprotected bool
+	dbFile      string
+	patchFile   string
+	machineSet  bool // machine chosen by the user, instead of the database
+	speedSet    bool // speed chosen by the user, instead of the database
+}
+
+// errorActions maps the names of the -unknown flag to the actions.
+var errorActions = map[string]chip8.ErrorAction{
+	"halt": chip8.ActionHalt,
+	"skip": chip8.ActionSkip,
+	"log":  chip8.ActionLog,
+}
+
+// newAudio creates the sound generator for the emulator, returning
+// the generator and its sample rate.
+func newAudio(c *chip8.Emulator, opts options) (io.Reader, int) {
+	if opts.machine.Instructions&chip8.InstrMegaChip != 0 {
+		player := chip8.NewSamplePlayer(c)
+		player.Volume = opts.volume
+		return player, player.SampleRate
+	}
+
+	if opts.pattern {
+		player := chip8.NewPatternPlayer(c)
+		player.Volume = opts.volume
+		return player, player.SampleRate
+	}
+
+	beeper := chip8.NewBeeper(c)
+	beeper.Pitch = opts.pitch
+	beeper.Volume = opts.volume
+	return beeper, beeper.SampleRate
+}
+
+// loadDatabase returns the built-in ROM database, merged with the
+// database file of the user, if any.
+func loadDatabase(dbFile string) (*chip8.Database, error) {
+	db := chip8.DefaultDatabase()
+	if dbFile == "" {
+		return db, nil
+	}
+
+	in, err := os.Open(dbFile)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	user, err := chip8.ReadDatabase(in)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.Merge(user), nil
+}
+
+// minConfidence is the confidence needed to run an unknown ROM on the
+// detected machine, instead of the default one.
+const minConfidence = 0.5
+
+// configure changes the options to run a ROM known by the database, or
+// else inferred from its file or detected by its opcodes, unless chosen
+// by the user.
+func configure(c *chip8.Emulator, f *chip8.ROMFile, info *chip8.ROMInfo, opts *options) {
+	if info == nil {
+		if opts.machineSet {
+			return
+		}
+
+		// the .ch8 extension is used by programs of every platform
+		if f.Machine != nil && f.Machine != chip8.COSMACVIP {
+			opts.machine = f.Machine
+		} else if d := chip8.DetectPlatform(f.ROM); d.Confidence >= minConfidence {
+			opts.machine = d.Machine
+		}
+
+		c.Machine = opts.machine
+		c.Reset()
+		return
+	}
+
+	if m := info.Machine(); m != nil && !opts.machineSet {
+		opts.machine = m
+	}
+
+	if info.Tickrate > 0 && !opts.speedSet {
+		opts.speed = info.Tickrate
+	}
+
+	if p := info.Palette(); p != nil && opts.palette == nil {
+		c.Palette = p
+	}
+
+	c.Machine = opts.machine
+	c.Reset()
+}
+
+func run(romFile string, opts options) error {
+	f, err := chip8.ReadROMFile(romFile)
+	if err != nil {
+		return err
+	}
+
+	db, err := loadDatabase(opts.dbFile)
+	if err != nil {
+		return err
+	}
+
+	// a patched ROM is usually unknown, but runs like the original one
+	info := db.Lookup(f.ROM)
+	if opts.patchFile != "" {
+		if f.ROM, err = applyPatches(f.ROM, []string{opts.patchFile}); err != nil {
+			return err
+		}
+
+		if patched := db.Lookup(f.ROM); patched != nil {
+			info = patched
+		}
+	}
+
+	c := chip8.NewEmulator(opts.machine)
+	c.Policy.UnknownOpcode = opts.unknown
+	c.Unprotected = opts.unprotected
+	c.Palette = opts.palette
+	configure(c, f, info, &opts)
+	if err := c.LoadROM(bytes.NewReader(f.ROM)); err != nil {
+		return err
+	}
+
+	audio, sampleRate := newAudio(c, opts)
+
+	var pcm bytes.Buffer
+	samples := make([]byte, sampleRate/framesPerSecond*chip8.BytesPerSample)
+	frames := int(opts.seconds * framesPerSecond)
+
+	for frame := 0; frame < frames; frame++ {
+		err := runFrame(c, opts)
+		if errors.Is(err, chip8.ErrStopped) {
+			break
+		}
+
+		if isFatal(err) {
+			return err
+		}
+
+		if _, err := audio.Read(samples); err != nil {
+			return err
+		}
+		pcm.Write(samples)
+	}
+
+	if err := writeScreenshot(c, opts.pngFile); err != nil {
+		return err
+	}
+
+	return writeAudio(opts.wavFile, sampleRate, pcm.Bytes())
+}
+
+// runFrame runs one frame of the emulation, updating the timers.
+func runFrame(c *chip8.Emulator, opts options) error {
+	if opts.timed {
+		_, err := c.RunFrame()
+		return err
+	}
+
+	_, err := c.Execute(opts.speed)
+	c.Tick()
+	return err
+}
+
+// isFatal reports whether the error returned by the emulator should
+// stop the emulation.
+func isFatal(err error) bool {
+	return err != nil && !errors.Is(err, chip8.ErrInputHalt) && !errors.Is(err, chip8.ErrDelayHalt)
+}
+
+func writeScreenshot(c *chip8.Emulator, pngFile string) error {
+	if pngFile == "" {
+		return nil
+	}
+
+	out, err := os.Create(pngFile)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	return png.Encode(out, c.Image())
+}
+
+func writeAudio(wavFile string, sampleRate int, pcm []byte) error {
+	if wavFile == "" {
+		return nil
+	}
+
+	out, err := os.Create(wavFile)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	return chip8.WriteWAV(out, sampleRate, pcm)
+}
+
+// patchMain runs the patch command, which applies IPS or BPS patches to
+// a ROM and writes the patched ROM.
+func patchMain(args []string) {
+	cmd := flag.NewFlagSet("patch", flag.ExitOnError)
+	outFile := cmd.String("o", "", "write the patched ROM to `file` (default: the ROM name with a -patched suffix)")
+	cmd.Usage = func() {
+		fmt.Fprintf(cmd.Output(), "usage: %s patch [-o file] rom patch...\n", os.Args[0])
+		cmd.PrintDefaults()
+	}
+	_ = cmd.Parse(args)
+
+	if cmd.NArg() < 2 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+
+	if err := patch(cmd.Arg(0), cmd.Args()[1:], *outFile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// patch applies the patches to the ROM file, in order, writing the
+// patched ROM to outFile.
+func patch(romFile string, patchFiles []string, outFile string) error {
+	f, err := chip8.ReadROMFile(romFile)
+	if err != nil {
+		return err
+	}
+
+	rom, err := applyPatches(f.ROM, patchFiles)
+	if err != nil {
+		return err
+	}
+
+	if outFile == "" {
+		ext := filepath.Ext(f.Name)
+		outFile = filepath.Join(filepath.Dir(romFile), strings.TrimSuffix(f.Name, ext)+"-patched"+ext)
+	}
+
+	return ioutil.WriteFile(outFile, rom, 0644)
+}
+
+// applyPatches applies the IPS or BPS patch files to the ROM, in order.
+func applyPatches(rom []byte, patchFiles []string) ([]byte, error) {
+	for _, name := range patchFiles {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if rom, err = chip8.ApplyPatch(rom, data); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return rom, nil
 }
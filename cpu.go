@@ -14,8 +14,8 @@ const (
 // using an unsupported instruction set.
 //
 // Unlike other errors, receiving a NoOpError will still move the
-// program counter and still counts the CPU cycle as executed. This
-// is done this way to make ignoring NOOP easier to handle for users.
+// program counter. To ignore unknown instructions, set the
+// UnknownOpcode action of the error policy (see ErrorPolicy).
 type NoOpError struct {
 	A byte
 	B byte
@@ -25,40 +25,23 @@ func (e NoOpError) Error() string {
 	return fmt.Sprintf("unknown instruction 0x%02X%02X (noop)", e.A, e.B)
 }
 
-// handlers for each 'category' of instruction
-var handlers = [16]func(*Emulator, byte, byte) error{
-	handleOp0,
-	nil,
-	nil,
-	nil,
-	nil,
-	nil,
-	handleOp6,
-	nil,
-	handleOp8,
-	nil,
-	nil,
-	nil,
-	nil,
-	nil,
-	nil,
-	nil,
-}
-
 // Execute runs at most 'cycles' CPU cycles, returning the number of cycles
 // executed and possibly an error value.
 //
 // Not all errors are fatal; particularly, the instruction Fx0A will keep
-// returning ErrInputHalt until a key is pressed.
+// returning ErrInputHalt until a key is pressed. Other errors of the
+// instructions are returned as an *ExecError, with the location of the
+// failure.
 func (c *Emulator) Execute(cycles int) (int, error) {
+	d := c.decoderFor()
+	if c.Engine == EngineRecompiler {
+		return c.executeBlocks(d, cycles)
+	}
+
 	executed := 0
 
 	for executed < cycles {
-		a := c.Memory[int(c.PC)]
-		b := c.Memory[int(c.PC+1)]
-		c.PC += 2
-
-		if err := handlers[a&msnMask>>4](c, a, b); err != nil {
+		if err := c.step(d, executed); err != nil {
 			return executed, err
 		}
 
@@ -68,30 +51,420 @@ func (c *Emulator) Execute(cycles int) (int, error) {
 	return executed, nil
 }
 
-func handleOp0(c *Emulator, a byte, b byte) error {
-	if a == 0x00 && b == 0xE0 {
-		for i := 0; i < 256; i++ {
-			c.Memory[AddrVideo+i] = 0
-		}
+// step executes the instruction pointed by PC, after the given number of
+// executed cycles, applying the error policy. It returns nil if the
+// instruction ran, or if its error was ignored by the policy.
+func (c *Emulator) step(d *decoder, executed int) error {
+	entry, err := c.fetch(d)
+	if err != nil {
+		// like unknown instructions, skip the failed fetch
+		c.PC += 2
+		return c.fail(err, c.PC-2, c.Read(int(c.PC-2)), 0, executed)
+	}
+
+	c.PC += 2
+
+	if entry.ins == nil {
+		return c.fail(NoOpError{A: entry.a, B: entry.b}, c.PC-2, entry.a, entry.b, executed)
+	}
+
+	if c.Trace != nil {
+		c.Trace(c.PC-2, entry.ins, entry.a, entry.b)
+	}
+
+	if err := entry.ins.Exec(c, entry.a, entry.b); err != nil {
+		return c.fail(err, c.PC-2, entry.a, entry.b, executed)
+	}
+
+	return nil
+}
+
+// chip8Instructions returns the instructions of the original CHIP-8.
+func chip8Instructions() []*Instruction {
+	return []*Instruction{
+		{Mask: 0xFFFF, Pattern: 0x00E0, Mnemonic: "CLS", Format: "CLS", Cycles: 1, Exec: opCls},
+		{Mask: 0xFFFF, Pattern: 0x00EE, Mnemonic: "RET", Format: "RET", Cycles: 1, Branch: true, Exec: opRet},
+		{Mask: 0xF000, Pattern: 0x0000, Mnemonic: "SYS", Format: "SYS {nnn}", Cycles: 1, Exec: opSys},
+		{Mask: 0xF000, Pattern: 0x1000, Mnemonic: "JP", Format: "JP {nnn}", Cycles: 1, Branch: true, Exec: opJp},
+		{Mask: 0xF000, Pattern: 0x2000, Mnemonic: "CALL", Format: "CALL {nnn}", Cycles: 1, Branch: true, Exec: opCall},
+		{Mask: 0xF000, Pattern: 0x6000, Mnemonic: "LD", Format: "LD V{x}, {nn}", Cycles: 1, Exec: opLdVxByte},
+		{Mask: 0xF00F, Pattern: 0x8000, Mnemonic: "LD", Format: "LD V{x}, V{y}", Cycles: 1, Exec: opLdVxVy},
+		{Mask: 0xF000, Pattern: 0xA000, Mnemonic: "LD", Format: "LD I, {nnn}", Cycles: 1, Exec: opLdI},
+		{Mask: 0xF000, Pattern: 0xB000, Mnemonic: "JP", Format: "JP V0, {nnn}", Cycles: 1, Branch: true, Exec: opJpV0},
+		{Mask: 0xF000, Pattern: 0xD000, Mnemonic: "DRW", Format: "DRW V{x}, V{y}, {n}", Cycles: 1, Exec: opDraw},
+		{Mask: 0xF0FF, Pattern: 0xE09E, Mnemonic: "SKP", Format: "SKP V{x}", Cycles: 1, Branch: true, Exec: opSkp},
+		{Mask: 0xF0FF, Pattern: 0xE0A1, Mnemonic: "SKNP", Format: "SKNP V{x}", Cycles: 1, Branch: true, Exec: opSknp},
+		{Mask: 0xF0FF, Pattern: 0xF007, Mnemonic: "LD", Format: "LD V{x}, DT", Cycles: 1, Exec: opLdVxDT},
+		{Mask: 0xF0FF, Pattern: 0xF015, Mnemonic: "LD", Format: "LD DT, V{x}", Cycles: 1, Exec: opLdDTVx},
+		{Mask: 0xF0FF, Pattern: 0xF018, Mnemonic: "LD", Format: "LD ST, V{x}", Cycles: 1, Exec: opLdSTVx},
+		{Mask: 0xF0FF, Pattern: 0xF029, Mnemonic: "LD", Format: "LD F, V{x}", Cycles: 1, Exec: opLdF},
+		{Mask: 0xF0FF, Pattern: 0xF033, Mnemonic: "LD", Format: "LD B, V{x}", Cycles: 1, Exec: opLdBCD},
+		{Mask: 0xF0FF, Pattern: 0xF055, Mnemonic: "LD", Format: "LD [I], V{x}", Cycles: 1, Exec: opStore},
+	}
+}
+
+// hiresInstructions returns the instructions of the Hi-res CHIP-8.
+func hiresInstructions() []*Instruction {
+	return []*Instruction{
+		{Mask: 0xFFFF, Pattern: 0x0230, Mnemonic: "CLS", Format: "CLS", Cycles: 1, Exec: opCls},
+	}
+}
+
+// schipInstructions returns the instructions of the SUPER-CHIP.
+func schipInstructions() []*Instruction {
+	return []*Instruction{
+		{Mask: 0xFFF0, Pattern: 0x00C0, Mnemonic: "SCRD", Format: "SCRD {n}", Cycles: 1, Exec: opScrollDown},
+		{Mask: 0xFFFF, Pattern: 0x00FB, Mnemonic: "SCRR", Format: "SCRR", Cycles: 1, Exec: opScrollRight},
+		{Mask: 0xFFFF, Pattern: 0x00FC, Mnemonic: "SCRL", Format: "SCRL", Cycles: 1, Exec: opScrollLeft},
+		{Mask: 0xFFFF, Pattern: 0x00FD, Mnemonic: "EXIT", Format: "EXIT", Cycles: 1, Branch: true, Exec: opStop},
+		{Mask: 0xFFFF, Pattern: 0x00FE, Mnemonic: "LOW", Format: "LOW", Cycles: 1, Exec: opLowRes},
+		{Mask: 0xFFFF, Pattern: 0x00FF, Mnemonic: "HIGH", Format: "HIGH", Cycles: 1, Exec: opHighRes},
+		{Mask: 0xF00F, Pattern: 0xD000, Mnemonic: "DRW", Format: "DRW V{x}, V{y}, 0", Cycles: 1, Exec: opDrawBig},
+		{Mask: 0xF0FF, Pattern: 0xF030, Mnemonic: "LD", Format: "LD HF, V{x}", Cycles: 1, Exec: opLdHF},
+		{Mask: 0xF0FF, Pattern: 0xF075, Mnemonic: "LD", Format: "LD R, V{x}", Cycles: 1, Exec: opSaveFlags},
+		{Mask: 0xF0FF, Pattern: 0xF085, Mnemonic: "LD", Format: "LD V{x}, R", Cycles: 1, Exec: opLoadFlags},
+	}
+}
+
+// chip8xInstructions returns the instructions of the CHIP-8X.
+func chip8xInstructions() []*Instruction {
+	return []*Instruction{
+		{Mask: 0xFFFF, Pattern: 0x02A0, Mnemonic: "BGND", Format: "BGND", Cycles: 1, Exec: opBackground},
+		{Mask: 0xF000, Pattern: 0xB000, Mnemonic: "COL", Format: "COL V{x}, V{y}, {n}", Cycles: 1, Exec: opColor},
+		{Mask: 0xF0FF, Pattern: 0xE0F2, Mnemonic: "SKP2", Format: "SKP2 V{x}", Cycles: 1, Branch: true, Exec: opSkp2},
+		{Mask: 0xF0FF, Pattern: 0xE0F5, Mnemonic: "SKNP2", Format: "SKNP2 V{x}", Cycles: 1, Branch: true, Exec: opSknp2},
+		{Mask: 0xF0FF, Pattern: 0xF0F8, Mnemonic: "OUT", Format: "OUT V{x}", Cycles: 1, Exec: opOut},
+		{Mask: 0xF0FF, Pattern: 0xF0FB, Mnemonic: "IN", Format: "IN V{x}", Cycles: 1, Branch: true, Exec: opIn},
+	}
+}
+
+// xochipInstructions returns the instructions of the XO-CHIP.
+func xochipInstructions() []*Instruction {
+	return []*Instruction{
+		{Mask: 0xFFF0, Pattern: 0x00D0, Mnemonic: "SCRU", Format: "SCRU {n}", Cycles: 1, Exec: opScrollUp},
+		{Mask: 0xFFFF, Pattern: 0xF000, Mnemonic: "LD", Format: "LD I, LONG", Cycles: 1, Branch: true, Exec: opLdIWord},
+		{Mask: 0xF0FF, Pattern: 0xF001, Mnemonic: "PLANE", Format: "PLANE {x}", Cycles: 1, Exec: opPlane},
+		{Mask: 0xFFFF, Pattern: 0xF002, Mnemonic: "AUDIO", Format: "AUDIO", Cycles: 1, Exec: opAudio},
+		{Mask: 0xF00F, Pattern: 0x5002, Mnemonic: "SAVE", Format: "SAVE V{x}, V{y}", Cycles: 1, Exec: opSave},
+		{Mask: 0xF00F, Pattern: 0x5003, Mnemonic: "LOAD", Format: "LOAD V{x}, V{y}", Cycles: 1, Exec: opLoad},
+		{Mask: 0xF0FF, Pattern: 0xF03A, Mnemonic: "PITCH", Format: "PITCH V{x}", Cycles: 1, Exec: opPitch},
+	}
+}
+
+func opCls(c *Emulator, a byte, b byte) error {
+	if c.Mega.Enabled {
+		c.clearMega()
+	} else {
+		c.clearPlanes(c.planes())
+	}
+
+	return nil
+}
+
+func opSys(c *Emulator, a byte, b byte) error {
+	return nil
+}
+
+func opJp(c *Emulator, a byte, b byte) error {
+	return c.jump(int(a&lsnMask)<<8 | int(b))
+}
+
+func opCall(c *Emulator, a byte, b byte) error {
+	addr := int(a&lsnMask)<<8 | int(b)
+	if c.access(addr) == Unmapped || c.access(addr+1) == Unmapped {
+		return ErrInvalidAddress
+	}
+
+	if err := c.push(c.PC); err != nil {
+		return err
 	}
 
+	c.PC = uint16(addr)
 	return nil
 }
 
-func handleOp6(c *Emulator, a byte, b byte) error {
+func opRet(c *Emulator, a byte, b byte) error {
+	addr, err := c.pop()
+	if err != nil {
+		return err
+	}
+
+	return c.jump(int(addr))
+}
+
+func opJpV0(c *Emulator, a byte, b byte) error {
+	x := byte(0)
+	if c.machine().Quirks.JumpVx {
+		x = a & lsnMask
+	}
+
+	return c.jump(int(a&lsnMask)<<8 | int(b) + int(c.V[x]))
+}
+
+func opLdVxByte(c *Emulator, a byte, b byte) error {
 	c.V[a&lsnMask] = b
 	return nil
 }
 
-func handleOp8(c *Emulator, a byte, b byte) error {
+func opLdVxVy(c *Emulator, a byte, b byte) error {
+	c.V[a&lsnMask] = c.V[b&msnMask>>4]
+	return nil
+}
+
+// skip skips the next instruction. On the XO-CHIP, the 4 bytes of the
+// F000 nnnn instruction are skipped at once.
+func (c *Emulator) skip() {
+	if c.supports(InstrXOCHIP) && c.Read(int(c.PC)) == 0xF0 && c.Read(int(c.PC)+1) == 0x00 {
+		c.PC += 4
+		return
+	}
+
+	c.PC += 2
+}
+
+func opLdI(c *Emulator, a byte, b byte) error {
+	c.I = uint16(a&lsnMask)<<8 | uint16(b)
+	return nil
+}
+
+func opDraw(c *Emulator, a byte, b byte) error {
+	return c.draw(int(c.V[a&lsnMask]), int(c.V[b&msnMask>>4]), int(b&lsnMask))
+}
+
+func opSkp(c *Emulator, a byte, b byte) error {
+	if c.IsPressed(int(c.V[a&lsnMask] & lsnMask)) {
+		c.skip()
+	}
+
+	return nil
+}
+
+func opSknp(c *Emulator, a byte, b byte) error {
+	if !c.IsPressed(int(c.V[a&lsnMask] & lsnMask)) {
+		c.skip()
+	}
+
+	return nil
+}
+
+func opLdVxDT(c *Emulator, a byte, b byte) error {
+	c.V[a&lsnMask] = c.DT
+	return nil
+}
+
+func opLdDTVx(c *Emulator, a byte, b byte) error {
+	c.DT = c.V[a&lsnMask]
+	return nil
+}
+
+func opLdSTVx(c *Emulator, a byte, b byte) error {
+	c.ST = c.V[a&lsnMask]
+	return nil
+}
+
+func opDrawBig(c *Emulator, a byte, b byte) error {
+	if c.Mega.Enabled {
+		return opDrawMega(c, a, b)
+	}
+
+	return c.drawSprite(int(c.V[a&lsnMask]), int(c.V[b&msnMask>>4]), 16, 16)
+}
+
+func opScrollRight(c *Emulator, a byte, b byte) error {
+	c.scrollColumns(4)
+	return nil
+}
+
+func opScrollLeft(c *Emulator, a byte, b byte) error {
+	c.scrollColumns(-4)
+	return nil
+}
+
+func opLowRes(c *Emulator, a byte, b byte) error {
+	return c.SetDisplayMode(c.machine().Modes[0])
+}
+
+func opHighRes(c *Emulator, a byte, b byte) error {
+	return c.SetDisplayMode(DisplayMode{Width: 128, Height: 64})
+}
+
+func opSaveFlags(c *Emulator, a byte, b byte) error {
+	x := a & lsnMask
+	copy(c.Flags[:x+1], c.V[:x+1])
+	return nil
+}
+
+func opLoadFlags(c *Emulator, a byte, b byte) error {
+	x := a & lsnMask
+	copy(c.V[:x+1], c.Flags[:x+1])
+	return nil
+}
+
+func opLdF(c *Emulator, a byte, b byte) error {
+	c.I = c.fontAddr(c.V[a&lsnMask], false)
+	return nil
+}
+
+func opLdHF(c *Emulator, a byte, b byte) error {
+	c.I = c.fontAddr(c.V[a&lsnMask], true)
+	return nil
+}
+
+func opLdBCD(c *Emulator, a byte, b byte) error {
+	vx := c.V[a&lsnMask]
+	return c.store(int(c.I), vx/100, vx/10%10, vx%10)
+}
+
+func opStore(c *Emulator, a byte, b byte) error {
+	x := a & lsnMask
+	if err := c.store(int(c.I), c.V[:x+1]...); err != nil {
+		return err
+	}
+
+	switch q := c.machine().Quirks; {
+	case q.LoadStoreX:
+		c.I += uint16(x)
+	case q.LoadStoreI:
+		c.I += uint16(x) + 1
+	}
+
+	return nil
+}
+
+func opBackground(c *Emulator, a byte, b byte) error {
+	c.Background = (c.Background + 1) % byte(len(CHIP8XBackgrounds))
+	return nil
+}
+
+func opColor(c *Emulator, a byte, b byte) error {
 	x := a & lsnMask
 	y := b & msnMask >> 4
 	n := b & lsnMask
+	fg := c.V[(x+1)&lsnMask]
 
 	if n == 0 {
-		c.V[x] = c.V[y]
-		return nil
+		c.setZones(c.V[x], int(c.V[y]&lsnMask)*4, int(c.V[y]&msnMask>>4+1)*4, fg)
+	} else {
+		c.setZones(c.V[x], int(c.V[y]), int(n), fg)
+	}
+
+	return nil
+}
+
+func opSkp2(c *Emulator, a byte, b byte) error {
+	if c.IsPressed(Keypad2 + int(c.V[a&lsnMask]&lsnMask)) {
+		c.skip()
+	}
+
+	return nil
+}
+
+func opSknp2(c *Emulator, a byte, b byte) error {
+	if !c.IsPressed(Keypad2 + int(c.V[a&lsnMask]&lsnMask)) {
+		c.skip()
+	}
+
+	return nil
+}
+
+func opOut(c *Emulator, a byte, b byte) error {
+	if c.Port != nil {
+		c.Port.Out(c.V[a&lsnMask])
+	}
+
+	return nil
+}
+
+func opIn(c *Emulator, a byte, b byte) error {
+	return c.portIn(a & lsnMask)
+}
+
+func opAudio(c *Emulator, a byte, b byte) error {
+	pattern, err := c.load(int(c.I), len(c.Pattern))
+	if err != nil {
+		return err
+	}
+
+	copy(c.Pattern[:], pattern)
+	return nil
+}
+
+// registerRange returns the registers Vx to Vy, in the order used by
+// the XO-CHIP save and load instructions (descending if x > y).
+func registerRange(x, y byte) []byte {
+	var regs []byte
+
+	for i := int(x); ; {
+		regs = append(regs, byte(i))
+		if i == int(y) {
+			return regs
+		}
+
+		if x < y {
+			i++
+		} else {
+			i--
+		}
+	}
+}
+
+func opSave(c *Emulator, a byte, b byte) error {
+	regs := registerRange(a&lsnMask, b&msnMask>>4)
+	data := make([]byte, len(regs))
+
+	for i, r := range regs {
+		data[i] = c.V[r]
+	}
+
+	return c.store(int(c.I), data...)
+}
+
+func opLoad(c *Emulator, a byte, b byte) error {
+	regs := registerRange(a&lsnMask, b&msnMask>>4)
+
+	data, err := c.load(int(c.I), len(regs))
+	if err != nil {
+		return err
+	}
+
+	for i, r := range regs {
+		c.V[r] = data[i]
+	}
+
+	return nil
+}
+
+func opPitch(c *Emulator, a byte, b byte) error {
+	c.Pitch = c.V[a&lsnMask]
+	return nil
+}
+
+func opLdIWord(c *Emulator, a byte, b byte) error {
+	word, err := c.load(int(c.PC), 2)
+	if err != nil {
+		return err
+	}
+
+	c.I = uint16(word[0])<<8 | uint16(word[1])
+	c.PC += 2
+	return nil
+}
+
+func opPlane(c *Emulator, a byte, b byte) error {
+	c.Planes = a & 0x03
+	return nil
+}
+
+// portIn reads a byte from the I/O port into Vx, halting the emulator
+// if there is no data available.
+func (c *Emulator) portIn(x byte) error {
+	if c.Port != nil {
+		if value, ok := c.Port.In(); ok {
+			c.V[x] = value
+			return nil
+		}
 	}
 
-	return NoOpError{A: a, B: b}
+	c.PC -= 2
+	return ErrInputHalt
 }
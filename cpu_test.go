@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"image/color"
+	"strings"
 	"testing"
 
 	"github.com/ibraimgm/chip8"
@@ -120,6 +122,78 @@ func TestOpCls(t *testing.T) {
 	}
 }
 
+func TestOpClsETI660(t *testing.T) {
+	c := chip8.Emulator{Machine: chip8.ETI660}
+	if err := c.LoadROM(bytes.NewReader([]byte{0x00, 0xE0})); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.SetDisplayMode(chip8.DisplayMode{Width: 64, Height: 64}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 512; i++ {
+		c.Memory[chip8.ETI660.VideoAddr+i] = 0xFF
+	}
+
+	if _, err := c.Execute(1); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 512; i++ {
+		addr := chip8.ETI660.VideoAddr + i
+		if c.Memory[addr] != 0 {
+			t.Fatalf("video memory at address 0x%03X should be zero, but was 0x%02X", addr, c.Memory[addr])
+		}
+	}
+
+	if c.Memory[chip8.ETI660.SpriteAddr] != 0xF0 {
+		t.Fatal("clearing the screen should not touch the sprite data")
+	}
+}
+
+func TestOpClsHiRes(t *testing.T) {
+	tests := []struct {
+		name    string
+		machine *chip8.Machine
+		cleared bool
+	}{
+		{name: "HiRes", machine: chip8.HiResVIP, cleared: true},
+		{name: "VIP", machine: chip8.COSMACVIP, cleared: false},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			c := chip8.NewEmulator(test.machine)
+			if err := c.LoadROM(bytes.NewReader([]byte{0x02, 0x30})); err != nil {
+				t.Fatal(err)
+			}
+
+			size := c.Display.VideoSize()
+			for i := 0; i < size; i++ {
+				c.Memory[test.machine.VideoAddr+i] = 0xFF
+			}
+
+			if _, err := c.Execute(1); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < size; i++ {
+				addr := test.machine.VideoAddr + i
+				if test.cleared && c.Memory[addr] != 0 {
+					t.Fatalf("video memory at address 0x%03X should be zero, but was 0x%02X", addr, c.Memory[addr])
+				}
+
+				if !test.cleared && c.Memory[addr] != 0xFF {
+					t.Fatalf("video memory at address 0x%03X should not be cleared", addr)
+				}
+			}
+		})
+	}
+}
+
 func TestOpLdValue(t *testing.T) {
 	tests := []byte{2, 4, 6, 8, 10, 12, 14, 16, 18, 20, 22, 24, 26, 28, 30, 32}
 	rom := make([]byte, 0, len(tests)*2)
@@ -175,17 +249,18 @@ func TestOpLdVx(t *testing.T) {
 }
 
 func TestOpJP(t *testing.T) {
-	t.SkipNow()
-
 	tests := []struct {
 		name  string
 		rom   []byte
 		vx    []byte
+		pc    uint16
 		isErr bool
 	}{
 		{name: "JP", rom: []byte{0x60, 0x01, 0x12, 0x06, 0x61, 0x01, 0x62, 0x01}, vx: []byte{1, 0, 1}},
+		{name: "JP End", rom: []byte{0x1F, 0xFE}, pc: 0xFFE},
 		{name: "JP Error", rom: []byte{0x1F, 0xFF}, isErr: true},
-		{name: "JP+", rom: []byte{0x60, 0x04, 0xB2, 0x02, 0x61, 0x01, 0x61, 0x01}, vx: []byte{1, 0, 1}},
+		{name: "JP+", rom: []byte{0x60, 0x04, 0xB2, 0x02, 0x61, 0x01, 0x62, 0x01}, vx: []byte{4, 0, 1}},
+		{name: "JP+ Far", rom: []byte{0x60, 0x10, 0xB3, 0x00}, pc: 0x310},
 		{name: "JP+ Error", rom: []byte{0x60, 0xAA, 0xBF, 0xF0, 0x61, 0x01, 0x61, 0x01}, isErr: true},
 	}
 
@@ -216,13 +291,15 @@ func TestOpJP(t *testing.T) {
 					t.Fatalf("expected register V%X to be 0x%02X but was 0x%02X", i, value, c.V[i])
 				}
 			}
+
+			if test.pc != 0 && c.PC != test.pc {
+				t.Fatalf("expected jump to 0x%03X, but PC is 0x%03X", test.pc, c.PC)
+			}
 		})
 	}
 }
 
 func TestOpCallRet(t *testing.T) {
-	t.SkipNow()
-
 	rom := []byte{
 		0x12, 0x08, // JP 0x208 (skip the next 3 lines)
 		0x61, 0x01, // V1 = 1
@@ -247,8 +324,6 @@ func TestOpCallRet(t *testing.T) {
 }
 
 func TestStackOverflow(t *testing.T) {
-	t.SkipNow()
-
 	_, err := runEmulator([]byte{0x22, 0x00}) // call self (inf. loop)
 	if !errors.Is(err, chip8.ErrStackOverflow) {
 		t.Fatalf("expected stack overflow error, but got %v", err)
@@ -465,6 +540,72 @@ func TestOpDraw(t *testing.T) {
 	}
 }
 
+func TestOpDrawQuirks(t *testing.T) {
+	wrapping := *chip8.COSMACVIP
+	wrapping.Quirks.ClipSprites = false
+
+	tests := []struct {
+		name    string
+		machine *chip8.Machine
+		x, y    byte
+		pixels  [][2]int
+		count   int
+	}{
+		{name: "Inside", machine: chip8.COSMACVIP, x: 10, y: 5, pixels: [][2]int{{10, 5}, {17, 5}, {10, 6}}, count: 16},
+		{name: "Wrapped start", machine: chip8.COSMACVIP, x: 74, y: 37, pixels: [][2]int{{10, 5}, {17, 5}}, count: 16},
+		{name: "Clipped", machine: chip8.COSMACVIP, x: 60, y: 31, pixels: [][2]int{{60, 31}, {63, 31}}, count: 4},
+		{name: "Wrapped", machine: &wrapping, x: 60, y: 31, pixels: [][2]int{{60, 31}, {0, 31}, {60, 0}, {3, 0}}, count: 16},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			c := chip8.NewEmulator(test.machine)
+			copy(c.Memory[0x300:], []byte{0xFF, 0xFF})
+			copy(c.Memory[c.PC:], []byte{0xD0, 0x12, 0xD0, 0x12})
+			c.I = 0x300
+			c.V[0], c.V[1] = test.x, test.y
+
+			if _, err := c.Execute(1); err != nil {
+				t.Fatal(err)
+			}
+
+			set := 0
+			for y := 0; y < c.Display.Height; y++ {
+				for x := 0; x < c.Display.Width; x++ {
+					if c.Pixel(x, y) {
+						set++
+					}
+				}
+			}
+
+			if set != test.count {
+				t.Fatalf("expected %d pixels to be set, but found %d", test.count, set)
+			}
+
+			for _, p := range test.pixels {
+				if !c.Pixel(p[0], p[1]) {
+					t.Fatalf("expected pixel (%d, %d) to be set", p[0], p[1])
+				}
+			}
+
+			if c.V[0xF] != 0 {
+				t.Fatal("expected no collision on the first draw")
+			}
+
+			// drawing again erases the sprite
+			if _, err := c.Execute(1); err != nil {
+				t.Fatal(err)
+			}
+
+			if c.V[0xF] != 1 || c.Pixel(test.pixels[0][0], test.pixels[0][1]) {
+				t.Fatal("expected the second draw to erase the sprite, with a collision")
+			}
+		})
+	}
+}
+
 func TestOpRand(t *testing.T) {
 	t.SkipNow()
 
@@ -584,9 +725,151 @@ func TestOpInputHalt(t *testing.T) {
 	}
 }
 
-func TestOpLdTimers(t *testing.T) {
-	t.SkipNow()
+func TestOpCHIP8XKeypad(t *testing.T) {
+	rom := []byte{
+		0x65, byte(chip8.KeyA), // V5 = 'A'
+		0xE5, 0x9E, // SKP V5 (first keypad, pressed)
+		0x60, 0x01, // V0 = 1 (skipped)
+		0xE5, 0xA1, // SKNP V5 (first keypad, pressed)
+		0x61, 0x01, // V1 = 1
+		0xE5, 0xF2, // SKP2 V5 (second keypad, not pressed)
+		0x62, 0x01, // V2 = 1
+		0xE5, 0xF5, // SKNP2 V5 (second keypad, not pressed)
+		0x63, 0x01, // V3 = 1 (skipped)
+	}
+
+	c := chip8.NewEmulator(chip8.CHIP8X)
+	if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatal(err)
+	}
+
+	c.PressKey(chip8.KeyA)
+	c.PressKey(chip8.Keypad2 + chip8.KeyB)
+
+	if _, err := c.Execute(7); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, v := range []byte{0, 1, 1, 0} {
+		if c.V[i] != v {
+			t.Fatalf("expected register V%X to be 0x%02X, but was 0x%02X", i, v, c.V[i])
+		}
+	}
+
+	// without the CHIP-8X, the second keypad is not available
+	c = chip8.NewEmulator(nil)
+	if err := c.LoadROM(bytes.NewReader([]byte{0xE5, 0xF2})); err != nil {
+		t.Fatal(err)
+	}
+
+	var noop chip8.NoOpError
+	if _, err := c.Execute(1); !errors.As(err, &noop) {
+		t.Fatalf("expected noop error, but got %v", err)
+	}
+}
+
+func TestOpCHIP8XColors(t *testing.T) {
+	rom := []byte{
+		0x02, 0xA0, // cycle background (black)
+		0x02, 0xA0, // cycle background (green)
+		0x60, 0x12, // V0 = column 2, 2 columns wide
+		0x61, 0x04, // V1 = color 4 (green)
+		0x62, 0x11, // V2 = row 1 (in 4-pixel units), 2 rows high
+		0xB0, 0x20, // COL V0, V2 (zones)
+		0x63, 0x07, // V3 = row 7
+		0x64, 0x00, // V4 = column 0, 1 column wide
+		0x65, 0x06, // V5 = color 6 (aqua)
+		0xB4, 0x32, // COL V4, V3, 2 (rows)
+	}
+
+	c := chip8.NewEmulator(chip8.CHIP8X)
+	if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Execute(len(rom) / 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Background != 2 {
+		t.Fatalf("expected background color 2, but was %d", c.Background)
+	}
+
+	for y := range c.Zones {
+		for x := range c.Zones[y] {
+			expected := byte(1)
+
+			switch {
+			case y >= 4 && y < 12 && x >= 2 && x < 4:
+				expected = 4
+			case y >= 7 && y < 9 && x == 0:
+				expected = 6
+			}
+
+			if c.Zones[y][x] != expected {
+				t.Fatalf("expected zone (%d, %d) to have color %d, but was %d", x, y, expected, c.Zones[y][x])
+			}
+		}
+	}
+}
+
+type testPort struct {
+	out []byte
+	in  []byte
+}
+
+func (p *testPort) Out(value byte) {
+	p.out = append(p.out, value)
+}
 
+func (p *testPort) In() (byte, bool) {
+	if len(p.in) == 0 {
+		return 0, false
+	}
+
+	value := p.in[0]
+	p.in = p.in[1:]
+	return value, true
+}
+
+func TestOpCHIP8XPort(t *testing.T) {
+	rom := []byte{
+		0x60, 0x2A, // V0 = 0x2A
+		0xF0, 0xF8, // OUT V0
+		0xF1, 0xFB, // IN V1
+	}
+
+	port := &testPort{}
+	c := chip8.NewEmulator(chip8.CHIP8X)
+	c.Port = port
+	if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := c.Execute(3)
+	if !errors.Is(err, chip8.ErrInputHalt) {
+		t.Fatalf("expected input halt error, but received %v", err)
+	}
+
+	if n != 2 {
+		t.Fatalf("expected to execute 2 instructions, but executed %d", n)
+	}
+
+	if len(port.out) != 1 || port.out[0] != 0x2A {
+		t.Fatalf("expected 0x2A to be sent to the port, but found %v", port.out)
+	}
+
+	port.in = []byte{0x55}
+	if _, err := c.Execute(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.V[1] != 0x55 {
+		t.Fatalf("expected V1 to be 0x55, but was 0x%02X", c.V[1])
+	}
+}
+
+func TestOpLdTimers(t *testing.T) {
 	const expected = 0xAA
 
 	rom := []byte{
@@ -614,9 +897,57 @@ func TestOpLdTimers(t *testing.T) {
 	}
 }
 
-func TestOpLdSprites(t *testing.T) {
-	t.SkipNow()
+func TestOpAudio(t *testing.T) {
+	pattern := []byte{
+		0x00, 0xFF, 0x0F, 0xF0, 0x33, 0xCC, 0x55, 0xAA,
+		0x01, 0x80, 0x7E, 0x81, 0xFF, 0x00, 0xF0, 0x0F,
+	}
+
+	rom := []byte{
+		0x60, 0x70, // V0 = 112
+		0xF0, 0x3A, // PITCH V0
+		0xF0, 0x02, // AUDIO (load pattern from I)
+	}
+	rom = append(rom, pattern...)
+
+	c := chip8.NewEmulator(chip8.XOCHIP)
+	if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Pitch != chip8.DefaultPatternPitch {
+		t.Fatalf("expected pitch to start at %d, but was %d", chip8.DefaultPatternPitch, c.Pitch)
+	}
+
+	c.I = chip8.AddrStart + 6
+	if _, err := c.Execute(3); err != nil {
+		t.Fatal(err)
+	}
 
+	if c.Pitch != 0x70 {
+		t.Fatalf("expected pitch to be 0x70, but was 0x%02X", c.Pitch)
+	}
+
+	if !bytes.Equal(c.Pattern[:], pattern) {
+		t.Fatalf("expected audio pattern to be %v, but was %v", pattern, c.Pattern)
+	}
+}
+
+func TestOpAudioUnsupported(t *testing.T) {
+	for _, rom := range [][]byte{{0xF0, 0x02}, {0xF0, 0x3A}} {
+		var c chip8.Emulator
+		if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+			t.Fatal(err)
+		}
+
+		var noop chip8.NoOpError
+		if _, err := c.Execute(1); !errors.As(err, &noop) {
+			t.Fatalf("expected 0x%02X%02X to be a noop on COSMAC VIP, but got %v", rom[0], rom[1], err)
+		}
+	}
+}
+
+func TestOpLdSprites(t *testing.T) {
 	const numSprites = 16
 	const spriteSize = 5
 
@@ -638,8 +969,6 @@ func TestOpLdSprites(t *testing.T) {
 }
 
 func TestOpBCD(t *testing.T) {
-	t.SkipNow()
-
 	const baseAddr = 0x202 // location of the first BCD digit
 
 	tests := []struct {
@@ -726,9 +1055,28 @@ func TestOpLdVxI(t *testing.T) {
 	}
 }
 
-func TestWriteViolation(t *testing.T) {
-	t.SkipNow()
+func TestOpStoreIncrement(t *testing.T) {
+	tests := []struct {
+		machine *chip8.Machine
+		i       uint16
+	}{
+		{chip8.COSMACVIP, 0x303},
+		{chip8.SCHIP, 0x300},
+		{chip8.CHIP48, 0x302},
+	}
+
+	for _, test := range tests {
+		// LD I, 0x300; LD [I], V2
+		c := loadMachine(t, test.machine, []byte{0xA3, 0x00, 0xF2, 0x55})
+		execute(t, c, 2)
+
+		if c.I != test.i {
+			t.Errorf("%s: expected I to be 0x%03X, but was 0x%03X", test.machine.ID, test.i, c.I)
+		}
+	}
+}
 
+func TestWriteViolation(t *testing.T) {
 	tests := []struct {
 		name string
 		addr uint16
@@ -766,3 +1114,266 @@ func TestWriteViolation(t *testing.T) {
 		})
 	}
 }
+
+// loadMachine creates an emulator for the machine, with the ROM loaded.
+func loadMachine(t *testing.T, m *chip8.Machine, rom []byte) *chip8.Emulator {
+	t.Helper()
+
+	c := chip8.NewEmulator(m)
+	if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+// execute runs n instructions, failing the test on errors.
+func execute(t *testing.T, c *chip8.Emulator, n int) {
+	t.Helper()
+
+	if _, err := c.Execute(n); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pixels returns the set pixels of the display, as "x,y" strings.
+func pixels(c *chip8.Emulator) []string {
+	var set []string
+	for y := 0; y < c.Display.Height; y++ {
+		for x := 0; x < c.Display.Width; x++ {
+			if c.Pixel(x, y) {
+				set = append(set, fmt.Sprintf("%d,%d", x, y))
+			}
+		}
+	}
+
+	return set
+}
+
+// bigSprite returns the data of a 16x16 sprite with the first row set
+// to the given bits.
+func bigSprite(first byte) []byte {
+	sprite := make([]byte, 32)
+	sprite[0] = first
+	return sprite
+}
+
+func TestOpResolution(t *testing.T) {
+	c := loadMachine(t, chip8.SCHIP, []byte{0x00, 0xFF, 0x00, 0xFE})
+	execute(t, c, 1)
+
+	if c.Display != (chip8.DisplayMode{Width: 128, Height: 64}) {
+		t.Fatalf("expected high resolution, but found %v", c.Display)
+	}
+
+	execute(t, c, 1)
+	if c.Display != (chip8.DisplayMode{Width: 64, Height: 32}) {
+		t.Fatalf("expected low resolution, but found %v", c.Display)
+	}
+
+	// not a SUPER-CHIP instruction
+	c = loadMachine(t, chip8.COSMACVIP, []byte{0x00, 0xFF})
+	if _, err := c.Execute(1); err != nil || c.Display != chip8.COSMACVIP.Modes[0] {
+		t.Fatalf("expected the VIP to ignore 00FF, but got %v with display %v", err, c.Display)
+	}
+}
+
+func TestOpDrawBig(t *testing.T) {
+	rom := []byte{
+		0x00, 0xFF, // HIGH
+		0xD0, 0x00, // DRW V0, V0, 0
+		0xD0, 0x00, // DRW V0, V0, 0
+		0x80, 0x01, 0x80, 0x01, 0x80, 0x01, 0x80, 0x01, 0x80, 0x01, 0x80, 0x01, 0x80, 0x01, 0x80, 0x01,
+		0x80, 0x01, 0x80, 0x01, 0x80, 0x01, 0x80, 0x01, 0x80, 0x01, 0x80, 0x01, 0x80, 0x01, 0x80, 0x01,
+	}
+
+	c := loadMachine(t, chip8.SCHIP, rom)
+	c.I = chip8.AddrStart + 6
+	execute(t, c, 2)
+
+	set := pixels(c)
+	if len(set) != 32 || !c.Pixel(0, 0) || !c.Pixel(15, 15) || c.Pixel(16, 0) || c.V[0xF] != 0 {
+		t.Fatalf("expected two 16 pixels high columns, but found %v (VF=%d)", set, c.V[0xF])
+	}
+
+	execute(t, c, 1)
+	if set := pixels(c); len(set) != 0 || c.V[0xF] != 1 {
+		t.Fatalf("expected the sprite to be erased, but found %v (VF=%d)", set, c.V[0xF])
+	}
+}
+
+func TestOpScroll(t *testing.T) {
+	tests := []struct {
+		name    string
+		machine *chip8.Machine
+		op      []byte
+		pixel   string
+	}{
+		{"Right", chip8.SCHIP, []byte{0x00, 0xFB}, "12,9"},
+		{"Left", chip8.SCHIP, []byte{0x00, 0xFC}, "4,9"},
+		{"Down", chip8.SCHIP, []byte{0x00, 0xC3}, "8,12"},
+		{"Up", chip8.XOCHIP, []byte{0x00, 0xD3}, "8,6"},
+		{"Out", chip8.XOCHIP, []byte{0x00, 0xDF}, ""},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			rom := []byte{
+				0x60, 0x08, // V0 = 8
+				0x61, 0x09, // V1 = 9
+				0xD0, 0x10, // DRW V0, V1, 0
+				test.op[0], test.op[1],
+			}
+			rom = append(rom, bigSprite(0x80)...)
+
+			c := loadMachine(t, test.machine, rom)
+			c.I = chip8.AddrStart + 8
+			execute(t, c, 4)
+
+			if got := strings.Join(pixels(c), " "); got != test.pixel {
+				t.Fatalf("expected pixel at %q, but found %q", test.pixel, got)
+			}
+		})
+	}
+}
+
+func TestOpExit(t *testing.T) {
+	c := loadMachine(t, chip8.SCHIP, []byte{0x00, 0xFD})
+
+	if _, err := c.Execute(1); !errors.Is(err, chip8.ErrStopped) || c.PC != chip8.AddrStart {
+		t.Fatalf("expected the program to stop at 0x%03X, but got %v at 0x%03X", chip8.AddrStart, err, c.PC)
+	}
+}
+
+func TestOpFlags(t *testing.T) {
+	rom := []byte{
+		0x60, 0x11, // V0 = 0x11
+		0x61, 0x22, // V1 = 0x22
+		0x62, 0x33, // V2 = 0x33
+		0xF1, 0x75, // LD R, V1
+		0x60, 0x00, // V0 = 0
+		0x61, 0x00, // V1 = 0
+		0x62, 0x00, // V2 = 0
+		0xF2, 0x85, // LD V2, R
+	}
+
+	c := loadMachine(t, chip8.SCHIP, rom)
+	execute(t, c, len(rom)/2)
+
+	if c.V[0] != 0x11 || c.V[1] != 0x22 || c.V[2] != 0 {
+		t.Fatalf("unexpected registers: V0=0x%02X, V1=0x%02X, V2=0x%02X", c.V[0], c.V[1], c.V[2])
+	}
+
+	// the flags survive a reset
+	c.Reset()
+	if c.Flags[0] != 0x11 || c.Flags[1] != 0x22 {
+		t.Fatalf("expected the flags to be kept, but found %v", c.Flags)
+	}
+}
+
+func TestOpLdIWord(t *testing.T) {
+	rom := []byte{
+		0xF0, 0x00, 0x12, 0x34, // I = 0x1234
+		0xE0, 0xA1, // SKNP V0 (not pressed)
+		0xF0, 0x00, 0x56, 0x78, // I = 0x5678 (skipped)
+		0x60, 0x01, // V0 = 1
+	}
+
+	c := loadMachine(t, chip8.XOCHIP, rom)
+	execute(t, c, 3)
+
+	if c.I != 0x1234 || c.V[0] != 1 || c.PC != chip8.AddrStart+12 {
+		t.Fatalf("unexpected registers: I=0x%04X, V0=%d, PC=0x%03X", c.I, c.V[0], c.PC)
+	}
+}
+
+func TestOpPlane(t *testing.T) {
+	rom := []byte{
+		0xF2, 0x01, // PLANE 2
+		0xD0, 0x00, // DRW V0, V0, 0 (plane 2: 0xC0)
+		0xF3, 0x01, // PLANE 3
+		0x60, 0x04, // V0 = 4
+		0xD0, 0x00, // DRW V0, V0, 0 (plane 1: 0xC0, plane 2: 0x80)
+		0xF1, 0x01, // PLANE 1
+		0x00, 0xE0, // CLS
+	}
+	rom = append(rom, bigSprite(0xC0)...)
+	rom = append(rom, bigSprite(0x80)...)
+
+	c := loadMachine(t, chip8.XOCHIP, rom)
+	c.I = chip8.AddrStart + 14
+	execute(t, c, 6)
+
+	palette := chip8.DefaultPalette
+
+	tests := []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{0, 0, palette.Foreground2},
+		{1, 0, palette.Foreground2},
+		{2, 0, palette.Background},
+		{4, 4, palette.Blend},
+		{5, 4, palette.Foreground},
+		{6, 4, palette.Background},
+	}
+
+	for _, test := range tests {
+		if got := c.PixelColor(test.x, test.y); got != test.want {
+			t.Errorf("expected color %v at %d,%d, but found %v", test.want, test.x, test.y, got)
+		}
+	}
+
+	// clearing the screen only clears the selected planes
+	execute(t, c, 1)
+
+	if got := c.PixelColor(4, 4); got != palette.Foreground2 {
+		t.Fatalf("expected only the second plane to be left, but found %v", got)
+	}
+
+	if c.Pixel(5, 4) {
+		t.Fatal("expected the first plane to be cleared")
+	}
+}
+
+func TestOpSaveLoad(t *testing.T) {
+	rom := []byte{
+		0x61, 0x11, // V1 = 0x11
+		0x62, 0x22, // V2 = 0x22
+		0x63, 0x33, // V3 = 0x33
+		0xA3, 0x00, // I = 0x300
+		0x51, 0x32, // SAVE V1, V3
+		0xA3, 0x10, // I = 0x310
+		0x53, 0x12, // SAVE V3, V1
+		0xA3, 0x00, // I = 0x300
+		0x54, 0x63, // LOAD V4, V6
+	}
+
+	c := chip8.NewEmulator(chip8.XOCHIP)
+	if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Execute(len(rom) / 2); err != nil {
+		t.Fatal(err)
+	}
+
+	for addr, value := range map[int]byte{0x300: 0x11, 0x301: 0x22, 0x302: 0x33, 0x310: 0x33, 0x311: 0x22, 0x312: 0x11} {
+		if c.Memory[addr] != value {
+			t.Fatalf("expected 0x%02X at 0x%03X, but got 0x%02X", value, addr, c.Memory[addr])
+		}
+	}
+
+	if c.V[4] != 0x11 || c.V[5] != 0x22 || c.V[6] != 0x33 || c.I != 0x300 {
+		t.Fatalf("unexpected registers: V4=0x%02X, V5=0x%02X, V6=0x%02X, I=0x%03X", c.V[4], c.V[5], c.V[6], c.I)
+	}
+
+	// the interpreter area is protected
+	c.PC = chip8.AddrStart + 8
+	c.I = 0x100
+	if _, err := c.Execute(1); !errors.Is(err, chip8.ErrMemWrite) {
+		t.Fatalf("expected memory write error, but got %v", err)
+	}
+}
@@ -0,0 +1,322 @@
+package chip8
+
+import (
+	"bytes"
+	"crypto/sha1"
+	_ "embed" // embedded ROM database
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// ROMInfo is the metadata of a known ROM: what it is, and how to run it.
+type ROMInfo struct {
+	SHA1        string   // hash of the ROM, in lowercase hexadecimal
+	Title       string   // title of the program
+	Description string   // description of the program or of the ROM
+	Release     string   // release date or year
+	Authors     []string // authors of the program
+	File        string   // usual file name of the ROM
+
+	// Platforms are the IDs of the chip-8-database platforms where the
+	// ROM runs (ex: "originalChip8", "superchip", "xochip"), from the
+	// most to the least recommended.
+	Platforms []string
+
+	// Quirks overrides the quirks of some platforms, by platform ID and
+	// quirk name (ex: "shift", "memoryLeaveIUnchanged",
+	// "memoryIncrementByX", "wrap", "jump", "vblank", "logic").
+	Quirks map[string]map[string]bool
+
+	Tickrate  int      // instructions per frame (0 if unknown)
+	StartAddr int      // load address (0 for the platform default)
+	FontStyle string   // font of the ROM (ex: "vip", "octo")
+	Colors    []string // colors of the pixels ("#RRGGBB"), background first
+}
+
+// the format of the programs.json file of the chip-8-database
+type dbProgram struct {
+	Title       string           `json:"title"`
+	Description string           `json:"description"`
+	Release     string           `json:"release"`
+	Authors     []string         `json:"authors"`
+	ROMs        map[string]dbROM `json:"roms"`
+}
+
+type dbROM struct {
+	File            string                     `json:"file"`
+	Description     string                     `json:"description"`
+	Platforms       []string                   `json:"platforms"`
+	QuirkyPlatforms map[string]map[string]bool `json:"quirkyPlatforms"`
+	Tickrate        int                        `json:"tickrate"`
+	StartAddress    int                        `json:"startAddress"`
+	FontStyle       string                     `json:"fontStyle"`
+	Colors          struct {
+		Pixels []string `json:"pixels"`
+	} `json:"colors"`
+}
+
+// the machines of the platforms of the chip-8-database
+var dbPlatforms = map[string]*Machine{
+	"originalChip8": COSMACVIP,
+	"hybridVIP":     COSMACVIP,
+	"modernChip8":   modernCHIP8,
+	"chip8x":        CHIP8X,
+	"chip48":        CHIP48,
+	"superchip1":    SCHIP,
+	"superchip":     SCHIP,
+	"megachip8":     MEGACHIP,
+	"xochip":        XOCHIP,
+}
+
+// modernCHIP8 is the CHIP-8 as implemented by most modern interpreters:
+// the memory of the VIP, without its quirks.
+var modernCHIP8 = func() *Machine {
+	m := *COSMACVIP
+	m.Quirks = Quirks{ClipSprites: true}
+	m.Timing = nil
+	return &m
+}()
+
+// the fonts of the font styles of the chip-8-database
+var dbFonts = map[string]*Font{
+	"vip":       VIPFont,
+	"dream6800": DREAMFont,
+	"eti660":    ETI660Font,
+	"schip":     SCHIPFont,
+	"fish":      FishNChipsFont,
+	"octo":      XOCHIPFont,
+}
+
+// Database is a collection of known ROMs, indexed by their SHA-1 hash.
+type Database struct {
+	roms map[string]*ROMInfo
+}
+
+//go:embed database/programs.json
+var embeddedDatabase []byte
+
+var defaultDatabase struct {
+	once sync.Once
+	db   *Database
+}
+
+// DefaultDatabase returns the built-in ROM database, embedded from
+// database/programs.json, a copy of the programs.json file of the
+// chip-8-database project. The copy in the repository is empty until it
+// is updated from a chip-8-database release; a full database can also be
+// merged on it at run time, with ReadDatabase and Merge.
+func DefaultDatabase() *Database {
+	defaultDatabase.once.Do(func() {
+		db, err := ReadDatabase(bytes.NewReader(embeddedDatabase))
+		if err != nil {
+			panic(err)
+		}
+
+		defaultDatabase.db = db
+	})
+
+	return defaultDatabase.db
+}
+
+// ReadDatabase reads a ROM database in the format of the programs.json
+// file of the chip-8-database project.
+func ReadDatabase(r io.Reader) (*Database, error) {
+	var programs []dbProgram
+	if err := json.NewDecoder(r).Decode(&programs); err != nil {
+		return nil, fmt.Errorf("error reading ROM database: %w", err)
+	}
+
+	db := &Database{roms: map[string]*ROMInfo{}}
+	for _, p := range programs {
+		for sum, rom := range p.ROMs {
+			description := rom.Description
+			if description == "" {
+				description = p.Description
+			}
+
+			db.Add(&ROMInfo{
+				SHA1:        sum,
+				Title:       p.Title,
+				Description: description,
+				Release:     p.Release,
+				Authors:     p.Authors,
+				File:        rom.File,
+				Platforms:   rom.Platforms,
+				Quirks:      rom.QuirkyPlatforms,
+				Tickrate:    rom.Tickrate,
+				StartAddr:   rom.StartAddress,
+				FontStyle:   rom.FontStyle,
+				Colors:      rom.Colors.Pixels,
+			})
+		}
+	}
+
+	return db, nil
+}
+
+// Add adds the ROM to the database, replacing any ROM with the same hash.
+func (db *Database) Add(info *ROMInfo) {
+	if db.roms == nil {
+		db.roms = map[string]*ROMInfo{}
+	}
+
+	info.SHA1 = strings.ToLower(info.SHA1)
+	db.roms[info.SHA1] = info
+}
+
+// Merge returns a new database with the ROMs of both databases. The ROMs
+// of other take precedence, so it can hold the overrides of the user.
+func (db *Database) Merge(other *Database) *Database {
+	merged := &Database{roms: make(map[string]*ROMInfo, len(db.roms)+len(other.roms))}
+	for sum, info := range db.roms {
+		merged.roms[sum] = info
+	}
+
+	for sum, info := range other.roms {
+		merged.roms[sum] = info
+	}
+
+	return merged
+}
+
+// Len returns the number of ROMs in the database.
+func (db *Database) Len() int {
+	return len(db.roms)
+}
+
+// Lookup returns the metadata of the ROM, or nil if it is unknown.
+func (db *Database) Lookup(rom []byte) *ROMInfo {
+	sum := sha1.Sum(rom)
+	return db.LookupSHA1(hex.EncodeToString(sum[:]))
+}
+
+// LookupSHA1 returns the metadata of the ROM with the given SHA-1 hash
+// (in hexadecimal), or nil if it is unknown.
+func (db *Database) LookupSHA1(sum string) *ROMInfo {
+	return db.roms[strings.ToLower(sum)]
+}
+
+// LoadROM reads the ROM from r and, if it is on the database, configures
+// the emulator for it (see ROMInfo.Machine and ROMInfo.Palette) before
+// loading it. It returns the metadata of the ROM, or nil if it is unknown.
+func (db *Database) LoadROM(c *Emulator, r io.Reader) (*ROMInfo, error) {
+	rom, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	info := db.Lookup(rom)
+	if info != nil {
+		if m := info.Machine(); m != nil {
+			c.Machine = m
+		}
+
+		if p := info.Palette(); p != nil {
+			c.Palette = p
+		}
+
+		c.Reset()
+	}
+
+	return info, c.LoadROM(bytes.NewReader(rom))
+}
+
+// Platform returns the ID of the first platform of the ROM known by the
+// emulator, or an empty string if there is none.
+func (info *ROMInfo) Platform() string {
+	for _, id := range info.Platforms {
+		if dbPlatforms[id] != nil {
+			return id
+		}
+	}
+
+	return ""
+}
+
+// Machine returns the machine recommended to run the ROM, with its
+// quirks, font and start address, or nil if the emulator knows none of
+// the platforms of the ROM.
+func (info *ROMInfo) Machine() *Machine {
+	id := info.Platform()
+	if id == "" {
+		return nil
+	}
+
+	m := *dbPlatforms[id]
+	if quirks, ok := info.Quirks[id]; ok {
+		m.Quirks.apply(quirks)
+	}
+
+	if f := dbFonts[info.FontStyle]; f != nil {
+		m.Font = f
+	}
+
+	if info.StartAddr > 0 && info.StartAddr < m.MemorySize {
+		m.StartAddr = info.StartAddr
+	}
+
+	return &m
+}
+
+// Palette returns the palette of the ROM, or nil if it has no colors.
+func (info *ROMInfo) Palette() *Palette {
+	if len(info.Colors) < 2 {
+		return nil
+	}
+
+	bg, err := parseColor(info.Colors[0])
+	if err != nil {
+		return nil
+	}
+
+	fg, err := parseColor(info.Colors[1])
+	if err != nil {
+		return nil
+	}
+
+	p := &Palette{Background: bg, Foreground: fg}
+	if len(info.Colors) >= 4 {
+		p.Foreground2, _ = parseColor(info.Colors[2])
+		p.Blend, _ = parseColor(info.Colors[3])
+	}
+
+	return p
+}
+
+// apply changes the quirks named as in the chip-8-database.
+func (q *Quirks) apply(quirks map[string]bool) {
+	for name, on := range quirks {
+		switch name {
+		case "shift":
+			q.ShiftVy = !on
+		case "memoryLeaveIUnchanged":
+			q.LoadStoreI = !on
+		case "memoryIncrementByX":
+			q.LoadStoreX = on
+		case "wrap":
+			q.ClipSprites = !on
+		case "jump":
+			q.JumpVx = on
+		case "vblank":
+			q.WaitVBlank = on
+		case "logic":
+			q.ResetVF = on
+		}
+	}
+}
+
+// parseColor parses a "#RRGGBB" color.
+func parseColor(s string) (color.RGBA, error) {
+	rgb, err := hex.DecodeString(strings.TrimPrefix(s, "#"))
+	if err != nil || len(rgb) != 3 {
+		return color.RGBA{}, fmt.Errorf("invalid color: %q", s)
+	}
+
+	return color.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 0xFF}, nil
+}
@@ -0,0 +1,192 @@
+package chip8_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+// databaseROM clears the screen and loops forever.
+var databaseROM = []byte{0x00, 0xE0, 0x12, 0x02}
+
+func sha1Hex(rom []byte) string {
+	sum := sha1.Sum(rom)
+	return hex.EncodeToString(sum[:])
+}
+
+// testDatabase returns a database, in the chip-8-database format, with
+// databaseROM on the given platform.
+func testDatabase(t *testing.T, title string, platform string) *chip8.Database {
+	json := fmt.Sprintf(`[{
+		"title": %q,
+		"authors": ["Someone"],
+		"release": "2021",
+		"roms": {
+			%q: {
+				"file": "test.ch8",
+				"platforms": [%q, "originalChip8"],
+				"quirkyPlatforms": {
+					"superchip": {"shift": false, "wrap": true, "logic": true, "memoryIncrementByX": true},
+					"chip48": {"memoryIncrementByX": false}
+				},
+				"tickrate": 30,
+				"fontStyle": "octo",
+				"colors": {"pixels": ["#102030", "#A0B0C0"]}
+			}
+		}
+	}]`, title, strings.ToUpper(sha1Hex(databaseROM)), platform)
+
+	db, err := chip8.ReadDatabase(strings.NewReader(json))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestDefaultDatabase(t *testing.T) {
+	db := chip8.DefaultDatabase()
+	if db == nil {
+		t.Fatal("expected the built-in database")
+	}
+
+	data, err := ioutil.ReadFile("database/programs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var programs []struct {
+		Title string                     `json:"title"`
+		ROMs  map[string]json.RawMessage `json:"roms"`
+	}
+
+	if err := json.Unmarshal(data, &programs); err != nil {
+		t.Fatal(err)
+	}
+
+	// every ROM of the embedded file must be found by its hash
+	count := 0
+	for _, p := range programs {
+		for sum := range p.ROMs {
+			if _, err := hex.DecodeString(sum); err != nil || len(sum) != 40 {
+				t.Errorf("%s: invalid SHA-1 %q", p.Title, sum)
+			}
+
+			if info := db.LookupSHA1(sum); info == nil || info.Title != p.Title {
+				t.Errorf("%s: expected to find %s, but got %+v", p.Title, sum, info)
+			}
+
+			count++
+		}
+	}
+
+	if db.Len() != count {
+		t.Fatalf("expected %d ROMs, but got %d", count, db.Len())
+	}
+}
+
+func TestReadDatabase(t *testing.T) {
+	db := testDatabase(t, "Test", "superchip")
+	if db.Len() != 1 {
+		t.Fatalf("expected one ROM, but got %d", db.Len())
+	}
+
+	info := db.Lookup(databaseROM)
+	if info == nil || info.Title != "Test" || info.File != "test.ch8" || info.Tickrate != 30 || info.SHA1 != sha1Hex(databaseROM) {
+		t.Fatalf("unexpected ROM info: %+v", info)
+	}
+
+	if db.Lookup([]byte{0x00, 0xE0}) != nil {
+		t.Fatal("expected an unknown ROM")
+	}
+
+	if _, err := chip8.ReadDatabase(strings.NewReader(`{"title": "not a list"}`)); err == nil {
+		t.Fatal("expected an error on an invalid database")
+	}
+}
+
+func TestDatabaseMerge(t *testing.T) {
+	db := testDatabase(t, "Original", "superchip")
+	user := testDatabase(t, "Override", "xochip")
+
+	merged := db.Merge(user)
+	if info := merged.LookupSHA1(sha1Hex(databaseROM)); info == nil || info.Title != "Override" {
+		t.Fatalf("expected the override of the user, but got %+v", info)
+	}
+
+	if info := db.Lookup(databaseROM); info.Title != "Original" {
+		t.Fatal("expected the original database to be unchanged")
+	}
+}
+
+func TestROMInfoMachine(t *testing.T) {
+	tests := []struct {
+		platform string
+		machine  *chip8.Machine
+		quirks   chip8.Quirks
+	}{
+		{"superchip", chip8.SCHIP, chip8.Quirks{ShiftVy: true, LoadStoreX: true, JumpVx: true, ResetVF: true}},
+		{"chip48", chip8.CHIP48, chip8.Quirks{JumpVx: true, ClipSprites: true}},
+		{"xochip", chip8.XOCHIP, chip8.XOCHIP.Quirks},
+		{"unknownPlatform", chip8.COSMACVIP, chip8.COSMACVIP.Quirks},
+	}
+
+	for _, test := range tests {
+		t.Run(test.platform, func(t *testing.T) {
+			info := testDatabase(t, "Test", test.platform).Lookup(databaseROM)
+
+			m := info.Machine()
+			if m.ID != test.machine.ID || m.Quirks != test.quirks {
+				t.Fatalf("expected %s with quirks %+v, but got %s with %+v", test.machine.ID, test.quirks, m.ID, m.Quirks)
+			}
+
+			if m.Font != chip8.XOCHIPFont {
+				t.Fatalf("expected the octo font, but got %s", m.Font.ID)
+			}
+		})
+	}
+
+	if m := (&chip8.ROMInfo{Platforms: []string{"unknownPlatform"}}).Machine(); m != nil {
+		t.Fatalf("expected no machine, but got %s", m.ID)
+	}
+}
+
+func TestDatabaseLoadROM(t *testing.T) {
+	db := testDatabase(t, "Test", "xochip")
+
+	c := chip8.NewEmulator(nil)
+	info, err := db.LoadROM(c, bytes.NewReader(databaseROM))
+	if err != nil || info == nil {
+		t.Fatalf("expected a known ROM, but got %v (%v)", info, err)
+	}
+
+	if c.Machine.ID != chip8.XOCHIP.ID || len(c.Memory) < chip8.XOCHIP.MemorySize {
+		t.Fatalf("expected the emulator to be configured as XO-CHIP, but got %v", c.Machine)
+	}
+
+	expected := chip8.Palette{
+		Background: color.RGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xFF},
+		Foreground: color.RGBA{R: 0xA0, G: 0xB0, B: 0xC0, A: 0xFF},
+	}
+	if c.Palette == nil || *c.Palette != expected {
+		t.Fatalf("expected palette %v, but got %v", expected, c.Palette)
+	}
+
+	if c.Memory[chip8.AddrStart] != 0x00 || c.Memory[chip8.AddrStart+1] != 0xE0 {
+		t.Fatal("expected the ROM to be loaded")
+	}
+
+	// unknown ROMs are loaded as they are
+	c = chip8.NewEmulator(nil)
+	if info, err := db.LoadROM(c, bytes.NewReader([]byte{0x12, 0x00})); info != nil || err != nil || c.Machine != nil {
+		t.Fatalf("expected an unknown ROM, but got %v (%v)", info, err)
+	}
+}
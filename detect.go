@@ -0,0 +1,280 @@
+package chip8
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Detection is the platform suggested by DetectPlatform for a ROM.
+type Detection struct {
+	Machine    *Machine // suggested machine, with the detected quirks
+	Confidence float64  // from 0 (a guess) to 1 (almost certain)
+	Evidence   []string // the opcodes and patterns that led to the machine
+}
+
+// signature is an opcode specific to a platform.
+type signature struct {
+	mask, pattern uint16
+	name          string
+}
+
+func (s signature) matches(op uint16) bool {
+	return op&s.mask == s.pattern
+}
+
+// platformSignatures are the opcodes that identify each platform, from
+// the most to the least specific platform; the platforms later on the
+// list are subsets of (or conflict with) the ones before them.
+var platformSignatures = []struct {
+	machine    *Machine
+	signatures []signature
+}{
+	{MEGACHIP, []signature{
+		{0xFFFF, 0x0011, "0011 (MegaChip mode)"},
+	}},
+	{XOCHIP, []signature{
+		{0xFFFF, 0xF000, "F000 (long I)"},
+		{0xF00F, 0x5002, "5xy2 (save range)"},
+		{0xF00F, 0x5003, "5xy3 (load range)"},
+		{0xF0FF, 0xF001, "Fn01 (plane)"},
+		{0xFFFF, 0xF002, "F002 (audio)"},
+		{0xF0FF, 0xF03A, "Fx3A (pitch)"},
+		{0xFFF0, 0x00D0, "00Dn (scroll up)"},
+	}},
+	{SCHIP, []signature{
+		{0xFFFF, 0x00FF, "00FF (high resolution)"},
+		{0xFFFF, 0x00FE, "00FE (low resolution)"},
+		{0xFFFF, 0x00FB, "00FB (scroll right)"},
+		{0xFFFF, 0x00FC, "00FC (scroll left)"},
+		{0xFFFF, 0x00FD, "00FD (exit)"},
+		{0xFFF0, 0x00C0, "00Cn (scroll down)"},
+		{0xF00F, 0xD000, "Dxy0 (16x16 sprite)"},
+		{0xF0FF, 0xF030, "Fx30 (big font)"},
+		{0xF0FF, 0xF075, "Fx75 (save flags)"},
+		{0xF0FF, 0xF085, "Fx85 (load flags)"},
+	}},
+	{CHIP8X, []signature{
+		{0xFFFF, 0x02A0, "02A0 (background)"},
+		{0xF0FF, 0xE0F2, "ExF2 (second keypad)"},
+		{0xF0FF, 0xE0F5, "ExF5 (second keypad)"},
+		{0xF0FF, 0xF0F8, "FxF8 (output)"},
+		{0xF0FF, 0xF0FB, "FxFB (input)"},
+	}},
+	{HiResVIP, []signature{
+		{0xFFFF, 0x0230, "0230 (hi-res clear)"},
+	}},
+}
+
+// isCHIP8 reports whether the opcode is one of the original CHIP-8.
+func isCHIP8(op uint16) bool {
+	switch op >> 12 {
+	case 0x0:
+		return true // 00E0, 00EE and machine code routines
+	case 0x5, 0x9:
+		return op&0x000F == 0
+	case 0x8:
+		switch op & 0x000F {
+		case 0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0xE:
+			return true
+		}
+		return false
+	case 0xE:
+		return op&0x00FF == 0x9E || op&0x00FF == 0xA1
+	case 0xF:
+		switch op & 0x00FF {
+		case 0x07, 0x0A, 0x15, 0x18, 0x1E, 0x29, 0x33, 0x55, 0x65:
+			return true
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// traceCode returns the offsets of the ROM reached by following the
+// jumps, calls and skips of the program from its start, in order, for a
+// ROM loaded at the given address. Computed jumps (Bnnn) end the trace,
+// since their target is unknown.
+func traceCode(rom []byte, start int) []int {
+	visited := make([]bool, len(rom))
+	pending := []int{0}
+	var code []int
+
+	for len(pending) > 0 {
+		pc := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		for pc >= 0 && pc+1 < len(rom) && !visited[pc] {
+			visited[pc] = true
+			code = append(code, pc)
+
+			op := uint16(rom[pc])<<8 | uint16(rom[pc+1])
+			target := int(op&0x0FFF) - start
+			next := pc + 2
+
+			switch {
+			case op == 0x00EE || op == 0x00FD || op&0xF000 == 0xB000:
+				next = -1
+			case op&0xF000 == 0x1000:
+				next = target
+			case op&0xF000 == 0x2000:
+				pending = append(pending, target)
+			case op == 0xF000:
+				next = pc + 4 // the address after the instruction
+			case op&0xF000 == 0x3000, op&0xF000 == 0x4000, op&0xF00F == 0x5000, op&0xF00F == 0x9000,
+				op&0xF0FF == 0xE09E, op&0xF0FF == 0xE0A1:
+				pending = append(pending, pc+4)
+			}
+
+			pc = next
+		}
+	}
+
+	sort.Ints(code)
+	return code
+}
+
+// tracedCode is the code found on a ROM loaded at a given address.
+type tracedCode struct {
+	code       []int    // offsets of the instructions
+	opcodes    []uint16 // the instructions themselves
+	confidence float64  // how much the code found can be trusted
+}
+
+func newTracedCode(rom []byte, code []int, confidence float64) *tracedCode {
+	t := &tracedCode{code: code, opcodes: make([]uint16, len(code)), confidence: confidence}
+	for i, pc := range code {
+		t.opcodes[i] = uint16(rom[pc])<<8 | uint16(rom[pc+1])
+	}
+
+	return t
+}
+
+// findCode returns the code of the ROM loaded at the start address of
+// each known machine. When no trace finds enough code (ex: programs with
+// computed jumps at the start), every even offset is taken as code
+// instead, with a lower confidence.
+func findCode(rom []byte) map[int]*tracedCode {
+	found := make(map[int]*tracedCode)
+	traced := false
+
+	for _, m := range Machines {
+		if found[m.StartAddr] == nil {
+			code := traceCode(rom, m.StartAddr)
+			found[m.StartAddr] = newTracedCode(rom, code, 0.9)
+			traced = traced || len(code) >= 4
+		}
+	}
+
+	if traced {
+		return found
+	}
+
+	var code []int
+	for pc := 0; pc+1 < len(rom); pc += 2 {
+		code = append(code, pc)
+	}
+
+	t := newTracedCode(rom, code, 0.6)
+	for start := range found {
+		found[start] = t
+	}
+
+	return found
+}
+
+// DetectPlatform analyzes a ROM for which there is no metadata, looking
+// for the opcodes specific to each platform and for the patterns that
+// show the quirks the program relies on. It returns the suggested
+// machine, with a confidence score.
+//
+// The program is traced from the start address of each platform, so
+// data is not mistaken for instructions; when no trace finds enough code
+// (ex: programs with computed jumps at the start), every even offset is
+// checked instead, with a lower confidence.
+func DetectPlatform(rom []byte) Detection {
+	code := findCode(rom)
+	d := detectMachine(rom, code)
+	t := code[d.Machine.StartAddr]
+	detectQuirks(&d, t.code, t.opcodes)
+	return d
+}
+
+// detectMachine finds the most specific platform with opcodes in the
+// program, loaded at the start address of the platform.
+func detectMachine(rom []byte, code map[int]*tracedCode) Detection {
+	for _, platform := range platformSignatures {
+		var evidence []string
+		t := code[platform.machine.StartAddr]
+
+		for _, s := range platform.signatures {
+			for _, op := range t.opcodes {
+				if s.matches(op) {
+					evidence = append(evidence, s.name)
+					break
+				}
+			}
+		}
+
+		if len(evidence) > 0 {
+			// each kind of opcode found makes the guess more likely
+			score := t.confidence - 0.3/float64(len(evidence)+1)
+			m := *platform.machine
+			return Detection{Machine: &m, Confidence: score, Evidence: evidence}
+		}
+	}
+
+	m := *COSMACVIP
+	d := Detection{Machine: &m}
+
+	if len(rom) > m.MemorySize-m.StartAddr {
+		m := *XOCHIP
+		d.Machine = &m
+		d.Confidence = 0.3
+		d.Evidence = []string{fmt.Sprintf("%d bytes do not fit on a 4K machine", len(rom))}
+		return d
+	}
+
+	// without specific opcodes, how much looks like CHIP-8 is the score
+	t := code[m.StartAddr]
+	valid := 0
+	for _, op := range t.opcodes {
+		if isCHIP8(op) {
+			valid++
+		}
+	}
+
+	if len(t.opcodes) > 0 {
+		d.Confidence = t.confidence * 0.7 * float64(valid) / float64(len(t.opcodes))
+	}
+
+	return d
+}
+
+// detectQuirks sets the quirks of the detected machine used by the
+// program.
+func detectQuirks(d *Detection, code []int, opcodes []uint16) {
+	for i, op := range opcodes {
+		x, y := op>>8&0xF, op>>4&0xF
+
+		// shifting Vy into a different Vx only makes sense on the VIP
+		if op&0xF00F == 0x8006 || op&0xF00F == 0x800E {
+			if x != y && !d.Machine.Quirks.ShiftVy {
+				d.Machine.Quirks.ShiftVy = true
+				d.Evidence = append(d.Evidence, fmt.Sprintf("%04X (shift of Vy)", op))
+			}
+		}
+
+		// consecutive loads or stores without setting I expect it to
+		// be incremented
+		if i > 0 && code[i-1]+2 == code[i] && isLoadStore(op) && isLoadStore(opcodes[i-1]) && !d.Machine.Quirks.LoadStoreI {
+			d.Machine.Quirks.LoadStoreI = true
+			d.Evidence = append(d.Evidence, fmt.Sprintf("%04X after %04X (I incremented)", op, opcodes[i-1]))
+		}
+	}
+}
+
+// isLoadStore reports whether the opcode is Fx55 or Fx65.
+func isLoadStore(op uint16) bool {
+	return op&0xF0FF == 0xF055 || op&0xF0FF == 0xF065
+}
@@ -0,0 +1,107 @@
+package chip8_test
+
+import (
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+// program builds a ROM from a list of opcodes.
+func program(opcodes ...uint16) []byte {
+	rom := make([]byte, 0, len(opcodes)*2)
+	for _, op := range opcodes {
+		rom = append(rom, byte(op>>8), byte(op))
+	}
+
+	return rom
+}
+
+func TestDetectPlatform(t *testing.T) {
+	tests := []struct {
+		name    string
+		rom     []byte
+		machine *chip8.Machine
+		min     float64
+	}{
+		{"CHIP8", program(0x00E0, 0x6000, 0x6100, 0xA20E, 0xD015, 0x7001, 0x1208, 0x0000), chip8.COSMACVIP, 0.5},
+		{"SCHIP", program(0x00FF, 0x6000, 0x6100, 0xF030, 0xD01A, 0x7001, 0x1208, 0x0000), chip8.SCHIP, 0.7},
+		{"XOCHIP", program(0x00E0, 0xF000, 0x0300, 0x5012, 0xF201, 0xD015, 0x7001, 0x120A), chip8.XOCHIP, 0.7},
+		{"CHIP8X", program(0x02A0, 0x6000, 0xE0F2, 0x6101, 0xD015, 0x7001, 0x1208, 0x0000), chip8.CHIP8X, 0.7},
+		{"HiRes", program(0x12C4, 0x0000, 0x0230, 0x6000, 0xD015, 0x7001, 0x12C8, 0x0000), chip8.HiResVIP, 0.7},
+		{"MegaChip", program(0x0011, 0x6000, 0x6100, 0xD015, 0x7001, 0x1206, 0x0000, 0x0000), chip8.MEGACHIP, 0.7},
+		{"Large", make([]byte, 4000), chip8.XOCHIP, 0.2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := chip8.DetectPlatform(test.rom)
+			if d.Machine.ID != test.machine.ID {
+				t.Fatalf("expected %s, but got %s (%v)", test.machine.ID, d.Machine.ID, d.Evidence)
+			}
+
+			if d.Confidence < test.min || d.Confidence > 1 {
+				t.Fatalf("expected confidence of at least %.2f, but got %.2f", test.min, d.Confidence)
+			}
+		})
+	}
+}
+
+func TestDetectPlatformData(t *testing.T) {
+	// the SCHIP opcode is on data after the end of the program, so it
+	// is not taken as an instruction
+	rom := program(0x00E0, 0x6000, 0x6100, 0xA210, 0xD015, 0x7001, 0x7101, 0x1208, 0x00FF)
+	if d := chip8.DetectPlatform(rom); d.Machine.ID != chip8.COSMACVIP.ID {
+		t.Fatalf("expected COSMAC VIP, but got %s (%v)", d.Machine.ID, d.Evidence)
+	}
+}
+
+func TestDetectPlatformStartAddr(t *testing.T) {
+	tests := []struct {
+		name    string
+		rom     []byte
+		machine *chip8.Machine
+	}{
+		// the jumps only reach the platform opcode (and skip the data)
+		// when the ROM is loaded at 0x300 or 0x2C0
+		{"CHIP8X", program(0x6000, 0x6100, 0x1308, 0x00FF, 0xE0F2, 0xD015, 0x7001, 0x130A), chip8.CHIP8X},
+		{"HiRes", program(0x22C8, 0x6000, 0xD015, 0x12C2, 0x00FF, 0x0230, 0x6100, 0x00EE), chip8.HiResVIP},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := chip8.DetectPlatform(test.rom)
+			if d.Machine.ID != test.machine.ID {
+				t.Fatalf("expected %s, but got %s (%v)", test.machine.ID, d.Machine.ID, d.Evidence)
+			}
+
+			if d.Confidence < 0.7 {
+				t.Fatalf("expected confidence of at least 0.70, but got %.2f", d.Confidence)
+			}
+		})
+	}
+}
+
+func TestDetectQuirks(t *testing.T) {
+	tests := []struct {
+		name   string
+		rom    []byte
+		quirks chip8.Quirks
+	}{
+		{"None", program(0x00FF, 0x8006, 0x6000, 0xF055, 0xA300, 0xF065, 0x1200, 0x0000), chip8.SCHIP.Quirks},
+		{"ShiftVy", program(0x00FF, 0x8016, 0x6000, 0x6100, 0x6200, 0x6300, 0x1200, 0x0000), chip8.Quirks{ShiftVy: true, JumpVx: true, ClipSprites: true}},
+		{"LoadStoreI", program(0x00FF, 0xA300, 0xF165, 0xF165, 0x6200, 0x6300, 0x1200, 0x0000), chip8.Quirks{LoadStoreI: true, JumpVx: true, ClipSprites: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := chip8.DetectPlatform(test.rom)
+			if d.Machine.Quirks != test.quirks {
+				t.Fatalf("expected quirks %+v, but got %+v (%v)", test.quirks, d.Machine.Quirks, d.Evidence)
+			}
+		})
+	}
+
+	if chip8.SCHIP.Quirks.ShiftVy || chip8.SCHIP.Quirks.LoadStoreI {
+		t.Fatal("expected the built-in machine to be unchanged")
+	}
+}
@@ -0,0 +1,190 @@
+package chip8
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// Palette holds the colors used to render a monochrome display, or the
+// two bitplanes of the XO-CHIP display.
+type Palette struct {
+	Background  color.RGBA
+	Foreground  color.RGBA
+	Foreground2 color.RGBA // pixels set only on the second plane (zero means Foreground)
+	Blend       color.RGBA // pixels set on both planes (zero means Foreground)
+}
+
+// DefaultPalette is the palette used when the emulator does not
+// specify one: white pixels on a black background, with shades of gray
+// for the second XO-CHIP plane.
+var DefaultPalette = &Palette{
+	Background:  color.RGBA{R: 0x00, G: 0x00, B: 0x00, A: 0xFF},
+	Foreground:  color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF},
+	Foreground2: color.RGBA{R: 0xAA, G: 0xAA, B: 0xAA, A: 0xFF},
+	Blend:       color.RGBA{R: 0x55, G: 0x55, B: 0x55, A: 0xFF},
+}
+
+// ParsePalette parses a comma separated list of "#RRGGBB" colors: the
+// background and foreground colors, optionally followed by the
+// Foreground2 and Blend colors (ex: "#000000,#FFFFFF").
+func ParsePalette(s string) (*Palette, error) {
+	colors := strings.Split(s, ",")
+	if len(colors) != 2 && len(colors) != 4 {
+		return nil, fmt.Errorf("invalid palette: %q", s)
+	}
+
+	parsed := make([]color.RGBA, len(colors))
+	for i, c := range colors {
+		var err error
+		if parsed[i], err = parseColor(strings.TrimSpace(c)); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &Palette{Background: parsed[0], Foreground: parsed[1]}
+	if len(parsed) == 4 {
+		p.Foreground2, p.Blend = parsed[2], parsed[3]
+	}
+
+	return p, nil
+}
+
+// color returns the color of a pixel set on the given bitplanes.
+func (p *Palette) color(planes int) color.RGBA {
+	switch {
+	case planes == 0:
+		return p.Background
+	case planes == 2 && p.Foreground2 != color.RGBA{}:
+		return p.Foreground2
+	case planes == 3 && p.Blend != color.RGBA{}:
+		return p.Blend
+	default:
+		return p.Foreground
+	}
+}
+
+// CHIP8XBackgrounds are the background colors of the CHIP-8X, in the
+// order they are cycled by the 02A0 instruction.
+var CHIP8XBackgrounds = [4]color.RGBA{
+	{R: 0x00, G: 0x00, B: 0x80, A: 0xFF}, // dark blue
+	{R: 0x00, G: 0x00, B: 0x00, A: 0xFF}, // black
+	{R: 0x00, G: 0x80, B: 0x00, A: 0xFF}, // green
+	{R: 0x80, G: 0x00, B: 0x00, A: 0xFF}, // red
+}
+
+// CHIP8XForegrounds are the foreground colors of the CHIP-8X.
+var CHIP8XForegrounds = [8]color.RGBA{
+	{R: 0x00, G: 0x00, B: 0x00, A: 0xFF}, // black
+	{R: 0xFF, G: 0x00, B: 0x00, A: 0xFF}, // red
+	{R: 0x00, G: 0x00, B: 0xFF, A: 0xFF}, // blue
+	{R: 0xFF, G: 0x00, B: 0xFF, A: 0xFF}, // violet
+	{R: 0x00, G: 0xFF, B: 0x00, A: 0xFF}, // green
+	{R: 0xFF, G: 0xFF, B: 0x00, A: 0xFF}, // yellow
+	{R: 0x00, G: 0xFF, B: 0xFF, A: 0xFF}, // aqua
+	{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}, // white
+}
+
+// default CHIP-8X foreground color (red)
+const chip8xForeground = 1
+
+// Pixel reports whether the pixel at the given position is set (on any
+// of the XO-CHIP bitplanes). Positions outside of the display are never
+// set.
+func (c *Emulator) Pixel(x, y int) bool {
+	return c.pixelPlanes(x, y) != 0
+}
+
+// pixelPlanes returns the bitplanes where the pixel at the given position
+// is set, one bit per plane.
+func (c *Emulator) pixelPlanes(x, y int) int {
+	if x < 0 || y < 0 || x >= c.Display.Width || y >= c.Display.Height {
+		return 0
+	}
+
+	video := c.videoMemory()
+	offset := (y*c.Display.Width + x) / 8
+	planes := 0
+
+	for p := 0; p < c.machine().planeCount(); p++ {
+		if video[p*c.Display.VideoSize()+offset]&(0x80>>uint(x%8)) != 0 {
+			planes |= 1 << uint(p)
+		}
+	}
+
+	return planes
+}
+
+// PixelColor returns the color of the pixel at the given position,
+// as it would be shown by the emulated machine.
+func (c *Emulator) PixelColor(x, y int) color.RGBA {
+	if c.Mega.Enabled {
+		pixel := c.Mega.Framebuffer.RGBAAt(x, y)
+		pixel.A = c.Mega.Alpha
+		return pixel
+	}
+
+	planes := c.pixelPlanes(x, y)
+
+	if c.supports(InstrCHIP8X) {
+		if planes != 0 && y < len(c.Zones) && x/8 < len(c.Zones[y]) {
+			return CHIP8XForegrounds[c.Zones[y][x/8]&0x07]
+		}
+
+		return CHIP8XBackgrounds[c.Background&0x03]
+	}
+
+	palette := c.Palette
+	if palette == nil {
+		palette = DefaultPalette
+	}
+
+	return palette.color(planes)
+}
+
+// Image renders the display to a new image, with one image pixel for
+// each pixel of the display (or of the 256x192 screen, when the MegaChip
+// mode is on). Scaling and presenting the image is left to the frontend.
+func (c *Emulator) Image() *image.RGBA {
+	width, height := c.Display.Width, c.Display.Height
+	if c.Mega.Enabled {
+		width, height = MegaWidth, MegaHeight
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, c.PixelColor(x, y))
+		}
+	}
+
+	return img
+}
+
+// resetColors sets the CHIP-8X colors to their initial values.
+func (c *Emulator) resetColors() {
+	c.Background = 0
+
+	for y := range c.Zones {
+		for x := range c.Zones[y] {
+			c.Zones[y][x] = chip8xForeground
+		}
+	}
+}
+
+// setZones sets the foreground color of the CHIP-8X zones. The
+// horizontal position and width (minus one), in 8-pixel columns, are
+// the low and high nibbles of h; the vertical position and height
+// are given in rows of pixels.
+func (c *Emulator) setZones(h byte, top, rows int, fg byte) {
+	left := int(h & lsnMask)
+	columns := int(h&msnMask>>4) + 1
+
+	for y := top; y < top+rows && y < len(c.Zones); y++ {
+		for x := left; x < left+columns && x < len(c.Zones[y]); x++ {
+			c.Zones[y][x] = fg & 0x07
+		}
+	}
+}
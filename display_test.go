@@ -0,0 +1,169 @@
+package chip8_test
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+func TestPixel(t *testing.T) {
+	c := chip8.NewEmulator(nil)
+	c.Memory[chip8.AddrVideo] = 0b10000001
+	c.Memory[chip8.AddrVideo+15] = 0b00000001 // last pixel of the second row
+
+	tests := []struct {
+		x, y     int
+		expected bool
+	}{
+		{x: 0, y: 0, expected: true},
+		{x: 1, y: 0, expected: false},
+		{x: 7, y: 0, expected: true},
+		{x: 8, y: 0, expected: false},
+		{x: 63, y: 1, expected: true},
+		{x: 0, y: 1, expected: false},
+		{x: -1, y: 0, expected: false},
+		{x: 64, y: 0, expected: false},
+		{x: 0, y: 32, expected: false},
+	}
+
+	for _, test := range tests {
+		if actual := c.Pixel(test.x, test.y); actual != test.expected {
+			t.Fatalf("expected pixel (%d, %d) to be %v, but was %v", test.x, test.y, test.expected, actual)
+		}
+	}
+}
+
+func TestImage(t *testing.T) {
+	c := chip8.NewEmulator(nil)
+	c.Memory[chip8.AddrVideo] = 0b10000000
+
+	img := c.Image()
+	if img.Bounds().Dx() != 64 || img.Bounds().Dy() != 32 {
+		t.Fatalf("expected a 64x32 image, but got %v", img.Bounds())
+	}
+
+	if actual := img.RGBAAt(0, 0); actual != chip8.DefaultPalette.Foreground {
+		t.Fatalf("expected foreground color on (0, 0), but found %v", actual)
+	}
+
+	if actual := img.RGBAAt(1, 0); actual != chip8.DefaultPalette.Background {
+		t.Fatalf("expected background color on (1, 0), but found %v", actual)
+	}
+
+	// custom colors
+	c.Palette = &chip8.Palette{
+		Background: color.RGBA{R: 0x10, A: 0xFF},
+		Foreground: color.RGBA{G: 0x20, A: 0xFF},
+	}
+
+	img = c.Image()
+	if actual := img.RGBAAt(0, 0); actual != c.Palette.Foreground {
+		t.Fatalf("expected custom foreground color on (0, 0), but found %v", actual)
+	}
+
+	if actual := img.RGBAAt(1, 0); actual != c.Palette.Background {
+		t.Fatalf("expected custom background color on (1, 0), but found %v", actual)
+	}
+}
+
+func TestImageCHIP8X(t *testing.T) {
+	c := chip8.NewEmulator(chip8.CHIP8X)
+	c.Memory[chip8.AddrVideo] = 0b10000000
+	c.Memory[chip8.AddrVideo+1] = 0b10000000
+	c.Zones[0][1] = 4
+
+	img := c.Image()
+
+	expected := map[[2]int]color.RGBA{
+		{0, 0}: chip8.CHIP8XForegrounds[1], // red, by default
+		{8, 0}: chip8.CHIP8XForegrounds[4], // green zone
+		{1, 0}: chip8.CHIP8XBackgrounds[0], // blue background
+		{8, 1}: chip8.CHIP8XBackgrounds[0],
+	}
+
+	for pos, want := range expected {
+		if actual := img.RGBAAt(pos[0], pos[1]); actual != want {
+			t.Fatalf("expected color %v at %v, but found %v", want, pos, actual)
+		}
+	}
+}
+
+func TestPixelColorPlanes(t *testing.T) {
+	rom := []byte{
+		0xA2, 0x0C, // I = 0x20C
+		0xD0, 0x01, // DRW V0, V0, 1 (first plane)
+		0xF2, 0x01, // PLANE 2
+		0xA2, 0x0D, // I = 0x20D
+		0xD0, 0x01, // DRW V0, V0, 1 (second plane)
+		0x00, 0x00,
+		0b10100000,
+		0b01100000,
+	}
+
+	c := chip8.NewEmulator(chip8.XOCHIP)
+	if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Execute(5); err != nil {
+		t.Fatal(err)
+	}
+
+	palette := chip8.DefaultPalette
+	tests := []struct {
+		x        int
+		expected color.RGBA
+	}{
+		{x: 0, expected: palette.Foreground},
+		{x: 1, expected: palette.Foreground2},
+		{x: 2, expected: palette.Blend},
+		{x: 3, expected: palette.Background},
+	}
+
+	for _, test := range tests {
+		if actual := c.PixelColor(test.x, 0); actual != test.expected {
+			t.Fatalf("expected pixel (%d, 0) to be %v, but was %v", test.x, test.expected, actual)
+		}
+	}
+
+	// without the plane colors, every set pixel uses the foreground
+	c.Palette = &chip8.Palette{Foreground: palette.Foreground}
+	if actual := c.PixelColor(2, 0); actual != palette.Foreground {
+		t.Fatalf("expected the foreground color, but found %v", actual)
+	}
+}
+
+func TestParsePalette(t *testing.T) {
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+	green := color.RGBA{G: 0xFF, A: 0xFF}
+	blue := color.RGBA{B: 0xFF, A: 0xFF}
+	white := color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+
+	tests := []struct {
+		text    string
+		palette *chip8.Palette
+	}{
+		{"#FF0000,#00FF00", &chip8.Palette{Background: red, Foreground: green}},
+		{"#FF0000, #00ff00, #0000FF, #FFFFFF", &chip8.Palette{Background: red, Foreground: green, Foreground2: blue, Blend: white}},
+		{"#FF0000", nil},
+		{"#FF0000,#00FF00,#0000FF", nil},
+		{"#FF0000,green", nil},
+	}
+
+	for _, test := range tests {
+		p, err := chip8.ParsePalette(test.text)
+		if test.palette == nil {
+			if err == nil {
+				t.Errorf("%q: expected an error, but got %+v", test.text, p)
+			}
+
+			continue
+		}
+
+		if err != nil || *p != *test.palette {
+			t.Errorf("%q: expected %+v, but got %+v (%v)", test.text, test.palette, p, err)
+		}
+	}
+}
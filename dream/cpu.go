@@ -0,0 +1,534 @@
+package dream
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrIllegalOpcode is returned when the CPU finds an opcode that is not
+// part of the 6800 instruction set.
+var ErrIllegalOpcode = errors.New("illegal opcode")
+
+// Bits of the condition code register.
+const (
+	FlagC byte = 1 << iota // carry
+	FlagV                  // overflow
+	FlagZ                  // zero
+	FlagN                  // negative
+	FlagI                  // interrupt mask
+	FlagH                  // half carry
+)
+
+// Addresses of the interrupt vectors.
+const (
+	VectorIRQ   = 0xFFF8
+	VectorSWI   = 0xFFFA
+	VectorNMI   = 0xFFFC
+	VectorReset = 0xFFFE
+)
+
+// Bus connects the 6800 to the memory; the I/O devices are mapped on
+// the memory.
+type Bus interface {
+	Read(addr uint16) byte
+	Write(addr uint16, value byte)
+}
+
+// CPU is the Motorola 6800 microprocessor.
+type CPU struct {
+	A, B byte   // accumulators
+	X    uint16 // index register
+	SP   uint16 // stack pointer
+	PC   uint16 // program counter
+	CC   byte   // condition codes (see FlagC)
+
+	// Waiting is set by the WAI instruction; the CPU does nothing until
+	// an interrupt.
+	Waiting bool
+
+	Bus Bus
+}
+
+// cycles taken by each instruction; zero means an illegal opcode
+var cycles = [256]byte{
+	0, 2, 0, 0, 0, 0, 2, 2, 4, 4, 2, 2, 2, 2, 2, 2, // 0x00
+	2, 2, 0, 0, 0, 0, 2, 2, 0, 2, 0, 2, 0, 0, 0, 0, // 0x10
+	4, 0, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, // 0x20
+	4, 4, 4, 4, 4, 4, 4, 4, 0, 5, 0, 10, 0, 0, 9, 12, // 0x30
+	2, 0, 0, 2, 2, 0, 2, 2, 2, 2, 2, 0, 2, 2, 0, 2, // 0x40
+	2, 0, 0, 2, 2, 0, 2, 2, 2, 2, 2, 0, 2, 2, 0, 2, // 0x50
+	7, 0, 0, 7, 7, 0, 7, 7, 7, 7, 7, 0, 7, 7, 4, 7, // 0x60
+	6, 0, 0, 6, 6, 0, 6, 6, 6, 6, 6, 0, 6, 6, 3, 6, // 0x70
+	2, 2, 2, 0, 2, 2, 2, 0, 2, 2, 2, 2, 3, 8, 3, 0, // 0x80
+	3, 3, 3, 0, 3, 3, 3, 4, 3, 3, 3, 3, 4, 0, 4, 5, // 0x90
+	5, 5, 5, 0, 5, 5, 5, 6, 5, 5, 5, 5, 6, 8, 6, 7, // 0xA0
+	4, 4, 4, 0, 4, 4, 4, 5, 4, 4, 4, 4, 5, 9, 5, 6, // 0xB0
+	2, 2, 2, 0, 2, 2, 2, 0, 2, 2, 2, 2, 0, 0, 3, 0, // 0xC0
+	3, 3, 3, 0, 3, 3, 3, 4, 3, 3, 3, 3, 0, 0, 4, 5, // 0xD0
+	5, 5, 5, 0, 5, 5, 5, 6, 5, 5, 5, 5, 0, 0, 6, 7, // 0xE0
+	4, 4, 4, 0, 4, 4, 4, 5, 4, 4, 4, 4, 0, 0, 5, 6, // 0xF0
+}
+
+// Reset masks the interrupts and jumps to the address on the reset
+// vector.
+func (cpu *CPU) Reset() {
+	cpu.CC = 0xC0 | FlagI
+	cpu.Waiting = false
+	cpu.PC = cpu.read16(VectorReset)
+}
+
+// IRQ requests a maskable interrupt, returning the cycles taken. If the
+// interrupts are masked, the request is ignored and no time passes.
+func (cpu *CPU) IRQ() int {
+	if cpu.CC&FlagI != 0 {
+		return 0
+	}
+
+	return cpu.interrupt(VectorIRQ)
+}
+
+// NMI requests a non maskable interrupt, returning the cycles taken.
+func (cpu *CPU) NMI() int {
+	return cpu.interrupt(VectorNMI)
+}
+
+// interrupt saves the registers on the stack (unless WAI already did it)
+// and jumps to the address on the vector.
+func (cpu *CPU) interrupt(vector uint16) int {
+	taken := 4
+	if !cpu.Waiting {
+		cpu.pushState()
+		taken = 12
+	}
+
+	cpu.Waiting = false
+	cpu.CC |= FlagI
+	cpu.PC = cpu.read16(vector)
+	return taken
+}
+
+// Step executes one instruction, returning the cycles taken. When the
+// CPU is waiting for an interrupt, a single cycle passes.
+func (cpu *CPU) Step() (int, error) {
+	if cpu.Waiting {
+		return 1, nil
+	}
+
+	pc := cpu.PC
+	op := cpu.fetch()
+	if cycles[op] == 0 {
+		return 2, fmt.Errorf("%w %02X at %04X", ErrIllegalOpcode, op, pc)
+	}
+
+	switch {
+	case op >= 0x80:
+		cpu.accumulator(op)
+	case op >= 0x40:
+		cpu.unary(op)
+	case op >= 0x20 && op < 0x30:
+		cpu.branch(op)
+	default:
+		cpu.inherent(op)
+	}
+
+	return int(cycles[op]), nil
+}
+
+// fetch reads the byte pointed by the program counter, advancing it.
+func (cpu *CPU) fetch() byte {
+	value := cpu.Bus.Read(cpu.PC)
+	cpu.PC++
+	return value
+}
+
+// operand returns the address of the operand of the instruction, on
+// the addressing mode given by bits 4 and 5 of the opcode: immediate
+// (with the given size), direct, indexed and extended.
+func (cpu *CPU) operand(op byte, size uint16) uint16 {
+	switch op >> 4 & 0x03 {
+	case 0:
+		addr := cpu.PC
+		cpu.PC += size
+		return addr
+	case 1:
+		return uint16(cpu.fetch())
+	case 2:
+		return cpu.X + uint16(cpu.fetch())
+	default:
+		high := cpu.fetch()
+		return uint16(high)<<8 | uint16(cpu.fetch())
+	}
+}
+
+func (cpu *CPU) read16(addr uint16) uint16 {
+	return uint16(cpu.Bus.Read(addr))<<8 | uint16(cpu.Bus.Read(addr+1))
+}
+
+func (cpu *CPU) write16(addr uint16, value uint16) {
+	cpu.Bus.Write(addr, byte(value>>8))
+	cpu.Bus.Write(addr+1, byte(value))
+}
+
+func (cpu *CPU) push(value byte) {
+	cpu.Bus.Write(cpu.SP, value)
+	cpu.SP--
+}
+
+func (cpu *CPU) pull() byte {
+	cpu.SP++
+	return cpu.Bus.Read(cpu.SP)
+}
+
+func (cpu *CPU) push16(value uint16) {
+	cpu.push(byte(value))
+	cpu.push(byte(value >> 8))
+}
+
+func (cpu *CPU) pull16() uint16 {
+	high := cpu.pull()
+	return uint16(high)<<8 | uint16(cpu.pull())
+}
+
+// pushState saves all registers on the stack, like the interrupts do.
+func (cpu *CPU) pushState() {
+	cpu.push16(cpu.PC)
+	cpu.push16(cpu.X)
+	cpu.push(cpu.A)
+	cpu.push(cpu.B)
+	cpu.push(cpu.CC)
+}
+
+// inherent runs the instructions without operands, on 0x00 to 0x3F.
+func (cpu *CPU) inherent(op byte) {
+	switch op {
+	case 0x06: // TAP
+		cpu.CC = cpu.A | 0xC0
+	case 0x07: // TPA
+		cpu.A = cpu.CC | 0xC0
+	case 0x08: // INX
+		cpu.X++
+		cpu.set(FlagZ, cpu.X == 0)
+	case 0x09: // DEX
+		cpu.X--
+		cpu.set(FlagZ, cpu.X == 0)
+	case 0x0A, 0x0B: // CLV, SEV
+		cpu.set(FlagV, op == 0x0B)
+	case 0x0C, 0x0D: // CLC, SEC
+		cpu.set(FlagC, op == 0x0D)
+	case 0x0E, 0x0F: // CLI, SEI
+		cpu.set(FlagI, op == 0x0F)
+	case 0x10: // SBA
+		cpu.A = cpu.sub(cpu.A, cpu.B, 0)
+	case 0x11: // CBA
+		cpu.sub(cpu.A, cpu.B, 0)
+	case 0x16: // TAB
+		cpu.B = cpu.logic(cpu.A)
+	case 0x17: // TBA
+		cpu.A = cpu.logic(cpu.B)
+	case 0x19:
+		cpu.daa()
+	case 0x1B: // ABA
+		cpu.A = cpu.add(cpu.A, cpu.B, 0)
+	case 0x30: // TSX
+		cpu.X = cpu.SP + 1
+	case 0x31: // INS
+		cpu.SP++
+	case 0x32: // PULA
+		cpu.A = cpu.pull()
+	case 0x33: // PULB
+		cpu.B = cpu.pull()
+	case 0x34: // DES
+		cpu.SP--
+	case 0x35: // TXS
+		cpu.SP = cpu.X - 1
+	case 0x36: // PSHA
+		cpu.push(cpu.A)
+	case 0x37: // PSHB
+		cpu.push(cpu.B)
+	case 0x39: // RTS
+		cpu.PC = cpu.pull16()
+	case 0x3B: // RTI
+		cpu.CC = cpu.pull() | 0xC0
+		cpu.B = cpu.pull()
+		cpu.A = cpu.pull()
+		cpu.X = cpu.pull16()
+		cpu.PC = cpu.pull16()
+	case 0x3E: // WAI
+		cpu.pushState()
+		cpu.Waiting = true
+	case 0x3F: // SWI
+		cpu.pushState()
+		cpu.CC |= FlagI
+		cpu.PC = cpu.read16(VectorSWI)
+	}
+}
+
+// branch runs the relative branches, on 0x20 to 0x2F. Each even opcode
+// branches on the negation of the condition of the next one.
+func (cpu *CPU) branch(op byte) {
+	offset := int8(cpu.fetch())
+	n, v := cpu.flag(FlagN), cpu.flag(FlagV)
+
+	var condition bool
+	switch op & 0x0E {
+	case 0x0: // BRA
+		condition = false
+	case 0x2: // BLS
+		condition = cpu.flag(FlagC) || cpu.flag(FlagZ)
+	case 0x4: // BCS
+		condition = cpu.flag(FlagC)
+	case 0x6: // BEQ
+		condition = cpu.flag(FlagZ)
+	case 0x8: // BVS
+		condition = v
+	case 0xA: // BMI
+		condition = n
+	case 0xC: // BLT
+		condition = n != v
+	case 0xE: // BLE
+		condition = cpu.flag(FlagZ) || n != v
+	}
+
+	if condition == (op&0x01 != 0) {
+		cpu.PC += uint16(int16(offset))
+	}
+}
+
+// unary runs the instructions with a single operand, on accumulator A
+// (0x40 to 0x4F), accumulator B (0x50 to 0x5F) or memory (0x60 to 0x7F).
+func (cpu *CPU) unary(op byte) {
+	if op&0x0F == 0x0E { // JMP
+		cpu.PC = cpu.operand(op, 0)
+		return
+	}
+
+	var addr uint16
+	var value byte
+
+	switch op & 0xF0 {
+	case 0x40:
+		value = cpu.A
+	case 0x50:
+		value = cpu.B
+	default:
+		addr = cpu.operand(op, 0)
+		value = cpu.Bus.Read(addr)
+	}
+
+	value = cpu.modify(op&0x0F, value)
+	if op&0x0F == 0x0D { // TST
+		return
+	}
+
+	switch op & 0xF0 {
+	case 0x40:
+		cpu.A = value
+	case 0x50:
+		cpu.B = value
+	default:
+		cpu.Bus.Write(addr, value)
+	}
+}
+
+// modify applies the unary operation n to the value.
+func (cpu *CPU) modify(n byte, value byte) byte {
+	carry := cpu.CC & FlagC
+
+	switch n {
+	case 0x0: // NEG
+		result := -value
+		cpu.nz(result)
+		cpu.set(FlagV, result == 0x80)
+		cpu.set(FlagC, result != 0)
+		return result
+	case 0x3: // COM
+		cpu.CC |= FlagC
+		return cpu.logic(^value)
+	case 0x4: // LSR
+		return cpu.shift(value>>1, value&0x01)
+	case 0x6: // ROR
+		return cpu.shift(value>>1|carry<<7, value&0x01)
+	case 0x7: // ASR
+		return cpu.shift(value>>1|value&0x80, value&0x01)
+	case 0x8: // ASL
+		return cpu.shift(value<<1, value>>7)
+	case 0x9: // ROL
+		return cpu.shift(value<<1|carry, value>>7)
+	case 0xA: // DEC
+		cpu.nz(value - 1)
+		cpu.set(FlagV, value == 0x80)
+		return value - 1
+	case 0xC: // INC
+		cpu.nz(value + 1)
+		cpu.set(FlagV, value == 0x7F)
+		return value + 1
+	case 0xD: // TST
+		cpu.CC &^= FlagC
+		return cpu.logic(value)
+	default: // CLR
+		cpu.CC &^= FlagC
+		return cpu.logic(0)
+	}
+}
+
+// accumulator runs the instructions on 0x80 to 0xFF, with accumulator A
+// (0x80 to 0xBF) or B (0xC0 to 0xFF) and an operand on memory; these
+// include the 16 bit loads, stores and compares, and the subroutine
+// calls.
+func (cpu *CPU) accumulator(op byte) {
+	reg := &cpu.A
+	if op >= 0xC0 {
+		reg = &cpu.B
+	}
+
+	switch op & 0x0F {
+	case 0xC: // CPX
+		cpu.compareX(cpu.read16(cpu.operand(op, 2)))
+	case 0xD: // BSR, JSR
+		var addr uint16
+		if op == 0x8D {
+			offset := int8(cpu.fetch())
+			addr = cpu.PC + uint16(int16(offset))
+		} else {
+			addr = cpu.operand(op, 0)
+		}
+
+		cpu.push16(cpu.PC)
+		cpu.PC = addr
+	case 0xE: // LDS, LDX
+		value := cpu.read16(cpu.operand(op, 2))
+		cpu.nz16(value)
+
+		if op < 0xC0 {
+			cpu.SP = value
+		} else {
+			cpu.X = value
+		}
+	case 0xF: // STS, STX
+		value := cpu.SP
+		if op >= 0xC0 {
+			value = cpu.X
+		}
+
+		cpu.nz16(value)
+		cpu.write16(cpu.operand(op, 0), value)
+	case 0x7: // STA
+		cpu.Bus.Write(cpu.operand(op, 0), cpu.logic(*reg))
+	default:
+		value := cpu.Bus.Read(cpu.operand(op, 1))
+
+		switch op & 0x0F {
+		case 0x0: // SUB
+			*reg = cpu.sub(*reg, value, 0)
+		case 0x1: // CMP
+			cpu.sub(*reg, value, 0)
+		case 0x2: // SBC
+			*reg = cpu.sub(*reg, value, cpu.CC&FlagC)
+		case 0x4: // AND
+			*reg = cpu.logic(*reg & value)
+		case 0x5: // BIT
+			cpu.logic(*reg & value)
+		case 0x6: // LDA
+			*reg = cpu.logic(value)
+		case 0x8: // EOR
+			*reg = cpu.logic(*reg ^ value)
+		case 0x9: // ADC
+			*reg = cpu.add(*reg, value, cpu.CC&FlagC)
+		case 0xA: // ORA
+			*reg = cpu.logic(*reg | value)
+		case 0xB: // ADD
+			*reg = cpu.add(*reg, value, 0)
+		}
+	}
+}
+
+// add returns a + b + carry, setting all the flags but I.
+func (cpu *CPU) add(a, b, carry byte) byte {
+	sum := uint16(a) + uint16(b) + uint16(carry)
+	result := byte(sum)
+
+	cpu.set(FlagH, a&0x0F+b&0x0F+carry > 0x0F)
+	cpu.nz(result)
+	cpu.set(FlagV, (a^result)&(b^result)&0x80 != 0)
+	cpu.set(FlagC, sum > 0xFF)
+	return result
+}
+
+// sub returns a - b - borrow, setting N, Z, V and C.
+func (cpu *CPU) sub(a, b, borrow byte) byte {
+	diff := int(a) - int(b) - int(borrow)
+	result := byte(diff)
+
+	cpu.nz(result)
+	cpu.set(FlagV, (a^b)&(a^result)&0x80 != 0)
+	cpu.set(FlagC, diff < 0)
+	return result
+}
+
+// compareX compares the index register with a value (CPX). The carry is
+// not changed.
+func (cpu *CPU) compareX(value uint16) {
+	result := cpu.X - value
+
+	cpu.nz16(result)
+	cpu.set(FlagV, (cpu.X^value)&(cpu.X^result)&0x8000 != 0)
+}
+
+// logic sets the flags of the logical operations and data transfers: N
+// and Z by the value, and V cleared.
+func (cpu *CPU) logic(value byte) byte {
+	cpu.nz(value)
+	cpu.CC &^= FlagV
+	return value
+}
+
+// shift sets the flags of the shifts and rotations, where V is N xor C.
+func (cpu *CPU) shift(result byte, carry byte) byte {
+	cpu.nz(result)
+	cpu.set(FlagC, carry != 0)
+	cpu.set(FlagV, cpu.flag(FlagN) != (carry != 0))
+	return result
+}
+
+// daa adjusts the sum of two BCD numbers on accumulator A.
+func (cpu *CPU) daa() {
+	low, high := cpu.A&0x0F, cpu.A>>4
+	carry := cpu.flag(FlagC)
+
+	var correction byte
+	if cpu.flag(FlagH) || low > 9 {
+		correction |= 0x06
+	}
+
+	if carry || high > 9 || high > 8 && low > 9 {
+		correction |= 0x60
+		carry = true
+	}
+
+	cpu.A += correction
+	cpu.logic(cpu.A)
+	cpu.set(FlagC, carry)
+}
+
+func (cpu *CPU) nz(value byte) {
+	cpu.set(FlagN, value&0x80 != 0)
+	cpu.set(FlagZ, value == 0)
+}
+
+func (cpu *CPU) nz16(value uint16) {
+	cpu.set(FlagN, value&0x8000 != 0)
+	cpu.set(FlagZ, value == 0)
+	cpu.CC &^= FlagV
+}
+
+func (cpu *CPU) flag(f byte) bool {
+	return cpu.CC&f != 0
+}
+
+func (cpu *CPU) set(f byte, on bool) {
+	if on {
+		cpu.CC |= f
+	} else {
+		cpu.CC &^= f
+	}
+}
@@ -0,0 +1,155 @@
+package dream_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ibraimgm/chip8/dream"
+)
+
+// testBus is a bus with 64K of RAM.
+type testBus struct {
+	mem [0x10000]byte
+}
+
+func (b *testBus) Read(addr uint16) byte         { return b.mem[addr] }
+func (b *testBus) Write(addr uint16, value byte) { b.mem[addr] = value }
+
+// newTestCPU creates a CPU that starts running the program at 0x0100,
+// with the stack at 0x00FF.
+func newTestCPU(program ...byte) (*dream.CPU, *testBus) {
+	bus := &testBus{}
+	copy(bus.mem[0x0100:], program)
+	bus.mem[dream.VectorReset] = 0x01
+
+	cpu := &dream.CPU{Bus: bus}
+	cpu.Reset()
+	cpu.SP = 0x00FF
+	return cpu, bus
+}
+
+func TestCPU(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []byte
+		steps   int
+		setup   func(cpu *dream.CPU, bus *testBus)
+		check   func(cpu *dream.CPU, bus *testBus) bool
+	}{
+		{"LDAA immediate", []byte{0x86, 0x80}, 1, nil,
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.A == 0x80 && cpu.CC&dream.FlagN != 0 }},
+		{"LDAB direct", []byte{0xD6, 0x10}, 1, func(cpu *dream.CPU, bus *testBus) { bus.mem[0x10] = 0x42 },
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.B == 0x42 }},
+		{"LDAA indexed", []byte{0xA6, 0x02}, 1, func(cpu *dream.CPU, bus *testBus) { cpu.X = 0x0200; bus.mem[0x0202] = 0x33 },
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.A == 0x33 }},
+		{"STAA extended", []byte{0x86, 0x00, 0xB7, 0x12, 0x34}, 2, nil,
+			func(cpu *dream.CPU, bus *testBus) bool { return bus.mem[0x1234] == 0x00 && cpu.CC&dream.FlagZ != 0 }},
+		{"LDX/STX", []byte{0xCE, 0xBE, 0xEF, 0xDF, 0x10}, 2, nil,
+			func(cpu *dream.CPU, bus *testBus) bool {
+				return cpu.X == 0xBEEF && bus.mem[0x10] == 0xBE && bus.mem[0x11] == 0xEF
+			}},
+		{"ADDA carry", []byte{0x86, 0xFF, 0x8B, 0x01}, 2, nil,
+			func(cpu *dream.CPU, bus *testBus) bool {
+				return cpu.A == 0 && cpu.CC&(dream.FlagC|dream.FlagZ|dream.FlagH) == dream.FlagC|dream.FlagZ|dream.FlagH
+			}},
+		{"ADDA overflow", []byte{0x86, 0x7F, 0x8B, 0x01}, 2, nil,
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.A == 0x80 && cpu.CC&dream.FlagV != 0 }},
+		{"SUBA borrow", []byte{0x86, 0x01, 0x80, 0x02}, 2, nil,
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.A == 0xFF && cpu.CC&dream.FlagC != 0 }},
+		{"CMPA equal", []byte{0x86, 0x05, 0x81, 0x05}, 2, nil,
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.A == 0x05 && cpu.CC&dream.FlagZ != 0 }},
+		{"ABA/DAA", []byte{0x86, 0x19, 0xC6, 0x28, 0x1B, 0x19}, 4, nil,
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.A == 0x47 }},
+		{"NEGA", []byte{0x86, 0x01, 0x40}, 2, nil,
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.A == 0xFF && cpu.CC&dream.FlagC != 0 }},
+		{"LSRB", []byte{0xC6, 0x03, 0x54}, 2, nil,
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.B == 0x01 && cpu.CC&dream.FlagC != 0 }},
+		{"ROLA", []byte{0x0D, 0x86, 0x80, 0x49}, 3, nil,
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.A == 0x01 && cpu.CC&dream.FlagC != 0 }},
+		{"INC extended", []byte{0x7C, 0x00, 0x10}, 1, func(cpu *dream.CPU, bus *testBus) { bus.mem[0x10] = 0x7F },
+			func(cpu *dream.CPU, bus *testBus) bool { return bus.mem[0x10] == 0x80 && cpu.CC&dream.FlagV != 0 }},
+		{"CLR indexed", []byte{0x6F, 0x01}, 1, func(cpu *dream.CPU, bus *testBus) { cpu.X = 0x10; bus.mem[0x11] = 0x55 },
+			func(cpu *dream.CPU, bus *testBus) bool { return bus.mem[0x11] == 0 && cpu.CC&dream.FlagZ != 0 }},
+		{"BNE taken", []byte{0x86, 0x01, 0x26, 0x10}, 2, nil,
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.PC == 0x0114 }},
+		{"BEQ not taken", []byte{0x86, 0x01, 0x27, 0x10}, 2, nil,
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.PC == 0x0104 }},
+		{"BRA backwards", []byte{0x01, 0x20, 0xFD}, 2, nil,
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.PC == 0x0100 }},
+		{"BLT", []byte{0x86, 0x01, 0x81, 0x02, 0x2D, 0x10}, 3, nil,
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.PC == 0x0116 }},
+		{"JSR/RTS", []byte{0xBD, 0x01, 0x10}, 2, func(cpu *dream.CPU, bus *testBus) { bus.mem[0x0110] = 0x39 },
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.PC == 0x0103 && cpu.SP == 0x00FF }},
+		{"BSR", []byte{0x8D, 0x10}, 1, nil,
+			func(cpu *dream.CPU, bus *testBus) bool {
+				return cpu.PC == 0x0112 && bus.mem[0xFF] == 0x02 && bus.mem[0xFE] == 0x01
+			}},
+		{"PSHA/PULB", []byte{0x86, 0x42, 0x36, 0x33}, 3, nil,
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.B == 0x42 && cpu.SP == 0x00FF }},
+		{"TSX/TXS", []byte{0x30, 0x08, 0x35}, 3, nil,
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.X == 0x0101 && cpu.SP == 0x0100 }},
+		{"TAP/TPA", []byte{0x86, 0x01, 0x06, 0x07}, 3, nil,
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.CC == 0xC1 && cpu.A == 0xC1 }},
+		{"CPX", []byte{0xCE, 0x12, 0x34, 0x8C, 0x12, 0x34}, 2, nil,
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.CC&dream.FlagZ != 0 }},
+		{"DEX", []byte{0xCE, 0x00, 0x01, 0x09}, 2, nil,
+			func(cpu *dream.CPU, bus *testBus) bool { return cpu.X == 0 && cpu.CC&dream.FlagZ != 0 }},
+		{"SWI/RTI", []byte{0x86, 0x42, 0x3F}, 3, func(cpu *dream.CPU, bus *testBus) {
+			bus.mem[dream.VectorSWI], bus.mem[dream.VectorSWI+1] = 0x02, 0x00
+			bus.mem[0x0200] = 0x3B
+		}, func(cpu *dream.CPU, bus *testBus) bool { return cpu.PC == 0x0103 && cpu.A == 0x42 && cpu.SP == 0x00FF }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpu, bus := newTestCPU(test.program...)
+			if test.setup != nil {
+				test.setup(cpu, bus)
+			}
+
+			for i := 0; i < test.steps; i++ {
+				if _, err := cpu.Step(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if !test.check(cpu, bus) {
+				t.Fatalf("unexpected state: %+v", *cpu)
+			}
+		})
+	}
+}
+
+func TestCPUIllegalOpcode(t *testing.T) {
+	cpu, _ := newTestCPU(0x02)
+
+	if _, err := cpu.Step(); !errors.Is(err, dream.ErrIllegalOpcode) {
+		t.Fatalf("expected ErrIllegalOpcode, but got %v", err)
+	}
+}
+
+func TestCPUInterrupt(t *testing.T) {
+	cpu, bus := newTestCPU(0x0E, 0x3E)
+	bus.mem[dream.VectorIRQ], bus.mem[dream.VectorIRQ+1] = 0x02, 0x00
+
+	// interrupts are masked after reset
+	if cycles := cpu.IRQ(); cycles != 0 || cpu.PC != 0x0100 {
+		t.Fatal("expected the interrupt to be ignored")
+	}
+
+	cpu.Step() // CLI
+	cpu.Step() // WAI
+
+	if cycles, _ := cpu.Step(); !cpu.Waiting || cycles != 1 {
+		t.Fatal("expected the CPU to wait")
+	}
+
+	// the registers were saved by WAI
+	if cycles := cpu.IRQ(); cycles != 4 || cpu.PC != 0x0200 || cpu.Waiting || cpu.SP != 0x00F8 {
+		t.Fatalf("unexpected state after the interrupt: %+v", *cpu)
+	}
+
+	if cpu.CC&dream.FlagI == 0 {
+		t.Fatal("expected the interrupts to be masked")
+	}
+}
@@ -0,0 +1,244 @@
+// Package dream is a low level emulator of the DREAM 6800, a Motorola 6800
+// computer that ran CHIP-8 programs with its CHIPOS monitor. It emulates
+// the CPU, the memory map, the DMA display and the keypad, running an
+// image of CHIPOS supplied by the user. It implements chip8.System, so
+// frontends can use it in place of the chip8.Emulator.
+package dream
+
+import (
+	"errors"
+	"image"
+	"io"
+	"io/ioutil"
+
+	"github.com/ibraimgm/chip8"
+)
+
+// ErrROMSize is returned when the CHIPOS image is empty or does not fit
+// on the space reserved for the ROM.
+var ErrROMSize = errors.New("invalid ROM size")
+
+// Memory map of the DREAM 6800.
+const (
+	RAMSize     = 4096   // size of the RAM, mapped at address zero
+	VideoAddr   = 0x0100 // address of the display memory
+	StartAddr   = 0x0200 // address where the CHIP-8 programs are loaded
+	PIAAddr     = 0x8010 // address of the registers of the PIA
+	ROMAddr     = 0xC000 // address of CHIPOS, mirrored up to the vectors
+	ROMSize     = 0x4000 // maximum size of the ROM
+	TimerAddr   = 0x0020 // CHIP-8 delay timer, kept by CHIPOS
+	ToneAddr    = 0x0021 // CHIP-8 sound timer, kept by CHIPOS
+	speakerLine = 0x40   // line of port B that drives the speaker
+)
+
+// Timing of the DREAM 6800. The display refreshes at 50 Hz, and each
+// frame signals the control line 1 of port B of the PIA, which CHIPOS
+// uses as the interrupt that updates the timers. The cycles taken by the
+// video DMA are not emulated.
+const (
+	ClockRate       = 1000000
+	FramesPerSecond = 50
+	FrameCycles     = ClockRate / FramesPerSecond
+)
+
+// Size of the display, in pixels.
+const (
+	Width  = 64
+	Height = 32
+)
+
+// DREAM is a DREAM 6800 with CHIPOS installed.
+//
+// The keypad is a matrix of 4 rows, driven by the lines PA0 to PA3 of
+// the PIA, and 4 columns, read by the lines PA4 to PA7; both are active
+// low, and the key on row r and column c is r*4 + c. Pressing any key
+// also signals the control line 1 of port A.
+type DREAM struct {
+	CPU     CPU
+	PIA     PIA
+	RAM     []byte
+	ROM     []byte         // CHIPOS image
+	Keys    uint16         // pressed keys, one bit per key
+	Palette *chip8.Palette // display colors (nil means chip8.DefaultPalette)
+	cycle   int            // cycle of the frame
+}
+
+// New creates a DREAM 6800 running the given CHIPOS image.
+func New(chipos io.Reader) (*DREAM, error) {
+	rom, err := ioutil.ReadAll(chipos)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rom) == 0 || len(rom) > ROMSize {
+		return nil, ErrROMSize
+	}
+
+	d := &DREAM{ROM: rom}
+	d.Reset()
+	return d, nil
+}
+
+// Reset clears the memory and resets the CPU and the PIA.
+func (d *DREAM) Reset() {
+	if len(d.RAM) != RAMSize {
+		d.RAM = make([]byte, RAMSize)
+	}
+
+	for i := range d.RAM {
+		d.RAM[i] = 0
+	}
+
+	d.PIA.Reset()
+	d.PIA.A.Input = d.keypad
+	d.CPU.Bus = d
+	d.CPU.Reset()
+	d.cycle = 0
+}
+
+// LoadROM resets the machine and loads a CHIP-8 program at StartAddr.
+func (d *DREAM) LoadROM(rom io.Reader) error {
+	d.Reset()
+
+	data, err := ioutil.ReadAll(rom)
+	if err != nil {
+		return err
+	}
+
+	if StartAddr+len(data) > len(d.RAM) {
+		return chip8.ErrLoadOverflow
+	}
+
+	copy(d.RAM[StartAddr:], data)
+	return nil
+}
+
+// RunFrame runs the machine for one frame of the display (1/50 of a
+// second), returning the number of instructions executed by the CPU.
+func (d *DREAM) RunFrame() (int, error) {
+	executed := 0
+	d.PIA.B.Signal()
+
+	for d.cycle < FrameCycles {
+		if d.PIA.IRQ() {
+			if cycles := d.CPU.IRQ(); cycles > 0 {
+				d.cycle += cycles
+				continue
+			}
+		}
+
+		if !d.CPU.Waiting {
+			executed++
+		}
+
+		cycles, err := d.CPU.Step()
+		d.cycle += cycles
+
+		if err != nil {
+			return executed, err
+		}
+	}
+
+	d.cycle -= FrameCycles
+	return executed, nil
+}
+
+// Read reads the memory: the RAM, the PIA and the ROM. Other addresses
+// read as 0xFF.
+func (d *DREAM) Read(addr uint16) byte {
+	switch {
+	case int(addr) < len(d.RAM):
+		return d.RAM[addr]
+	case addr >= PIAAddr && addr < PIAAddr+4:
+		return d.PIA.Read(int(addr - PIAAddr))
+	case addr >= ROMAddr:
+		return d.ROM[int(addr-ROMAddr)%len(d.ROM)]
+	default:
+		return 0xFF
+	}
+}
+
+// Write writes to the RAM or to the PIA; other writes are ignored.
+func (d *DREAM) Write(addr uint16, value byte) {
+	switch {
+	case int(addr) < len(d.RAM):
+		d.RAM[addr] = value
+	case addr >= PIAAddr && addr < PIAAddr+4:
+		d.PIA.Write(int(addr-PIAAddr), value)
+	}
+}
+
+// keypad returns the levels of the column lines of the keypad, for the
+// rows driven low by port A.
+func (d *DREAM) keypad() byte {
+	rows := d.PIA.A.Output | ^d.PIA.A.Direction
+	columns := byte(0xFF)
+
+	for key := 0; key < 16; key++ {
+		if d.IsPressed(key) && rows&(1<<uint(key/4)) == 0 {
+			columns &^= 1 << uint(4+key%4)
+		}
+	}
+
+	return columns
+}
+
+// PressKey presses a key of the keypad.
+func (d *DREAM) PressKey(key int) {
+	d.Keys |= 1 << uint(key&0x0F)
+	d.PIA.A.Signal()
+}
+
+// ReleaseKey releases a key of the keypad.
+func (d *DREAM) ReleaseKey(key int) {
+	d.Keys &^= 1 << uint(key&0x0F)
+}
+
+// IsPressed reports whether a key of the keypad is pressed.
+func (d *DREAM) IsPressed(key int) bool {
+	return d.Keys&(1<<uint(key&0x0F)) != 0
+}
+
+// Pixel reports whether the pixel at the given position is set on the
+// display memory. Positions outside of the display are never set.
+func (d *DREAM) Pixel(x, y int) bool {
+	if x < 0 || y < 0 || x >= Width || y >= Height {
+		return false
+	}
+
+	return d.RAM[VideoAddr+y*Width/8+x/8]&(0x80>>uint(x%8)) != 0
+}
+
+// Image renders the display to a new 64x32 image.
+func (d *DREAM) Image() *image.RGBA {
+	palette := d.Palette
+	if palette == nil {
+		palette = chip8.DefaultPalette
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+
+	for y := 0; y < Height; y++ {
+		for x := 0; x < Width; x++ {
+			if d.Pixel(x, y) {
+				img.SetRGBA(x, y, palette.Foreground)
+			} else {
+				img.SetRGBA(x, y, palette.Background)
+			}
+		}
+	}
+
+	return img
+}
+
+// Timers returns the delay and sound timers of CHIP-8, kept by CHIPOS on
+// TimerAddr and ToneAddr.
+func (d *DREAM) Timers() (delay, sound byte) {
+	return d.RAM[TimerAddr], d.RAM[ToneAddr]
+}
+
+// SoundOn reports whether the speaker, driven by port B of the PIA, is
+// on.
+func (d *DREAM) SoundOn() bool {
+	return d.PIA.B.Direction&speakerLine != 0 && d.PIA.B.Output&speakerLine != 0
+}
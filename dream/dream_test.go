@@ -0,0 +1,149 @@
+package dream_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+	"github.com/ibraimgm/chip8/dream"
+)
+
+var _ chip8.System = (*dream.DREAM)(nil)
+
+// testMonitor is a small replacement for CHIPOS: it counts the frames on
+// the delay timer and on the first byte of the display, and turns the
+// speaker on when key 1 is pressed.
+var testMonitor = map[int][]byte{
+	0x000: {
+		0x8E, 0x00, 0x7F, // C000: LDS #$007F
+		0x86, 0x40, //       C003: LDAA #$40
+		0xB7, 0x80, 0x12, // C005: STAA $8012 (DDRB)
+		0x86, 0x0F, //       C008: LDAA #$0F
+		0xB7, 0x80, 0x10, // C00A: STAA $8010 (DDRA)
+		0x86, 0x05, //       C00D: LDAA #$05
+		0xB7, 0x80, 0x13, // C00F: STAA $8013 (CRB)
+		0x86, 0x04, //       C012: LDAA #$04
+		0xB7, 0x80, 0x11, // C014: STAA $8011 (CRA)
+		0x86, 0x0E, //       C017: LDAA #$0E
+		0xB7, 0x80, 0x10, // C019: STAA $8010 (row 0)
+		0x0E,             // C01C: CLI
+		0xB6, 0x80, 0x10, // C01D: LDAA $8010
+		0x84, 0x20, //       C020: ANDA #$20 (column 1)
+		0x26, 0xF9, //       C022: BNE $C01D
+		0x86, 0x40, //       C024: LDAA #$40
+		0xB7, 0x80, 0x12, // C026: STAA $8012 (speaker)
+		0x20, 0xFE, //       C029: BRA $C029
+	},
+	0x040: {
+		0x7C, 0x00, 0x20, // C040: INC $0020
+		0xB6, 0x80, 0x12, // C043: LDAA $8012
+		0x7C, 0x01, 0x00, // C046: INC $0100
+		0x3B, //             C049: RTI
+	},
+	0x3F8: {0xC0, 0x40}, // IRQ vector
+	0x3FE: {0xC0, 0x00}, // reset vector
+}
+
+func newDREAM(t *testing.T) *dream.DREAM {
+	t.Helper()
+
+	rom := make([]byte, 0x400)
+	for addr, code := range testMonitor {
+		copy(rom[addr:], code)
+	}
+
+	d, err := dream.New(bytes.NewReader(rom))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func TestNew(t *testing.T) {
+	if _, err := dream.New(bytes.NewReader(nil)); !errors.Is(err, dream.ErrROMSize) {
+		t.Fatalf("expected ErrROMSize, but got %v", err)
+	}
+
+	if _, err := dream.New(bytes.NewReader(make([]byte, dream.ROMSize+1))); !errors.Is(err, dream.ErrROMSize) {
+		t.Fatalf("expected ErrROMSize, but got %v", err)
+	}
+
+	if d := newDREAM(t); d.CPU.PC != dream.ROMAddr {
+		t.Fatalf("expected to start at the reset vector, but PC is %04X", d.CPU.PC)
+	}
+}
+
+func TestLoadROM(t *testing.T) {
+	d := newDREAM(t)
+
+	if err := d.LoadROM(bytes.NewReader([]byte{0x12, 0x34})); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Read(dream.StartAddr) != 0x12 || d.Read(dream.StartAddr+1) != 0x34 {
+		t.Fatal("expected the program at the start address")
+	}
+
+	if err := d.LoadROM(bytes.NewReader(make([]byte, dream.RAMSize))); !errors.Is(err, chip8.ErrLoadOverflow) {
+		t.Fatalf("expected ErrLoadOverflow, but got %v", err)
+	}
+}
+
+func TestRunFrame(t *testing.T) {
+	d := newDREAM(t)
+
+	for frame := 1; frame <= 3; frame++ {
+		if _, err := d.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+
+		if delay, _ := d.Timers(); int(delay) != frame {
+			t.Fatalf("expected %d interrupts, but got %d", frame, delay)
+		}
+	}
+
+	// 3 = 00000011
+	if d.Pixel(5, 0) || !d.Pixel(6, 0) || !d.Pixel(7, 0) || d.Pixel(8, 0) {
+		t.Fatal("unexpected pixels on the display")
+	}
+
+	img := d.Image()
+	if img.Bounds().Dx() != dream.Width || img.Bounds().Dy() != dream.Height {
+		t.Fatalf("unexpected image size: %v", img.Bounds())
+	}
+}
+
+func TestKeypad(t *testing.T) {
+	d := newDREAM(t)
+
+	// key 5 is on the second row, which is not driven
+	d.PressKey(chip8.Key5)
+	if _, err := d.RunFrame(); err != nil || d.SoundOn() {
+		t.Fatalf("expected the key to be ignored (%v)", err)
+	}
+
+	d.PressKey(chip8.Key1)
+	if !d.IsPressed(chip8.Key1) {
+		t.Fatal("expected the key to be pressed")
+	}
+
+	if _, err := d.RunFrame(); err != nil || !d.SoundOn() {
+		t.Fatalf("expected the key to turn the speaker on (%v)", err)
+	}
+
+	d.ReleaseKey(chip8.Key1)
+	if d.IsPressed(chip8.Key1) || !d.IsPressed(chip8.Key5) {
+		t.Fatal("unexpected keys")
+	}
+}
+
+func TestRunFrameIllegalOpcode(t *testing.T) {
+	d := newDREAM(t)
+	d.CPU.PC = 0x0000 // zero is illegal
+
+	if _, err := d.RunFrame(); !errors.Is(err, dream.ErrIllegalOpcode) {
+		t.Fatalf("expected ErrIllegalOpcode, but got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package dream
+
+// Bits of the control register of a PIA port.
+const (
+	ControlIRQ1     byte = 0x01 // enables the interrupt of the control line 1
+	ControlData     byte = 0x04 // selects the data register, instead of the direction register
+	ControlFlag1    byte = 0x80 // set on a transition of the control line 1
+	controlFlag2    byte = 0x40 // set on a transition of the control line 2
+	controlWritable      = 0x3F // bits of the control register set by the CPU
+)
+
+// PIA is a Motorola 6821 Peripheral Interface Adapter, with two ports
+// of 8 lines. Only the features used by the DREAM 6800 are emulated:
+// the data direction, and the interrupt on the control line 1 of each
+// port.
+type PIA struct {
+	A, B PIAPort
+}
+
+// PIAPort is one of the ports of the PIA.
+type PIAPort struct {
+	Output    byte // output register
+	Direction byte // data direction register (1 means output)
+	Control   byte // control register (see ControlData)
+
+	// Input returns the levels of the lines set as inputs. Nil means
+	// all lines are high.
+	Input func() byte
+}
+
+// Reset clears the registers of both ports.
+func (p *PIA) Reset() {
+	p.A.reset()
+	p.B.reset()
+}
+
+// IRQ reports whether the PIA is requesting an interrupt.
+func (p *PIA) IRQ() bool {
+	return p.A.irq() || p.B.irq()
+}
+
+// Read reads one of the 4 registers of the PIA: the data (or direction)
+// and control registers of port A, then the ones of port B.
+func (p *PIA) Read(reg int) byte {
+	return p.port(reg).read(reg&0x01 != 0)
+}
+
+// Write writes one of the 4 registers of the PIA (see Read).
+func (p *PIA) Write(reg int, value byte) {
+	p.port(reg).write(reg&0x01 != 0, value)
+}
+
+func (p *PIA) port(reg int) *PIAPort {
+	if reg&0x02 != 0 {
+		return &p.B
+	}
+
+	return &p.A
+}
+
+// Lines returns the levels of the lines of the port: the output register
+// on the lines set as outputs, and the input on the others.
+func (port *PIAPort) Lines() byte {
+	input := byte(0xFF)
+	if port.Input != nil {
+		input = port.Input()
+	}
+
+	return port.Output&port.Direction | input&^port.Direction
+}
+
+// Signal signals a transition on the control line 1 of the port, which
+// requests an interrupt if enabled (see ControlIRQ1).
+func (port *PIAPort) Signal() {
+	port.Control |= ControlFlag1
+}
+
+func (port *PIAPort) reset() {
+	port.Output = 0
+	port.Direction = 0
+	port.Control = 0
+}
+
+func (port *PIAPort) irq() bool {
+	return port.Control&(ControlFlag1|ControlIRQ1) == ControlFlag1|ControlIRQ1
+}
+
+// read reads the control register, or the data register (which clears
+// the interrupt flags) or the direction register, depending on bit 2 of
+// the control register.
+func (port *PIAPort) read(control bool) byte {
+	switch {
+	case control:
+		return port.Control
+	case port.Control&ControlData != 0:
+		port.Control &^= ControlFlag1 | controlFlag2
+		return port.Lines()
+	default:
+		return port.Direction
+	}
+}
+
+func (port *PIAPort) write(control bool, value byte) {
+	switch {
+	case control:
+		port.Control = port.Control&^controlWritable | value&controlWritable
+	case port.Control&ControlData != 0:
+		port.Output = value
+	default:
+		port.Direction = value
+	}
+}
@@ -0,0 +1,57 @@
+package dream_test
+
+import (
+	"testing"
+
+	"github.com/ibraimgm/chip8/dream"
+)
+
+func TestPIADirection(t *testing.T) {
+	var p dream.PIA
+	p.A.Input = func() byte { return 0xA0 }
+
+	p.Write(0, 0x0F) // direction, since the control register is zero
+	p.Write(1, dream.ControlData)
+	p.Write(0, 0x35)
+
+	if p.A.Direction != 0x0F || p.A.Output != 0x35 {
+		t.Fatalf("unexpected registers: %+v", p.A)
+	}
+
+	// outputs on the low lines, inputs on the high ones
+	if value := p.Read(0); value != 0xA5 {
+		t.Fatalf("expected 0xA5, but got 0x%02X", value)
+	}
+
+	// port B has no input attached
+	p.Write(3, dream.ControlData)
+	if value := p.Read(2); value != 0xFF {
+		t.Fatalf("expected 0xFF, but got 0x%02X", value)
+	}
+}
+
+func TestPIAInterrupt(t *testing.T) {
+	var p dream.PIA
+
+	p.B.Signal()
+	if p.IRQ() {
+		t.Fatal("expected no interrupt while disabled")
+	}
+
+	p.Write(3, dream.ControlData|dream.ControlIRQ1)
+	if !p.IRQ() || p.Read(3)&dream.ControlFlag1 == 0 {
+		t.Fatal("expected an interrupt")
+	}
+
+	// the flag is read only
+	p.Write(3, dream.ControlData|dream.ControlIRQ1)
+	if !p.IRQ() {
+		t.Fatal("expected the flag to be kept")
+	}
+
+	// reading the data register clears the flag
+	p.Read(2)
+	if p.IRQ() {
+		t.Fatal("expected the interrupt to be cleared")
+	}
+}
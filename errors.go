@@ -0,0 +1,79 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Registers is a snapshot of the registers of the emulator.
+type Registers struct {
+	V     [16]byte
+	I     uint16
+	DT    byte
+	ST    byte
+	PC    uint16
+	SP    int
+	Stack []uint16 // the calls on the stack, from the outermost
+}
+
+// Registers returns a snapshot of the current registers.
+func (c *Emulator) Registers() Registers {
+	stack := make([]uint16, c.SP)
+	for i := range stack {
+		stack[i] = c.stackEntry(i)
+	}
+
+	return Registers{
+		V:     c.V,
+		I:     c.I,
+		DT:    c.DT,
+		ST:    c.ST,
+		PC:    c.PC,
+		SP:    c.SP,
+		Stack: stack,
+	}
+}
+
+// ExecError is the error returned by Execute when an instruction fails.
+// It wraps the error of the instruction (like ErrInvalidAddress or a
+// NoOpError), so errors.Is and errors.As see through it, and records
+// where the failure happened.
+//
+// The halting errors (ErrInputHalt and ErrDelayHalt) are not failures,
+// and are returned as they are.
+type ExecError struct {
+	Err         error     // error of the instruction
+	PC          uint16    // address of the instruction
+	Opcode      uint16    // the instruction itself
+	Disassembly string    // the instruction, disassembled
+	Executed    int       // instructions run by the failing call (Execute or RunCycles) before the failure
+	Registers   Registers // registers after the failure
+}
+
+func (e *ExecError) Error() string {
+	return fmt.Sprintf("0x%03X: %s (0x%04X): %v", e.PC, e.Disassembly, e.Opcode, e.Err)
+}
+
+// Unwrap returns the error of the instruction.
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// fail wraps the error of the instruction at pc on an ExecError, and
+// applies the error policy, returning nil if the error is ignored.
+func (c *Emulator) fail(err error, pc uint16, a byte, b byte, executed int) error {
+	if errors.Is(err, ErrInputHalt) || errors.Is(err, ErrDelayHalt) {
+		return err
+	}
+
+	opcode := uint16(a)<<8 | uint16(b)
+
+	return c.Policy.apply(&ExecError{
+		Err:         err,
+		PC:          pc,
+		Opcode:      opcode,
+		Disassembly: c.machine().Disassemble(opcode),
+		Executed:    executed,
+		Registers:   c.Registers(),
+	})
+}
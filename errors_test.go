@@ -0,0 +1,102 @@
+package chip8_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+func TestExecError(t *testing.T) {
+	tests := []struct {
+		name     string
+		machine  *chip8.Machine
+		rom      []byte
+		i        uint16
+		target   error
+		pc       uint16
+		opcode   uint16
+		executed int
+		asm      string
+	}{
+		{"Unknown", chip8.COSMACVIP, []byte{0x61, 0x05, 0x81, 0x2F}, 0, nil, 0x200 + 2, 0x812F, 1, "DW 0x812F"},
+		{"InvalidAddress", chip8.COSMACVIP, []byte{0x61, 0x05, 0x6E, 0x10, 0xD0, 0x0F}, 0xFFF0, chip8.ErrInvalidAddress, 0x200 + 4, 0xD00F, 2, "DRW V0, V0, 0xF"},
+	}
+
+	for _, test := range tests {
+		for _, engine := range engines {
+			t.Run(test.name+"/"+engine.name, func(t *testing.T) {
+				c := chip8.NewEmulator(test.machine)
+				c.Engine = engine.engine
+				if err := c.LoadROM(bytes.NewReader(test.rom)); err != nil {
+					t.Fatal(err)
+				}
+
+				c.I = test.i
+
+				_, err := c.Execute(10)
+
+				var execErr *chip8.ExecError
+				if !errors.As(err, &execErr) {
+					t.Fatalf("expected ExecError, but got %v", err)
+				}
+
+				if test.target != nil && !errors.Is(err, test.target) {
+					t.Fatalf("expected %v, but got %v", test.target, err)
+				}
+
+				if test.target == nil {
+					var noop chip8.NoOpError
+					if !errors.As(err, &noop) {
+						t.Fatalf("expected NoOpError, but got %v", err)
+					}
+				}
+
+				if execErr.PC != test.pc || execErr.Opcode != test.opcode || execErr.Executed != test.executed {
+					t.Fatalf("unexpected location: PC=0x%03X, opcode=0x%04X, executed=%d", execErr.PC, execErr.Opcode, execErr.Executed)
+				}
+
+				if execErr.Disassembly != test.asm || !strings.Contains(err.Error(), test.asm) {
+					t.Fatalf("unexpected disassembly: %q", execErr.Disassembly)
+				}
+
+				if execErr.Registers.V[1] != c.V[1] || execErr.Registers.PC != c.PC {
+					t.Fatalf("unexpected registers: %+v", execErr.Registers)
+				}
+			})
+		}
+	}
+}
+
+func TestExecErrorRunCycles(t *testing.T) {
+	c := chip8.NewEmulator(chip8.COSMACVIP)
+	if err := c.LoadROM(bytes.NewReader([]byte{0x61, 0x05, 0x62, 0x06, 0x81, 0x2F})); err != nil {
+		t.Fatal(err)
+	}
+
+	executed, err := c.RunCycles(100000)
+
+	var execErr *chip8.ExecError
+	if !errors.As(err, &execErr) {
+		t.Fatalf("expected ExecError, but got %v", err)
+	}
+
+	if executed != 2 || execErr.Executed != 2 || execErr.PC != 0x200+4 {
+		t.Fatalf("expected to fail at 0x204 after 2 instructions, but failed at 0x%03X after %d (returned %d)", execErr.PC, execErr.Executed, executed)
+	}
+}
+
+func TestExecErrorHalt(t *testing.T) {
+	c := chip8.NewEmulator(chip8.CHIP8X)
+	if err := c.LoadROM(bytes.NewReader([]byte{0xF0, 0xFB})); err != nil {
+		t.Fatal(err)
+	}
+
+	// halting is not a failure
+	var execErr *chip8.ExecError
+	if _, err := c.Execute(1); err != chip8.ErrInputHalt || errors.As(err, &execErr) {
+		t.Fatalf("expected ErrInputHalt, but got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package chip8
+
+import "errors"
+
+// ErrDelayHalt is returned when the emulator is stopped waiting for the
+// delay timer to reach zero, like in the Fx4F instruction of the CHIP-8E.
+// The delay timer is updated by Tick, so the emulation will continue after
+// enough calls to it.
+var ErrDelayHalt = errors.New("awaiting for delay timer")
+
+// Extension is an additional set of instructions that can be plugged
+// into a machine, without changing the built-in instructions.
+//
+// The instructions of the extensions take precedence over the ones of
+// the machine instruction sets, so an extension may also replace a
+// built-in instruction.
+type Extension struct {
+	ID           string
+	Name         string
+	Instructions []Instruction
+}
+
+// CHIP8E is the CHIP-8E extension, by Gilles Detillieux, which adds
+// register range load/store, relative jumps, output and delay instructions.
+// The relative jumps (BBnn and BFnn) are relative to the address of the
+// jump instruction itself.
+var CHIP8E = &Extension{
+	ID:   "chip8e",
+	Name: "CHIP-8E",
+	Instructions: []Instruction{
+		{Mask: 0xFFFF, Pattern: 0x00ED, Mnemonic: "STOP", Format: "STOP", Cycles: 1, Branch: true, Exec: opStop},
+		{Mask: 0xF00F, Pattern: 0x5001, Mnemonic: "SGT", Format: "SGT V{x}, V{y}", Cycles: 1, Branch: true, Exec: opSgt},
+		{Mask: 0xF00F, Pattern: 0x5002, Mnemonic: "LD", Format: "LD [I], V{x}-V{y}", Cycles: 1, Exec: opStoreRange},
+		{Mask: 0xF00F, Pattern: 0x5003, Mnemonic: "LD", Format: "LD V{x}-V{y}, [I]", Cycles: 1, Exec: opLoadRange},
+		{Mask: 0xFF00, Pattern: 0xBB00, Mnemonic: "JB", Format: "JB {nn}", Cycles: 1, Branch: true, Exec: opJumpBack},
+		{Mask: 0xFF00, Pattern: 0xBF00, Mnemonic: "JF", Format: "JF {nn}", Cycles: 1, Branch: true, Exec: opJumpForward},
+		{Mask: 0xF0FF, Pattern: 0xF003, Mnemonic: "OUT", Format: "OUT V{x}", Cycles: 1, Exec: opOut},
+		{Mask: 0xF0FF, Pattern: 0xF01B, Mnemonic: "SKIP", Format: "SKIP V{x}", Cycles: 1, Branch: true, Exec: opSkipBytes},
+		{Mask: 0xF0FF, Pattern: 0xF04F, Mnemonic: "DELAY", Format: "DELAY V{x}", Cycles: 1, Branch: true, Exec: opDelay},
+	},
+}
+
+// CHIP8I is the CHIP-8I extension, which adds instructions to read
+// from the input port.
+var CHIP8I = &Extension{
+	ID:   "chip8i",
+	Name: "CHIP-8I",
+	Instructions: []Instruction{
+		{Mask: 0xF0FF, Pattern: 0xF0E3, Mnemonic: "IN", Format: "IN V{x}", Cycles: 1, Branch: true, Exec: opIn},
+		{Mask: 0xF0FF, Pattern: 0xF0E7, Mnemonic: "INNW", Format: "INNW V{x}", Cycles: 1, Exec: opInNoWait},
+	},
+}
+
+// list of built-in extensions
+var extensions = []*Extension{CHIP8E, CHIP8I}
+
+// FindExtension returns the built-in extension with the given ID, or
+// nil if there is no such extension.
+func FindExtension(id string) *Extension {
+	for _, ext := range extensions {
+		if ext.ID == id {
+			return ext
+		}
+	}
+
+	return nil
+}
+
+// WithExtensions returns a copy of the machine, with the given
+// extensions added to it.
+func (m *Machine) WithExtensions(exts ...*Extension) *Machine {
+	copied := *m
+	copied.Extensions = append(append([]*Extension{}, m.Extensions...), exts...)
+	return &copied
+}
+
+// instructions returns pointers to the instructions of the extension.
+func (ext *Extension) instructions() []*Instruction {
+	list := make([]*Instruction, len(ext.Instructions))
+	for i := range ext.Instructions {
+		list[i] = &ext.Instructions[i]
+	}
+
+	return list
+}
+
+func opStop(c *Emulator, a byte, b byte) error {
+	c.PC -= 2
+	return ErrStopped
+}
+
+func opSgt(c *Emulator, a byte, b byte) error {
+	if c.V[a&lsnMask] > c.V[b&msnMask>>4] {
+		c.skip()
+	}
+
+	return nil
+}
+
+func opStoreRange(c *Emulator, a byte, b byte) error {
+	return c.storeRange(a&lsnMask, b&msnMask>>4)
+}
+
+func opLoadRange(c *Emulator, a byte, b byte) error {
+	return c.loadRange(a&lsnMask, b&msnMask>>4)
+}
+
+func opJumpBack(c *Emulator, a byte, b byte) error {
+	return c.jump(int(c.PC) - 2 - int(b))
+}
+
+func opJumpForward(c *Emulator, a byte, b byte) error {
+	return c.jump(int(c.PC) + int(b) - 2)
+}
+
+func opSkipBytes(c *Emulator, a byte, b byte) error {
+	c.PC += uint16(c.V[a&lsnMask])
+	return nil
+}
+
+func opDelay(c *Emulator, a byte, b byte) error {
+	return c.delay(a & lsnMask)
+}
+
+func opInNoWait(c *Emulator, a byte, b byte) error {
+	if c.Port != nil {
+		if value, ok := c.Port.In(); ok {
+			c.V[a&lsnMask] = value
+		}
+	}
+
+	return nil
+}
+
+// storeRange stores the registers Vx to Vy on the memory pointed by I,
+// advancing I past the stored bytes.
+func (c *Emulator) storeRange(x, y byte) error {
+	if x > y {
+		return nil
+	}
+
+	if err := c.store(int(c.I), c.V[x:y+1]...); err != nil {
+		return err
+	}
+
+	c.I += uint16(y-x) + 1
+	return nil
+}
+
+// loadRange loads the registers Vx to Vy from the memory pointed by I,
+// advancing I past the loaded bytes.
+func (c *Emulator) loadRange(x, y byte) error {
+	if x > y {
+		return nil
+	}
+
+	data, err := c.load(int(c.I), int(y-x)+1)
+	if err != nil {
+		return err
+	}
+
+	copy(c.V[x:y+1], data)
+	c.I += uint16(len(data))
+	return nil
+}
+
+// delay sets the delay timer to Vx and halts until it reaches zero.
+func (c *Emulator) delay(x byte) error {
+	if !c.delaying {
+		c.DT = c.V[x]
+		c.delaying = true
+	}
+
+	if c.DT > 0 {
+		c.PC -= 2
+		return ErrDelayHalt
+	}
+
+	c.delaying = false
+	return nil
+}
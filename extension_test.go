@@ -0,0 +1,207 @@
+package chip8_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+func newExtendedEmulator(t *testing.T, rom []byte, exts ...*chip8.Extension) *chip8.Emulator {
+	t.Helper()
+
+	c := chip8.NewEmulator(chip8.COSMACVIP.WithExtensions(exts...))
+	if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestWithExtensions(t *testing.T) {
+	m := chip8.COSMACVIP.WithExtensions(chip8.CHIP8E)
+
+	if len(chip8.COSMACVIP.Extensions) != 0 {
+		t.Fatal("adding extensions should not change the original machine")
+	}
+
+	if len(m.Extensions) != 1 || m.Extensions[0] != chip8.CHIP8E {
+		t.Fatalf("expected machine to have the CHIP-8E extension, but found %v", m.Extensions)
+	}
+
+	if m.StartAddr != chip8.COSMACVIP.StartAddr {
+		t.Fatal("the copy should keep the machine settings")
+	}
+}
+
+func TestFindExtension(t *testing.T) {
+	if ext := chip8.FindExtension("chip8i"); ext != chip8.CHIP8I {
+		t.Fatalf("expected to find CHIP-8I extension, but found %v", ext)
+	}
+
+	if ext := chip8.FindExtension("unknown"); ext != nil {
+		t.Fatalf("expected no extension, but found %v", ext)
+	}
+}
+
+func TestCHIP8ERanges(t *testing.T) {
+	rom := []byte{
+		0x61, 0x11, // V1 = 0x11
+		0x62, 0x22, // V2 = 0x22
+		0x63, 0x33, // V3 = 0x33
+		0x51, 0x32, // LD [I], V1-V3
+		0x84, 0x10, // V4 = V1
+		0x54, 0x21, // SGT V4, V2 (not skipped)
+		0x65, 0x01, // V5 = 1
+		0x53, 0x11, // SGT V3, V1 (skipped)
+		0x66, 0x01, // V6 = 1
+		0x57, 0x93, // LD V7-V9, [I]
+	}
+
+	c := newExtendedEmulator(t, rom, chip8.CHIP8E)
+	c.I = 0x300
+
+	if _, err := c.Execute(5); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(c.Memory[0x300:0x303], []byte{0x11, 0x22, 0x33}) {
+		t.Fatalf("expected registers to be stored, but found %v", c.Memory[0x300:0x303])
+	}
+
+	if c.I != 0x303 {
+		t.Fatalf("expected I to be 0x303, but was 0x%03X", c.I)
+	}
+
+	c.I = 0x300
+	if _, err := c.Execute(4); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, v := range []byte{0, 0x11, 0x22, 0x33, 0x11, 1, 0, 0x11, 0x22, 0x33} {
+		if c.V[i] != v {
+			t.Fatalf("expected register V%X to be 0x%02X, but was 0x%02X", i, v, c.V[i])
+		}
+	}
+}
+
+func TestCHIP8EJumps(t *testing.T) {
+	rom := []byte{
+		0xBF, 0x06, // JF 6 (to 0x206)
+		0x61, 0x01, // V1 = 1 (target of JB)
+		0xBF, 0x08, // JF 8 (to 0x20C)
+		0xBB, 0x04, // JB 4 (to 0x202)
+		0x60, 0x01, // V0 = 1 (skipped)
+		0x60, 0x02, // V0 = 2 (skipped)
+		0x62, 0x02, // V2 = 2
+		0x6A, 0x02, // VA = 2
+		0xFA, 0x1B, // SKIP VA (skips the next instruction)
+		0x63, 0x03, // V3 = 3 (skipped)
+		0x64, 0x04, // V4 = 4
+	}
+
+	c := newExtendedEmulator(t, rom, chip8.CHIP8E)
+	if _, err := c.Execute(8); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, v := range []byte{0, 1, 2, 0, 4} {
+		if c.V[i] != v {
+			t.Fatalf("expected register V%X to be 0x%02X, but was 0x%02X", i, v, c.V[i])
+		}
+	}
+}
+
+func TestCHIP8EStop(t *testing.T) {
+	c := newExtendedEmulator(t, []byte{0x60, 0x01, 0x00, 0xED}, chip8.CHIP8E)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Execute(2); !errors.Is(err, chip8.ErrStopped) {
+			t.Fatalf("expected stop error, but got %v", err)
+		}
+	}
+
+	if c.PC != chip8.AddrStart+2 {
+		t.Fatalf("expected PC to stay on the stop instruction, but was 0x%03X", c.PC)
+	}
+}
+
+func TestCHIP8EDelay(t *testing.T) {
+	rom := []byte{
+		0x60, 0x02, // V0 = 2
+		0xF0, 0x4F, // DELAY V0
+		0x61, 0x01, // V1 = 1
+	}
+
+	c := newExtendedEmulator(t, rom, chip8.CHIP8E)
+
+	expectDelay := func(expected byte) {
+		t.Helper()
+
+		if _, err := c.Execute(3); !errors.Is(err, chip8.ErrDelayHalt) {
+			t.Fatalf("expected delay halt error, but got %v", err)
+		}
+
+		if c.DT != expected {
+			t.Fatalf("expected delay timer to be %d, but was %d", expected, c.DT)
+		}
+	}
+
+	expectDelay(2)
+	expectDelay(2) // executing again should not restart the timer
+	c.Tick()
+	expectDelay(1)
+
+	c.Tick()
+	if _, err := c.Execute(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.V[1] != 1 {
+		t.Fatal("expected execution to continue after the delay")
+	}
+}
+
+func TestCHIP8EOutput(t *testing.T) {
+	port := &testPort{}
+	c := newExtendedEmulator(t, []byte{0x65, 0x42, 0xF5, 0x03}, chip8.CHIP8E)
+	c.Port = port
+
+	if _, err := c.Execute(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(port.out) != 1 || port.out[0] != 0x42 {
+		t.Fatalf("expected 0x42 to be sent to the port, but found %v", port.out)
+	}
+}
+
+func TestCHIP8IInput(t *testing.T) {
+	rom := []byte{
+		0xF1, 0xE7, // IN V1 (no wait)
+		0xF2, 0xE3, // IN V2 (wait)
+	}
+
+	port := &testPort{}
+	c := newExtendedEmulator(t, rom, chip8.CHIP8I)
+	c.Port = port
+
+	n, err := c.Execute(2)
+	if !errors.Is(err, chip8.ErrInputHalt) {
+		t.Fatalf("expected input halt error, but got %v", err)
+	}
+
+	if n != 1 {
+		t.Fatalf("expected to execute 1 instruction, but executed %d", n)
+	}
+
+	port.in = []byte{0x99}
+	if _, err := c.Execute(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.V[1] != 0 || c.V[2] != 0x99 {
+		t.Fatalf("expected V1 = 0x00 and V2 = 0x99, but found 0x%02X and 0x%02X", c.V[1], c.V[2])
+	}
+}
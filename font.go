@@ -0,0 +1,258 @@
+package chip8
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// AddrFont is the font address used by most modern interpreters, and
+// assumed by some recent programs, instead of AddrSprite.
+const AddrFont = 0x050
+
+// Sizes of the glyphs of the fonts, in bytes.
+const (
+	SmallGlyphSize = 5  // 4x5 pixels (the first 4 bits of each byte)
+	BigGlyphSize   = 10 // 8x10 pixels
+)
+
+// ErrFontSize is returned when loading a font with an invalid size.
+var ErrFontSize = errors.New("invalid font size")
+
+// Font is the set of hexadecimal digits built into a machine, used by
+// the Fx29 instruction (and by Fx30, for the big digits of the SUPER-CHIP
+// and the XO-CHIP).
+//
+// The small digits are loaded at the font address of the machine, and
+// the big digits, if any, right after them.
+type Font struct {
+	ID    string // short, unique identifier
+	Name  string // human readable name
+	Small []byte // 16 small digits (0 to F)
+	Big   []byte // big digits: none, 10 (0 to 9) or 16 (0 to F)
+}
+
+// CHIP48Font is the font of the CHIP-48, which became the font of most
+// interpreters. It is the font of the built-in machines, unless noted.
+var CHIP48Font = &Font{
+	ID:   "chip48",
+	Name: "CHIP-48",
+	Small: []byte{
+		0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
+		0x20, 0x60, 0x20, 0x20, 0x70, // 1
+		0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
+		0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
+		0x90, 0x90, 0xF0, 0x10, 0x10, // 4
+		0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
+		0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
+		0xF0, 0x10, 0x20, 0x40, 0x40, // 7
+		0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
+		0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
+		0xF0, 0x90, 0xF0, 0x90, 0x90, // A
+		0xE0, 0x90, 0xE0, 0x90, 0xE0, // B
+		0xF0, 0x80, 0x80, 0x80, 0xF0, // C
+		0xE0, 0x90, 0x90, 0x90, 0xE0, // D
+		0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
+		0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+	},
+}
+
+// VIPFont is the font of the original interpreter of the COSMAC VIP. The
+// built-in machines do not use it; select it with Machine.WithFont.
+var VIPFont = &Font{
+	ID:   "vip",
+	Name: "COSMAC VIP",
+	Small: []byte{
+		0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
+		0x60, 0x20, 0x20, 0x20, 0x70, // 1
+		0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
+		0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
+		0xA0, 0xA0, 0xF0, 0x20, 0x20, // 4
+		0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
+		0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
+		0xF0, 0x10, 0x10, 0x10, 0x10, // 7
+		0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
+		0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
+		0xF0, 0x90, 0xF0, 0x90, 0x90, // A
+		0xF0, 0x50, 0x70, 0x50, 0xF0, // B
+		0xF0, 0x80, 0x80, 0x80, 0xF0, // C
+		0xF0, 0x50, 0x50, 0x50, 0xF0, // D
+		0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
+		0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+	},
+}
+
+// ETI660Font is the font of the ETI 660, with 3 pixels wide digits.
+var ETI660Font = &Font{
+	ID:   "eti660",
+	Name: "ETI 660",
+	Small: []byte{
+		0xE0, 0xA0, 0xA0, 0xA0, 0xE0, // 0
+		0x20, 0x20, 0x20, 0x20, 0x20, // 1
+		0xE0, 0x20, 0xE0, 0x80, 0xE0, // 2
+		0xE0, 0x20, 0xE0, 0x20, 0xE0, // 3
+		0xA0, 0xA0, 0xE0, 0x20, 0x20, // 4
+		0xE0, 0x80, 0xE0, 0x20, 0xE0, // 5
+		0xE0, 0x80, 0xE0, 0xA0, 0xE0, // 6
+		0xE0, 0x20, 0x20, 0x20, 0x20, // 7
+		0xE0, 0xA0, 0xE0, 0xA0, 0xE0, // 8
+		0xE0, 0xA0, 0xE0, 0x20, 0xE0, // 9
+		0xE0, 0xA0, 0xE0, 0xA0, 0xA0, // A
+		0x80, 0x80, 0xE0, 0xA0, 0xE0, // B
+		0xE0, 0x80, 0x80, 0x80, 0xE0, // C
+		0x20, 0x20, 0xE0, 0xA0, 0xE0, // D
+		0xE0, 0x80, 0xE0, 0x80, 0xE0, // E
+		0xE0, 0x80, 0xC0, 0x80, 0x80, // F
+	},
+}
+
+// DREAMFont is the font of CHIPOS, the interpreter of the DREAM 6800.
+var DREAMFont = &Font{
+	ID:   "dream",
+	Name: "DREAM 6800",
+	Small: []byte{
+		0xE0, 0xA0, 0xA0, 0xA0, 0xE0, // 0
+		0x40, 0x40, 0x40, 0x40, 0x40, // 1
+		0xE0, 0x20, 0xE0, 0x80, 0xE0, // 2
+		0xE0, 0x20, 0xE0, 0x20, 0xE0, // 3
+		0x80, 0xA0, 0xA0, 0xE0, 0x20, // 4
+		0xE0, 0x80, 0xE0, 0x20, 0xE0, // 5
+		0xE0, 0x80, 0xE0, 0xA0, 0xE0, // 6
+		0xE0, 0x20, 0x20, 0x20, 0x20, // 7
+		0xE0, 0xA0, 0xE0, 0xA0, 0xE0, // 8
+		0xE0, 0xA0, 0xE0, 0x20, 0xE0, // 9
+		0xE0, 0xA0, 0xE0, 0xA0, 0xA0, // A
+		0xC0, 0xA0, 0xE0, 0xA0, 0xC0, // B
+		0xE0, 0x80, 0x80, 0x80, 0xE0, // C
+		0xC0, 0xA0, 0xA0, 0xA0, 0xC0, // D
+		0xE0, 0x80, 0xE0, 0x80, 0xE0, // E
+		0xE0, 0x80, 0xC0, 0x80, 0x80, // F
+	},
+}
+
+// FishNChipsFont is the font of the fish'n'chips emulator.
+var FishNChipsFont = &Font{
+	ID:   "fish",
+	Name: "fish'n'chips",
+	Small: []byte{
+		0x60, 0xA0, 0xA0, 0xA0, 0xC0, // 0
+		0x40, 0xC0, 0x40, 0x40, 0xE0, // 1
+		0xC0, 0x20, 0x40, 0x80, 0xE0, // 2
+		0xC0, 0x20, 0x40, 0x20, 0xC0, // 3
+		0x20, 0xA0, 0xE0, 0x20, 0x20, // 4
+		0xE0, 0x80, 0xC0, 0x20, 0xC0, // 5
+		0x40, 0x80, 0xC0, 0xA0, 0x40, // 6
+		0xE0, 0x20, 0x60, 0x40, 0x40, // 7
+		0x40, 0xA0, 0x40, 0xA0, 0x40, // 8
+		0x40, 0xA0, 0x60, 0x20, 0x40, // 9
+		0x40, 0xA0, 0xE0, 0xA0, 0xA0, // A
+		0xC0, 0xA0, 0xC0, 0xA0, 0xC0, // B
+		0x60, 0x80, 0x80, 0x80, 0x60, // C
+		0xC0, 0xA0, 0xA0, 0xA0, 0xC0, // D
+		0xE0, 0x80, 0xC0, 0x80, 0xE0, // E
+		0xE0, 0x80, 0xC0, 0x80, 0x80, // F
+	},
+}
+
+// SCHIPFont is the font of the SUPER-CHIP 1.1: the CHIP-48 digits, plus
+// big digits from 0 to 9.
+var SCHIPFont = &Font{
+	ID:    "schip",
+	Name:  "SUPER-CHIP",
+	Small: CHIP48Font.Small,
+	Big: []byte{
+		0x3C, 0x7E, 0xE7, 0xC3, 0xC3, 0xC3, 0xC3, 0xE7, 0x7E, 0x3C, // 0
+		0x18, 0x38, 0x58, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x3C, // 1
+		0x3E, 0x7F, 0xC3, 0x06, 0x0C, 0x18, 0x30, 0x60, 0xFF, 0xFF, // 2
+		0x3C, 0x7E, 0xC3, 0x03, 0x0E, 0x0E, 0x03, 0xC3, 0x7E, 0x3C, // 3
+		0x06, 0x0E, 0x1E, 0x36, 0x66, 0xC6, 0xFF, 0xFF, 0x06, 0x06, // 4
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFC, 0xFE, 0x03, 0xC3, 0x7E, 0x3C, // 5
+		0x3E, 0x7C, 0xC0, 0xC0, 0xFC, 0xFE, 0xC3, 0xC3, 0x7E, 0x3C, // 6
+		0xFF, 0xFF, 0x03, 0x06, 0x0C, 0x18, 0x30, 0x60, 0x60, 0x60, // 7
+		0x3C, 0x7E, 0xC3, 0xC3, 0x7E, 0x7E, 0xC3, 0xC3, 0x7E, 0x3C, // 8
+		0x3C, 0x7E, 0xC3, 0xC3, 0x7F, 0x3F, 0x03, 0x03, 0x3E, 0x7C, // 9
+	},
+}
+
+// XOCHIPFont is the font of Octo: the CHIP-48 digits, plus big digits
+// from 0 to F.
+var XOCHIPFont = &Font{
+	ID:    "xochip",
+	Name:  "XO-CHIP",
+	Small: CHIP48Font.Small,
+	Big: []byte{
+		0xFF, 0xFF, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, // 0
+		0x18, 0x78, 0x78, 0x18, 0x18, 0x18, 0x18, 0x18, 0xFF, 0xFF, // 1
+		0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, // 2
+		0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 3
+		0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0x03, 0x03, // 4
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 5
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, // 6
+		0xFF, 0xFF, 0x03, 0x03, 0x06, 0x0C, 0x18, 0x18, 0x18, 0x18, // 7
+		0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, // 8
+		0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 9
+		0x7E, 0xFF, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xC3, // A
+		0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, // B
+		0x3C, 0xFF, 0xC3, 0xC0, 0xC0, 0xC0, 0xC0, 0xC3, 0xFF, 0x3C, // C
+		0xFC, 0xFE, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFE, 0xFC, // D
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, // E
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xC0, 0xC0, // F
+	},
+}
+
+// Fonts is the list of all built-in fonts.
+var Fonts = []*Font{CHIP48Font, VIPFont, ETI660Font, DREAMFont, FishNChipsFont, SCHIPFont, XOCHIPFont}
+
+// FindFont returns the built-in font with the given ID, or nil if there
+// is no such font.
+func FindFont(id string) *Font {
+	for _, f := range Fonts {
+		if f.ID == id {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// LoadFont reads a font from r: the 80 bytes of the small digits,
+// optionally followed by 100 or 160 bytes of big digits. Any other size
+// returns ErrFontSize.
+func LoadFont(id string, r io.Reader) (*Font, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	small := 16 * SmallGlyphSize
+	switch len(data) - small {
+	case 0, 10 * BigGlyphSize, 16 * BigGlyphSize:
+	default:
+		return nil, ErrFontSize
+	}
+
+	f := &Font{ID: id, Name: id, Small: data[:small]}
+	if len(data) > small {
+		f.Big = data[small:]
+	}
+
+	return f, nil
+}
+
+// WithFont returns a copy of the machine, using the given font at addr.
+func (m *Machine) WithFont(f *Font, addr int) *Machine {
+	copied := *m
+	copied.Font = f
+	copied.SpriteAddr = addr
+	return &copied
+}
+
+// fontAddr returns the address of the digit of the font, small or big.
+func (c *Emulator) fontAddr(digit byte, big bool) uint16 {
+	m := c.machine()
+	if big && m.Font != nil {
+		return uint16(m.SpriteAddr + len(m.Font.Small) + int(digit&lsnMask)*BigGlyphSize)
+	}
+
+	return uint16(m.SpriteAddr + int(digit&lsnMask)*SmallGlyphSize)
+}
@@ -0,0 +1,127 @@
+package chip8_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+func TestFindFont(t *testing.T) {
+	for _, f := range chip8.Fonts {
+		if found := chip8.FindFont(f.ID); found != f {
+			t.Fatalf("expected to find font %s, but found %v", f.ID, found)
+		}
+
+		if len(f.Small) != 16*chip8.SmallGlyphSize {
+			t.Fatalf("expected 16 small digits on font %s, but found %d bytes", f.ID, len(f.Small))
+		}
+
+		if big := len(f.Big) / chip8.BigGlyphSize; big != 0 && big != 10 && big != 16 {
+			t.Fatalf("unexpected number of big digits on font %s: %d", f.ID, big)
+		}
+	}
+
+	if f := chip8.FindFont("unknown"); f != nil {
+		t.Fatalf("expected no font, but found %v", f)
+	}
+}
+
+func TestLoadFont(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		big  int
+		err  error
+	}{
+		{"Small", 80, 0, nil},
+		{"SCHIP", 180, 100, nil},
+		{"XOCHIP", 240, 160, nil},
+		{"Empty", 0, 0, chip8.ErrFontSize},
+		{"Short", 79, 0, chip8.ErrFontSize},
+		{"Partial", 90, 0, chip8.ErrFontSize},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := chip8.LoadFont("custom", bytes.NewReader(make([]byte, test.size)))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, but got %v", test.err, err)
+			}
+
+			if err == nil && (f.ID != "custom" || len(f.Small) != 80 || len(f.Big) != test.big) {
+				t.Fatalf("expected 80 bytes of small and %d of big digits, but got %d and %d", test.big, len(f.Small), len(f.Big))
+			}
+		})
+	}
+}
+
+func TestWithFont(t *testing.T) {
+	m := chip8.SCHIP.WithFont(chip8.XOCHIPFont, chip8.AddrFont)
+	if chip8.SCHIP.Font != chip8.SCHIPFont || chip8.SCHIP.SpriteAddr != chip8.AddrSprite {
+		t.Fatal("expected the original machine to be unchanged")
+	}
+
+	c := chip8.NewEmulator(m)
+	for i, b := range chip8.XOCHIPFont.Small {
+		if c.Memory[chip8.AddrFont+i] != b {
+			t.Fatalf("expected small digits at 0x%X", chip8.AddrFont)
+		}
+	}
+
+	bigAddr := chip8.AddrFont + len(chip8.XOCHIPFont.Small)
+	for i, b := range chip8.XOCHIPFont.Big {
+		if c.Memory[bigAddr+i] != b {
+			t.Fatalf("expected big digits at 0x%X", bigAddr)
+		}
+	}
+
+	if c.Memory[chip8.AddrSprite+len(chip8.XOCHIPFont.Small)+len(chip8.XOCHIPFont.Big)] != 0 {
+		t.Fatal("expected no font at the default address")
+	}
+}
+
+func TestOpLdFont(t *testing.T) {
+	tests := []struct {
+		name     string
+		machine  *chip8.Machine
+		rom      []byte
+		expected int
+	}{
+		{"Small", chip8.COSMACVIP, []byte{0x60, 0x0A, 0xF0, 0x29}, chip8.AddrSprite + 0xA*chip8.SmallGlyphSize},
+		{"SmallMasked", chip8.COSMACVIP, []byte{0x60, 0x1A, 0xF0, 0x29}, chip8.AddrSprite + 0xA*chip8.SmallGlyphSize},
+		{"SmallModern", chip8.COSMACVIP.WithFont(chip8.VIPFont, chip8.AddrFont), []byte{0x60, 0x03, 0xF0, 0x29}, chip8.AddrFont + 3*chip8.SmallGlyphSize},
+		{"BigSCHIP", chip8.SCHIP, []byte{0x60, 0x07, 0xF0, 0x30}, chip8.AddrSprite + 80 + 7*chip8.BigGlyphSize},
+		{"BigXOCHIP", chip8.XOCHIP, []byte{0x60, 0x0F, 0xF0, 0x30}, chip8.AddrSprite + 80 + 0xF*chip8.BigGlyphSize},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := chip8.NewEmulator(test.machine)
+			if err := c.LoadROM(bytes.NewReader(test.rom)); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := c.Execute(2); err != nil {
+				t.Fatal(err)
+			}
+
+			if int(c.I) != test.expected {
+				t.Fatalf("expected I to be 0x%X, but was 0x%X", test.expected, c.I)
+			}
+		})
+	}
+}
+
+func TestOpLdBigFontUnsupported(t *testing.T) {
+	c := chip8.NewEmulator(chip8.COSMACVIP)
+	if err := c.LoadROM(bytes.NewReader([]byte{0xF0, 0x30})); err != nil {
+		t.Fatal(err)
+	}
+
+	var noop chip8.NoOpError
+	if _, err := c.Execute(1); !errors.As(err, &noop) {
+		t.Fatalf("expected F030 to be a noop on COSMAC VIP, but got %v", err)
+	}
+}
@@ -1,3 +1,3 @@
 module github.com/ibraimgm/chip8
 
-go 1.15
+go 1.16
@@ -0,0 +1,241 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrDuplicateInstruction is returned when registering an instruction
+// with the same mask and pattern of one already in the instruction set.
+var ErrDuplicateInstruction = errors.New("instruction already registered")
+
+// ErrInstructionSet is returned when registering an instruction on a
+// value that is not a single instruction set (ex: InstrCHIP8|InstrSCHIP).
+var ErrInstructionSet = errors.New("invalid instruction set")
+
+// Instruction describes a single instruction (or a family of instructions
+// that share the same handler). An opcode matches the instruction when
+// opcode & Mask == Pattern; when more than one instruction matches, the
+// one with more bits on the mask wins.
+//
+// Instructions that may change the program counter, other than advancing
+// it to the next instruction (jumps, skips, calls, or halting instructions
+// that rewind it) must set Branch, so the EngineRecompiler ends the basic
+// blocks on them.
+//
+// Format is used to disassemble the instruction, with the fields of the
+// opcode replaced by their (hexadecimal) values:
+//
+//	{x}   the second nibble (ex: "LD V{x}, {nn}")
+//	{y}   the third nibble
+//	{n}   the last nibble
+//	{nn}  the last byte
+//	{nnn} the last 12 bits
+type Instruction struct {
+	Mask     uint16
+	Pattern  uint16
+	Mnemonic string                                  // short name, ex: "LD"
+	Format   string                                  // disassembly format
+	Cycles   int                                     // cost of the instruction, in cycles
+	Branch   bool                                    // whether the instruction changes the flow
+	Exec     func(c *Emulator, a byte, b byte) error // the instruction handler
+}
+
+// Matches reports whether the opcode is an instance of the instruction.
+func (ins *Instruction) Matches(opcode uint16) bool {
+	return opcode&ins.Mask == ins.Pattern
+}
+
+// Disassemble returns the textual representation of the opcode,
+// according to the instruction format.
+func (ins *Instruction) Disassemble(opcode uint16) string {
+	return strings.NewReplacer(
+		"{nnn}", fmt.Sprintf("0x%03X", opcode&0x0FFF),
+		"{nn}", fmt.Sprintf("0x%02X", opcode&0x00FF),
+		"{n}", fmt.Sprintf("0x%X", opcode&0x000F),
+		"{x}", fmt.Sprintf("%X", opcode>>8&0x000F),
+		"{y}", fmt.Sprintf("%X", opcode>>4&0x000F),
+	).Replace(ins.Format)
+}
+
+// the registered instructions of each instruction set
+var registry = struct {
+	sync.RWMutex
+	sets map[InstructionSet][]*Instruction
+}{
+	sets: builtinInstructions(),
+}
+
+// incremented every time the registry changes, to invalidate the
+// decoded instructions of the emulators
+var registryVersion int64
+
+// builtinInstructions returns the instructions of the built-in
+// instruction sets.
+func builtinInstructions() map[InstructionSet][]*Instruction {
+	return map[InstructionSet][]*Instruction{
+		InstrCHIP8:    chip8Instructions(),
+		InstrSCHIP:    schipInstructions(),
+		InstrHiRes:    hiresInstructions(),
+		InstrCHIP8X:   chip8xInstructions(),
+		InstrXOCHIP:   xochipInstructions(),
+		InstrMegaChip: megachipInstructions(),
+	}
+}
+
+// Register adds an instruction to the given instruction set. Machines
+// supporting the set will be able to execute, disassemble and trace the
+// new instruction. The set may be one of the built-in sets or a new one,
+// created by the user (ex: InstructionSet(1 << 16)).
+//
+// To add instructions only to some machines, without changing the
+// instruction sets, use an Extension instead.
+func Register(set InstructionSet, ins Instruction) error {
+	if bits.OnesCount(uint(set)) != 1 {
+		return ErrInstructionSet
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	for _, existing := range registry.sets[set] {
+		if existing.Mask == ins.Mask && existing.Pattern == ins.Pattern {
+			return ErrDuplicateInstruction
+		}
+	}
+
+	registry.sets[set] = append(registry.sets[set], &ins)
+	atomic.AddInt64(&registryVersion, 1)
+	return nil
+}
+
+// Instructions returns a copy of the instructions registered on the
+// given instruction set.
+func Instructions(set InstructionSet) []Instruction {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	list := make([]Instruction, len(registry.sets[set]))
+	for i, ins := range registry.sets[set] {
+		list[i] = *ins
+	}
+
+	return list
+}
+
+// Lookup returns the instruction the machine executes for the given
+// opcode, or nil if the opcode is unknown. The extensions of the machine
+// are checked first, in order; then, the most specific instruction from
+// the instruction sets of the machine is used. When instructions of two
+// sets are equally specific, the one from the set with the highest value
+// wins, so the sets that extend the CHIP-8 may replace its instructions.
+func (m *Machine) Lookup(opcode uint16) *Instruction {
+	for _, ext := range m.Extensions {
+		if ins := mostSpecific(ext.instructions(), opcode); ins != nil {
+			return ins
+		}
+	}
+
+	registry.RLock()
+	defer registry.RUnlock()
+
+	var found *Instruction
+	for set := InstructionSet(1); set != 0; set <<= 1 {
+		if m.Instructions&set == 0 {
+			continue
+		}
+
+		if ins := mostSpecific(registry.sets[set], opcode); ins != nil && (found == nil || !moreSpecific(found, ins)) {
+			found = ins
+		}
+	}
+
+	return found
+}
+
+// Disassemble returns the textual representation of the opcode on the
+// machine, or a 'data' declaration if the opcode is unknown.
+func (m *Machine) Disassemble(opcode uint16) string {
+	if ins := m.Lookup(opcode); ins != nil {
+		return ins.Disassemble(opcode)
+	}
+
+	return fmt.Sprintf("DW 0x%04X", opcode)
+}
+
+// mostSpecific returns the instruction of the list that matches the
+// opcode with the biggest mask.
+func mostSpecific(list []*Instruction, opcode uint16) *Instruction {
+	var found *Instruction
+
+	for _, ins := range list {
+		if ins.Matches(opcode) && (found == nil || moreSpecific(ins, found)) {
+			found = ins
+		}
+	}
+
+	return found
+}
+
+// moreSpecific reports whether a has more bits on the mask than b. Ties
+// are broken by the mask value, so the result does not depend on the
+// order of the instructions.
+func moreSpecific(a, b *Instruction) bool {
+	ca, cb := bits.OnesCount16(a.Mask), bits.OnesCount16(b.Mask)
+	if ca != cb {
+		return ca > cb
+	}
+
+	return a.Mask > b.Mask
+}
+
+// decoder caches the result of Lookup for every opcode, for a given
+// machine and version of the registry, and the decoded instructions of
+// the memory (see cache.go).
+type decoder struct {
+	machine *Machine
+	version int64
+	known   [0x10000]bool
+	table   [0x10000]*Instruction
+	pages   []*cachePage
+	current cachedInstruction // last instruction decoded without the cache
+	blocks  blockCache
+}
+
+// decoderFor returns the decoder of the emulated machine, creating a new
+// one if the machine or the registry changed.
+func (c *Emulator) decoderFor() *decoder {
+	m := c.machine()
+	version := atomic.LoadInt64(&registryVersion)
+
+	if c.decoder == nil || c.decoder.machine != m || c.decoder.version != version {
+		c.decoder = &decoder{machine: m, version: version}
+	}
+
+	return c.decoder
+}
+
+// lookup returns the instruction for the opcode, or nil if the opcode is
+// unknown.
+func (d *decoder) lookup(opcode uint16) *Instruction {
+	if !d.known[opcode] {
+		d.table[opcode] = d.machine.Lookup(opcode)
+		d.known[opcode] = true
+	}
+
+	return d.table[opcode]
+}
+
+// Disassemble returns the textual representation of the instruction at
+// the given address, according to the emulated machine.
+func (c *Emulator) Disassemble(addr int) string {
+	if c.access(addr) == Unmapped || c.access(addr+1) == Unmapped {
+		return "??"
+	}
+
+	return c.machine().Disassemble(uint16(c.Read(addr))<<8 | uint16(c.Read(addr+1)))
+}
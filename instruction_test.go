@@ -0,0 +1,252 @@
+package chip8_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+// instruction set used only by the tests
+const instrTest chip8.InstructionSet = 1 << 16
+
+func TestRegister(t *testing.T) {
+	swap := chip8.Instruction{
+		Mask:     0xF00F,
+		Pattern:  0x5007,
+		Mnemonic: "SWAP",
+		Format:   "SWAP V{x}, V{y}",
+		Cycles:   1,
+		Exec: func(c *chip8.Emulator, a byte, b byte) error {
+			x, y := a&0x0F, b>>4
+			c.V[x], c.V[y] = c.V[y], c.V[x]
+			return nil
+		},
+	}
+
+	if err := chip8.Register(instrTest, swap); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := chip8.Register(instrTest, swap); !errors.Is(err, chip8.ErrDuplicateInstruction) {
+		t.Fatalf("expected ErrDuplicateInstruction, but got %v", err)
+	}
+
+	if err := chip8.Register(chip8.InstrCHIP8|chip8.InstrSCHIP, swap); !errors.Is(err, chip8.ErrInstructionSet) {
+		t.Fatalf("expected ErrInstructionSet, but got %v", err)
+	}
+
+	if list := chip8.Instructions(instrTest); len(list) != 1 || list[0].Mnemonic != "SWAP" {
+		t.Fatalf("expected the registered instruction, but found %v", list)
+	}
+
+	rom := []byte{
+		0x61, 0x11, // V1 = 0x11
+		0x62, 0x22, // V2 = 0x22
+		0x51, 0x27, // SWAP V1, V2
+	}
+
+	m := *chip8.COSMACVIP
+	m.Instructions |= instrTest
+
+	c := chip8.NewEmulator(&m)
+	if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Execute(3); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.V[1] != 0x22 || c.V[2] != 0x11 {
+		t.Fatalf("expected registers to be swapped, but found V1=0x%02X and V2=0x%02X", c.V[1], c.V[2])
+	}
+
+	if s := c.Disassemble(chip8.AddrStart + 4); s != "SWAP V1, V2" {
+		t.Fatalf("expected 'SWAP V1, V2', but found '%s'", s)
+	}
+
+	// machines without the instruction set do not know the instruction
+	c = chip8.NewEmulator(chip8.COSMACVIP)
+	if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatal(err)
+	}
+
+	var noop chip8.NoOpError
+	if _, err := c.Execute(3); !errors.As(err, &noop) {
+		t.Fatalf("expected NoOpError, but got %v", err)
+	}
+}
+
+func TestInstructions(t *testing.T) {
+	list := chip8.Instructions(chip8.InstrCHIP8)
+	if len(list) == 0 {
+		t.Fatal("expected the CHIP-8 instructions to be registered")
+	}
+
+	for _, ins := range list {
+		if ins.Mnemonic == "" || ins.Format == "" || ins.Cycles <= 0 || ins.Exec == nil {
+			t.Fatalf("incomplete instruction %+v", ins)
+		}
+
+		if ins.Pattern&^ins.Mask != 0 {
+			t.Fatalf("pattern 0x%04X has bits outside of the mask 0x%04X", ins.Pattern, ins.Mask)
+		}
+	}
+
+	list[0].Mnemonic = "CHANGED"
+	if chip8.Instructions(chip8.InstrCHIP8)[0].Mnemonic == "CHANGED" {
+		t.Fatal("changing the returned list should not change the registry")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		machine  *chip8.Machine
+		opcode   uint16
+		mnemonic string
+	}{
+		{chip8.COSMACVIP, 0x00E0, "CLS"},
+		{chip8.COSMACVIP, 0x0123, "SYS"},
+		{chip8.COSMACVIP, 0x0230, "SYS"},
+		{chip8.HiResVIP, 0x0230, "CLS"},
+		{chip8.CHIP8X, 0x02A0, "BGND"},
+		{chip8.MEGACHIP, 0x0011, "MEGAON"},
+		{chip8.MEGACHIP, 0x00E0, "CLS"},
+		{chip8.COSMACVIP.WithExtensions(chip8.CHIP8E), 0x00ED, "STOP"},
+		{chip8.COSMACVIP.WithExtensions(chip8.CHIP8I), 0xF3E3, "IN"},
+		{chip8.COSMACVIP, 0x8121, ""},
+		{chip8.COSMACVIP, 0xF03A, ""},
+		{chip8.XOCHIP, 0xF03A, "PITCH"},
+		{chip8.SCHIP, 0x00FF, "HIGH"},
+		{chip8.SCHIP, 0xD120, "DRW"},
+		{chip8.SCHIP, 0x00D1, "SYS"},
+		{chip8.XOCHIP, 0x00D1, "SCRU"},
+		{chip8.XOCHIP, 0xF201, "PLANE"},
+	}
+
+	for _, test := range tests {
+		ins := test.machine.Lookup(test.opcode)
+
+		mnemonic := ""
+		if ins != nil {
+			mnemonic = ins.Mnemonic
+		}
+
+		if mnemonic != test.mnemonic {
+			t.Errorf("%s: expected 0x%04X to be '%s', but found '%s'", test.machine.ID, test.opcode, test.mnemonic, mnemonic)
+		}
+	}
+}
+
+func TestLookupExtensionFirst(t *testing.T) {
+	ext := &chip8.Extension{
+		ID: "test",
+		Instructions: []chip8.Instruction{
+			{Mask: 0xF000, Pattern: 0x6000, Mnemonic: "NOP", Format: "NOP", Cycles: 1},
+		},
+	}
+
+	m := chip8.COSMACVIP.WithExtensions(ext)
+	if ins := m.Lookup(0x6A2A); ins == nil || ins.Mnemonic != "NOP" {
+		t.Fatalf("expected the extension to replace the built-in instruction, but found %v", ins)
+	}
+}
+
+func TestLookupOrder(t *testing.T) {
+	low := chip8.Instruction{Mask: 0xFFFF, Pattern: 0x5FF9, Mnemonic: "LOW", Format: "LOW", Cycles: 1}
+	high := chip8.Instruction{Mask: 0xFFFF, Pattern: 0x5FF9, Mnemonic: "HIGH", Format: "HIGH", Cycles: 1}
+
+	for set, ins := range map[chip8.InstructionSet]chip8.Instruction{1 << 20: low, 1 << 21: high} {
+		if err := chip8.Register(set, ins); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := *chip8.COSMACVIP
+	m.Instructions |= 1<<20 | 1<<21
+
+	// the registry is a map, so repeat the lookup to catch a random order
+	for i := 0; i < 100; i++ {
+		if ins := m.Lookup(0x5FF9); ins == nil || ins.Mnemonic != "HIGH" {
+			t.Fatalf("expected the instruction of the highest set, but found %v", ins)
+		}
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	tests := []struct {
+		machine *chip8.Machine
+		opcode  uint16
+		text    string
+	}{
+		{chip8.COSMACVIP, 0x00E0, "CLS"},
+		{chip8.COSMACVIP, 0x0123, "SYS 0x123"},
+		{chip8.COSMACVIP, 0x6A2A, "LD VA, 0x2A"},
+		{chip8.COSMACVIP, 0x8120, "LD V1, V2"},
+		{chip8.COSMACVIP, 0xE19E, "SKP V1"},
+		{chip8.COSMACVIP, 0xF518, "LD ST, V5"},
+		{chip8.COSMACVIP, 0x8121, "DW 0x8121"},
+		{chip8.CHIP8X, 0xB123, "COL V1, V2, 0x3"},
+		{chip8.MEGACHIP, 0x0102, "LDHI I, 0x02"},
+		{chip8.COSMACVIP.WithExtensions(chip8.CHIP8E), 0x5132, "LD [I], V1-V3"},
+	}
+
+	for _, test := range tests {
+		if text := test.machine.Disassemble(test.opcode); text != test.text {
+			t.Errorf("%s: expected 0x%04X to be '%s', but found '%s'", test.machine.ID, test.opcode, test.text, text)
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	rom := []byte{
+		0x61, 0x11, // V1 = 0x11
+		0x82, 0x10, // V2 = V1
+		0xF2, 0x15, // DT = V2
+	}
+
+	c := chip8.NewEmulator(chip8.COSMACVIP)
+	if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatal(err)
+	}
+
+	var trace []string
+	c.Trace = func(pc uint16, ins *chip8.Instruction, a byte, b byte) {
+		if int(pc) != chip8.AddrStart+len(trace)*2 {
+			t.Errorf("unexpected address 0x%03X for instruction %d", pc, len(trace))
+		}
+
+		trace = append(trace, ins.Disassemble(uint16(a)<<8|uint16(b)))
+	}
+
+	if _, err := c.Execute(len(rom) / 2); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"LD V1, 0x11", "LD V2, V1", "LD DT, V2"}
+	if len(trace) != len(expected) {
+		t.Fatalf("expected %v, but found %v", expected, trace)
+	}
+
+	for i := range expected {
+		if trace[i] != expected[i] {
+			t.Fatalf("expected %v, but found %v", expected, trace)
+		}
+	}
+}
+
+func TestUnknownInstruction(t *testing.T) {
+	for _, opcode := range [][]byte{{0x51, 0x24}, {0x81, 0x2F}, {0xE1, 0x00}, {0xF1, 0x99}} {
+		c := chip8.NewEmulator(chip8.COSMACVIP)
+		if err := c.LoadROM(bytes.NewReader(opcode)); err != nil {
+			t.Fatal(err)
+		}
+
+		var noop chip8.NoOpError
+		if _, err := c.Execute(1); !errors.As(err, &noop) {
+			t.Fatalf("expected NoOpError for 0x%02X%02X, but got %v", opcode[0], opcode[1], err)
+		}
+	}
+}
@@ -0,0 +1,497 @@
+package chip8
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// ErrDisplayMode is returned when trying to use a display mode that is
+// not supported by the emulated machine.
+var ErrDisplayMode = errors.New("display mode not supported by this machine")
+
+// DisplayMode describes the size, in pixels, of the CHIP-8 display.
+type DisplayMode struct {
+	Width  int
+	Height int
+}
+
+// VideoSize returns the number of bytes of video memory needed by the
+// display mode (one bit per pixel).
+func (d DisplayMode) VideoSize() int {
+	return d.Width * d.Height / 8
+}
+
+// Region is a range of memory addresses, from Start (inclusive) to
+// End (exclusive).
+type Region struct {
+	Name  string
+	Start int
+	End   int
+}
+
+// Contains reports whether the address is inside the region.
+func (r Region) Contains(addr int) bool {
+	return addr >= r.Start && addr < r.End
+}
+
+// InstructionSet is a set of flags indicating which groups of
+// instructions are understood by a machine.
+type InstructionSet uint
+
+// Known instruction sets.
+const (
+	InstrCHIP8    InstructionSet = 1 << iota // the original CHIP-8 instructions
+	InstrSCHIP                               // SUPER-CHIP extensions
+	InstrXOCHIP                              // XO-CHIP extensions
+	InstrHiRes                               // 0230 screen clear of the Hi-res CHIP-8
+	InstrCHIP8X                              // CHIP-8X colors, second keypad and I/O
+	InstrMegaChip                            // MegaChip8 extensions
+)
+
+// Quirks describes the behaviors that changed between CHIP-8
+// implementations, and that programs may (or may not) rely on.
+type Quirks struct {
+	ShiftVy     bool // 8xy6 and 8xyE shift Vy into Vx, instead of shifting Vx in place
+	LoadStoreI  bool // Fx55 and Fx65 increment I
+	LoadStoreX  bool // Fx55 and Fx65 increment I by x, instead of x + 1 (taking precedence over LoadStoreI)
+	JumpVx      bool // Bnnn jumps to nnn + Vx (where x is the high nibble of nnn) instead of nnn + V0
+	ResetVF     bool // 8xy1, 8xy2 and 8xy3 set VF to zero
+	ClipSprites bool // sprites are clipped on the screen edges, instead of wrapping
+	WaitVBlank  bool // Dxyn waits for the vertical blank before drawing
+}
+
+// Machine describes the differences between the computers (or
+// interpreters) that ran CHIP-8 programs: the memory layout, the stack,
+// the display, the instructions available and the quirks of each one.
+//
+// A Machine is a read-only description; to emulate a machine with
+// different settings, make a copy of one of the built-in values and
+// change it as needed.
+type Machine struct {
+	ID           string         // short, unique identifier
+	Name         string         // human readable name
+	MemorySize   int            // size of the addressable memory, in bytes
+	Reserved     []Region       // memory regions reserved for the interpreter
+	VideoAddr    int            // start of the video memory (kept apart from Emulator.Memory if after MemorySize)
+	SpriteAddr   int            // start of the built-in font
+	Font         *Font          // built-in font (nil for none)
+	StartAddr    int            // address where the programs are loaded
+	StackDepth   int            // maximum number of nested calls (or StackUnlimited)
+	StackAddr    int            // top of the stack in memory (0 for none; see Emulator.MemoryStack)
+	Modes        []DisplayMode  // supported display modes; the first is the default
+	Planes       int            // bitplanes of the display (0 means 1), one after the other in video memory
+	Instructions InstructionSet // instructions understood by the machine
+	Extensions   []*Extension   // additional instructions, checked before the built-in ones
+	Quirks       Quirks         // behavior of the ambiguous instructions
+	Timing       *Timing        // time taken by the instructions (nil if unknown)
+}
+
+// memoryLen returns the length of the memory allocated by Reset: all of
+// the addressable memory, or only up to the start of the programs on the
+// machines whose memory grows as it is used (see eagerMemory).
+func (m *Machine) memoryLen() int {
+	size := m.MemorySize
+	if size > eagerMemory {
+		size = m.StartAddr
+	}
+
+	if end := m.VideoAddr + m.videoLen(); m.VideoAddr < m.MemorySize && end > size {
+		size = end
+	}
+
+	return size
+}
+
+// videoLen returns the length of the video memory of the biggest display
+// mode, for all of the bitplanes.
+func (m *Machine) videoLen() int {
+	size := 0
+	for _, mode := range m.Modes {
+		if n := mode.VideoSize() * m.planeCount(); n > size {
+			size = n
+		}
+	}
+
+	return size
+}
+
+// planeCount returns the number of bitplanes of the display.
+func (m *Machine) planeCount() int {
+	if m.Planes < 1 {
+		return 1
+	}
+
+	return m.Planes
+}
+
+// COSMACVIP is the original CHIP-8 machine, and the one used by default
+// when the emulator does not specify a machine.
+var COSMACVIP = &Machine{
+	ID:         "vip",
+	Name:       "COSMAC VIP",
+	MemorySize: 4096,
+	Reserved: []Region{
+		{Name: "interpreter", Start: 0x000, End: AddrStart},
+	},
+	VideoAddr:    AddrVideo,
+	SpriteAddr:   AddrSprite,
+	Font:         CHIP48Font,
+	StartAddr:    AddrStart,
+	StackDepth:   12,
+	StackAddr:    AddrStack,
+	Modes:        []DisplayMode{{Width: 64, Height: 32}},
+	Instructions: InstrCHIP8,
+	Quirks: Quirks{
+		ShiftVy:     true,
+		LoadStoreI:  true,
+		ResetVF:     true,
+		ClipSprites: true,
+		WaitVBlank:  true,
+	},
+	Timing: VIPTiming,
+}
+
+// HiResVIP is the 'Hi-res CHIP-8' for the COSMAC VIP, which patched the
+// interpreter at 0x0230 to have a two-page, 64x64 display. Programs are
+// loaded at 0x2C0 and clear the screen using the 0230 instruction.
+var HiResVIP = &Machine{
+	ID:         "vip-hires",
+	Name:       "Hi-res CHIP-8",
+	MemorySize: 4096,
+	Reserved: []Region{
+		{Name: "interpreter", Start: 0x000, End: 0x2C0},
+	},
+	VideoAddr:    0x000,
+	SpriteAddr:   0x200,
+	Font:         CHIP48Font,
+	StartAddr:    0x2C0,
+	StackDepth:   12,
+	StackAddr:    AddrStack,
+	Modes:        []DisplayMode{{Width: 64, Height: 64}},
+	Instructions: InstrCHIP8 | InstrHiRes,
+	Quirks: Quirks{
+		ShiftVy:     true,
+		LoadStoreI:  true,
+		ResetVF:     true,
+		ClipSprites: true,
+		WaitVBlank:  true,
+	},
+}
+
+// CHIP8X is the CHIP-8X for the COSMAC VIP with the VP-590 color board
+// and the VP-580 second keypad. Programs are loaded at 0x300.
+var CHIP8X = &Machine{
+	ID:         "chip8x",
+	Name:       "CHIP-8X",
+	MemorySize: 4096,
+	Reserved: []Region{
+		{Name: "interpreter", Start: 0x000, End: 0x300},
+	},
+	VideoAddr:    AddrVideo,
+	SpriteAddr:   AddrSprite,
+	Font:         CHIP48Font,
+	StartAddr:    0x300,
+	StackDepth:   12,
+	StackAddr:    AddrStack,
+	Modes:        []DisplayMode{{Width: 64, Height: 32}},
+	Instructions: InstrCHIP8 | InstrCHIP8X,
+	Quirks: Quirks{
+		ShiftVy:     true,
+		LoadStoreI:  true,
+		ResetVF:     true,
+		ClipSprites: true,
+		WaitVBlank:  true,
+	},
+}
+
+// ETI660 is the ETI 660 computer, which loads programs at 0x600 and
+// supports 64x48 and 64x64 displays besides the default 64x32 one.
+var ETI660 = &Machine{
+	ID:         "eti660",
+	Name:       "ETI 660",
+	MemorySize: 4096,
+	Reserved: []Region{
+		{Name: "interpreter", Start: 0x000, End: 0x600},
+	},
+	VideoAddr:  0x000,
+	SpriteAddr: 0x200,
+	Font:       CHIP48Font,
+	StartAddr:  0x600,
+	StackDepth: 16,
+	Modes: []DisplayMode{
+		{Width: 64, Height: 32},
+		{Width: 64, Height: 48},
+		{Width: 64, Height: 64},
+	},
+	Instructions: InstrCHIP8,
+	Quirks: Quirks{
+		ShiftVy:     true,
+		LoadStoreI:  true,
+		ResetVF:     true,
+		ClipSprites: true,
+	},
+}
+
+// CHIP48 is the CHIP-48 interpreter for the HP-48 calculators.
+var CHIP48 = &Machine{
+	ID:         "chip48",
+	Name:       "CHIP-48",
+	MemorySize: 4096,
+	Reserved: []Region{
+		{Name: "interpreter", Start: 0x000, End: AddrStart},
+	},
+	VideoAddr:    AddrVideo,
+	SpriteAddr:   AddrSprite,
+	Font:         CHIP48Font,
+	StartAddr:    AddrStart,
+	StackDepth:   16,
+	Modes:        []DisplayMode{{Width: 64, Height: 32}},
+	Instructions: InstrCHIP8,
+	Quirks: Quirks{
+		LoadStoreX:  true,
+		JumpVx:      true,
+		ClipSprites: true,
+	},
+}
+
+// SCHIP is the SUPER-CHIP 1.1 interpreter for the HP-48 calculators.
+// Its high resolution video memory does not fit on the lower 512 bytes
+// of the memory, so it's placed after the addressable area.
+var SCHIP = &Machine{
+	ID:         "schip",
+	Name:       "SUPER-CHIP",
+	MemorySize: 4096,
+	Reserved: []Region{
+		{Name: "interpreter", Start: 0x000, End: AddrStart},
+		{Name: "video", Start: 0x1000, End: 0x1400},
+	},
+	VideoAddr:  0x1000,
+	SpriteAddr: AddrSprite,
+	Font:       SCHIPFont,
+	StartAddr:  AddrStart,
+	StackDepth: 16,
+	Modes: []DisplayMode{
+		{Width: 64, Height: 32},
+		{Width: 128, Height: 64},
+	},
+	Instructions: InstrCHIP8 | InstrSCHIP,
+	Quirks: Quirks{
+		JumpVx:      true,
+		ClipSprites: true,
+	},
+}
+
+// XOCHIP is the XO-CHIP extension, as implemented by Octo. It has 64KB
+// of addressable memory, so the video memory of its two bitplanes is
+// placed after it.
+var XOCHIP = &Machine{
+	ID:         "xochip",
+	Name:       "XO-CHIP",
+	MemorySize: 0x10000,
+	Reserved: []Region{
+		{Name: "interpreter", Start: 0x000, End: AddrStart},
+		{Name: "video", Start: 0x10000, End: 0x10800},
+	},
+	VideoAddr:  0x10000,
+	SpriteAddr: AddrSprite,
+	Font:       XOCHIPFont,
+	StartAddr:  AddrStart,
+	StackDepth: 16,
+	Modes: []DisplayMode{
+		{Width: 64, Height: 32},
+		{Width: 128, Height: 64},
+	},
+	Planes:       2,
+	Instructions: InstrCHIP8 | InstrSCHIP | InstrXOCHIP,
+	Quirks: Quirks{
+		ShiftVy:    true,
+		LoadStoreI: true,
+	},
+}
+
+// Machines is the list of all built-in machines.
+var Machines = []*Machine{COSMACVIP, HiResVIP, CHIP8X, ETI660, CHIP48, SCHIP, XOCHIP, MEGACHIP}
+
+// FindMachine returns the built-in machine with the given ID, or nil
+// if there is no such machine.
+func FindMachine(id string) *Machine {
+	for _, m := range Machines {
+		if m.ID == id {
+			return m
+		}
+	}
+
+	return nil
+}
+
+// NewEmulator creates a new emulator for the given machine, already
+// reset and ready to load a ROM. A nil machine means COSMACVIP.
+func NewEmulator(m *Machine) *Emulator {
+	c := &Emulator{Machine: m}
+	c.Reset()
+	return c
+}
+
+// machine returns the machine being emulated.
+func (c *Emulator) machine() *Machine {
+	if c.Machine == nil {
+		return COSMACVIP
+	}
+
+	return c.Machine
+}
+
+// supports reports whether the emulated machine understands the
+// given set of instructions.
+func (c *Emulator) supports(set InstructionSet) bool {
+	return c.machine().Instructions&set == set
+}
+
+// SetDisplayMode changes the display mode of the emulator, clearing the
+// video memory. If the mode is not supported by the machine, ErrDisplayMode
+// is returned and nothing is changed.
+func (c *Emulator) SetDisplayMode(mode DisplayMode) error {
+	for _, supported := range c.machine().Modes {
+		if supported == mode {
+			c.Display = mode
+			c.clearVideo()
+			return nil
+		}
+	}
+
+	return ErrDisplayMode
+}
+
+// draw draws a sprite of n rows (8 pixels each) read from the memory
+// pointed by I, at (x, y) (see drawSprite).
+func (c *Emulator) draw(x, y, n int) error {
+	return c.drawSprite(x, y, 8, n)
+}
+
+// drawSprite draws a sprite of the given size, read from the memory
+// pointed by I, at (x, y), on each selected bitplane; the sprites of the
+// planes follow each other in memory. The pixels are combined with the
+// screen using XOR, and VF is set to 1 if any pixel was turned off. The
+// starting position wraps around the screen; the pixels outside of the
+// screen are clipped or wrapped, according to the ClipSprites quirk.
+func (c *Emulator) drawSprite(x, y, width, height int) error {
+	size := width / 8 * height
+	planes := c.planes()
+
+	sprite, err := c.load(int(c.I), size*bits.OnesCount8(planes))
+	if err != nil {
+		return err
+	}
+
+	clip := c.machine().Quirks.ClipSprites
+	w, h := c.Display.Width, c.Display.Height
+	x, y = x%w, y%h
+	c.V[0xF] = 0
+
+	c.eachPlane(planes, func(video []byte) {
+		for row := 0; row < height; row++ {
+			for col := 0; col < width; col++ {
+				if sprite[row*width/8+col/8]&(0x80>>uint(col%8)) == 0 {
+					continue
+				}
+
+				px, py := x+col, y+row
+				if clip && (px >= w || py >= h) {
+					continue
+				}
+
+				px, py = px%w, py%h
+				addr := (py*w + px) / 8
+				bit := byte(0x80 >> uint(px%8))
+
+				if video[addr]&bit != 0 {
+					c.V[0xF] = 1
+				}
+
+				video[addr] ^= bit
+			}
+		}
+
+		sprite = sprite[size:]
+	})
+
+	return nil
+}
+
+// planes returns the bitplanes selected for drawing, clearing and
+// scrolling the screen.
+func (c *Emulator) planes() byte {
+	if c.machine().planeCount() == 1 {
+		return 1
+	}
+
+	return c.Planes
+}
+
+// videoMemory returns the video memory of the current display mode, for
+// all of the bitplanes.
+func (c *Emulator) videoMemory() []byte {
+	m := c.machine()
+	size := c.Display.VideoSize() * m.planeCount()
+
+	if m.VideoAddr >= m.MemorySize {
+		return c.video[:size]
+	}
+
+	return c.Memory[m.VideoAddr : m.VideoAddr+size]
+}
+
+// eachPlane calls f with the video memory of each of the selected
+// bitplanes, in order.
+func (c *Emulator) eachPlane(selected byte, f func(video []byte)) {
+	m := c.machine()
+	video := c.videoMemory()
+	size := c.Display.VideoSize()
+
+	for p := 0; p < m.planeCount(); p++ {
+		if selected&(1<<uint(p)) != 0 {
+			f(video[p*size:][:size])
+		}
+	}
+
+	if m.VideoAddr < m.MemorySize {
+		c.InvalidateCache(m.VideoAddr, len(video))
+	}
+}
+
+// clearVideo clears the video memory of the current display mode.
+func (c *Emulator) clearVideo() {
+	c.clearPlanes(0xFF)
+}
+
+// clearPlanes clears the video memory of the selected bitplanes.
+func (c *Emulator) clearPlanes(selected byte) {
+	c.eachPlane(selected, func(video []byte) {
+		for i := range video {
+			video[i] = 0
+		}
+	})
+}
+
+// scrollColumns moves the screen contents right by the given number of
+// pixels (or left, if negative).
+func (c *Emulator) scrollColumns(pixels int) {
+	w, h := c.Display.Width, c.Display.Height
+
+	c.eachPlane(c.planes(), func(video []byte) {
+		shifted := make([]byte, len(video))
+
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				src := x - pixels
+				if src < 0 || src >= w || video[(y*w+src)/8]&(0x80>>uint(src%8)) == 0 {
+					continue
+				}
+
+				shifted[(y*w+x)/8] |= 0x80 >> uint(x%8)
+			}
+		}
+
+		copy(video, shifted)
+	})
+}
@@ -0,0 +1,166 @@
+package chip8_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+func TestDefaultMachine(t *testing.T) {
+	var c chip8.Emulator
+	c.Reset()
+
+	expected := chip8.DisplayMode{Width: 64, Height: 32}
+	if c.Display != expected {
+		t.Fatalf("expected display to be %v, but was %v", expected, c.Display)
+	}
+
+	if c.PC != chip8.AddrStart {
+		t.Fatalf("expected PC to be 0x%X, but was 0x%X", chip8.AddrStart, c.PC)
+	}
+}
+
+func TestNewEmulator(t *testing.T) {
+	for _, m := range chip8.Machines {
+		m := m
+
+		t.Run(m.ID, func(t *testing.T) {
+			c := chip8.NewEmulator(m)
+
+			if c.Machine != m {
+				t.Fatalf("expected machine to be %s, but was %v", m.Name, c.Machine)
+			}
+
+			// bigger memories grow as they are used
+			if len(c.Memory) < m.MemorySize && m.MemorySize <= 0x10000 {
+				t.Fatalf("expected at least %d bytes of memory, but found %d", m.MemorySize, len(c.Memory))
+			}
+
+			if int(c.PC) != m.StartAddr {
+				t.Fatalf("expected PC to be 0x%X, but was 0x%X", m.StartAddr, c.PC)
+			}
+
+			if c.Display != m.Modes[0] {
+				t.Fatalf("expected display to be %v, but was %v", m.Modes[0], c.Display)
+			}
+
+			font := append(append([]byte{}, m.Font.Small...), m.Font.Big...)
+			for i, b := range font {
+				if c.Memory[m.SpriteAddr+i] != b {
+					t.Fatalf("expected sprite data 0x%02X at 0x%X, but found 0x%02X", b, m.SpriteAddr+i, c.Memory[m.SpriteAddr+i])
+				}
+			}
+
+			// every display mode should fit in memory
+			for _, mode := range m.Modes {
+				if err := c.SetDisplayMode(mode); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestMachineLayout(t *testing.T) {
+	for _, m := range chip8.Machines {
+		m := m
+
+		t.Run(m.ID, func(t *testing.T) {
+			// program area should not overlap the font or the video memory
+			for _, mode := range m.Modes {
+				video := chip8.Region{Start: m.VideoAddr, End: m.VideoAddr + mode.VideoSize()}
+				if video.Contains(m.StartAddr) || video.Contains(m.SpriteAddr) {
+					t.Fatalf("video memory in mode %v overlaps program or sprites", mode)
+				}
+			}
+
+			if m.SpriteAddr+len(m.Font.Small)+len(m.Font.Big) > m.StartAddr {
+				t.Fatal("sprite data overlaps the program area")
+			}
+
+			if m.StackDepth <= 0 {
+				t.Fatal("stack depth should be positive")
+			}
+		})
+	}
+}
+
+func TestMachineFont(t *testing.T) {
+	tests := []struct {
+		machine *chip8.Machine
+		font    *chip8.Font
+	}{
+		{chip8.COSMACVIP, chip8.CHIP48Font},
+		{chip8.HiResVIP, chip8.CHIP48Font},
+		{chip8.CHIP8X, chip8.CHIP48Font},
+		{chip8.ETI660, chip8.CHIP48Font},
+		{chip8.COSMACVIP.WithFont(chip8.VIPFont, chip8.AddrSprite), chip8.VIPFont},
+		{chip8.CHIP48, chip8.CHIP48Font},
+		{chip8.SCHIP, chip8.SCHIPFont},
+		{chip8.XOCHIP, chip8.XOCHIPFont},
+	}
+
+	for _, test := range tests {
+		if test.machine.Font != test.font {
+			t.Errorf("%s: expected the %s font, but found %s", test.machine.ID, test.font.Name, test.machine.Font.Name)
+		}
+	}
+}
+
+func TestFindMachine(t *testing.T) {
+	if m := chip8.FindMachine("eti660"); m != chip8.ETI660 {
+		t.Fatalf("expected to find ETI 660 machine, but found %v", m)
+	}
+
+	if m := chip8.FindMachine("unknown"); m != nil {
+		t.Fatalf("expected no machine, but found %v", m)
+	}
+}
+
+func TestSetDisplayMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		machine *chip8.Machine
+		mode    chip8.DisplayMode
+		isErr   bool
+	}{
+		{name: "VIP-64x32", machine: chip8.COSMACVIP, mode: chip8.DisplayMode{Width: 64, Height: 32}},
+		{name: "VIP-64x64", machine: chip8.COSMACVIP, mode: chip8.DisplayMode{Width: 64, Height: 64}, isErr: true},
+		{name: "ETI660-64x32", machine: chip8.ETI660, mode: chip8.DisplayMode{Width: 64, Height: 32}},
+		{name: "ETI660-64x48", machine: chip8.ETI660, mode: chip8.DisplayMode{Width: 64, Height: 48}},
+		{name: "ETI660-64x64", machine: chip8.ETI660, mode: chip8.DisplayMode{Width: 64, Height: 64}},
+		{name: "ETI660-128x64", machine: chip8.ETI660, mode: chip8.DisplayMode{Width: 128, Height: 64}, isErr: true},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			c := chip8.Emulator{Machine: test.machine}
+			c.Reset()
+			previous := c.Display
+
+			err := c.SetDisplayMode(test.mode)
+			if test.isErr {
+				if !errors.Is(err, chip8.ErrDisplayMode) {
+					t.Fatalf("expected display mode error, but got %v", err)
+				}
+
+				if c.Display != previous {
+					t.Fatalf("display should not change on error, but changed to %v", c.Display)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c.Display != test.mode {
+				t.Fatalf("expected display to be %v, but was %v", test.mode, c.Display)
+			}
+		})
+	}
+}
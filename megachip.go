@@ -0,0 +1,433 @@
+package chip8
+
+import (
+	"image"
+	"image/color"
+)
+
+// Size of the MegaChip display, in pixels.
+const (
+	MegaWidth  = 256
+	MegaHeight = 192
+)
+
+// BlendMode is the way MegaChip sprites are combined with the pixels
+// already on the screen.
+type BlendMode byte
+
+// Blend modes supported by the MegaChip (080n instruction).
+const (
+	BlendNormal BlendMode = iota
+	Blend25
+	Blend50
+	BlendAdd
+	BlendMultiply
+)
+
+// MegaSound is the state of the MegaChip digitized sound playback. The
+// sample is stored in memory as unsigned 8-bit PCM, mono.
+type MegaSound struct {
+	Playing bool
+	Loop    bool
+	Addr    int // address of the first sample
+	Length  int // number of samples
+	Rate    int // sample rate, in Hz
+}
+
+// MegaChip holds the state of the MegaChip extensions.
+//
+// Since the MegaChip has 24-bit addresses and the I register has only
+// 16 bits, the upper 8 bits are kept on IHigh. They are set by the
+// 01nn nnnn instruction and used by the MegaChip instructions that
+// read from memory.
+type MegaChip struct {
+	Enabled        bool            // whether the MegaChip mode is on
+	IHigh          byte            // upper 8 bits of the I register
+	Framebuffer    *image.RGBA     // the 256x192 screen
+	Indexes        []byte          // palette index of each pixel of the screen
+	Palette        [256]color.RGBA // colors used by the sprites
+	SpriteWidth    int
+	SpriteHeight   int
+	Alpha          byte      // screen alpha (05nn)
+	Blend          BlendMode // sprite blend mode (080n)
+	CollisionColor byte      // palette index that triggers collisions (09nn)
+	Sound          MegaSound // digitized sound (060n and 0700)
+}
+
+// MEGACHIP is the MegaChip8 extension, by Revival Studios. It has 24-bit
+// addresses (16MB of memory, allocated as the program uses it) and, when
+// the MegaChip mode is on, a 256x192 display with 256 colors.
+var MEGACHIP = &Machine{
+	ID:         "megachip",
+	Name:       "MegaChip8",
+	MemorySize: 0x1000000,
+	Reserved: []Region{
+		{Name: "interpreter", Start: 0x000, End: AddrStart},
+		{Name: "video", Start: 0x1000000, End: 0x1000400},
+	},
+	VideoAddr:  0x1000000,
+	SpriteAddr: AddrSprite,
+	Font:       SCHIPFont,
+	StartAddr:  AddrStart,
+	StackDepth: 16,
+	Modes: []DisplayMode{
+		{Width: 64, Height: 32},
+		{Width: 128, Height: 64},
+	},
+	Instructions: InstrCHIP8 | InstrSCHIP | InstrMegaChip,
+	Quirks: Quirks{
+		JumpVx:      true,
+		ClipSprites: true,
+	},
+}
+
+// megaI returns the full (24-bit) value of the I register.
+func (c *Emulator) megaI() int {
+	return int(c.Mega.IHigh)<<16 | int(c.I)
+}
+
+// resetMega turns off the MegaChip mode and resets its state.
+func (c *Emulator) resetMega() {
+	c.Mega = MegaChip{
+		Alpha:        0xFF,
+		SpriteWidth:  MegaWidth,
+		SpriteHeight: MegaHeight,
+	}
+}
+
+// enableMega turns the MegaChip mode on, creating a new screen.
+func (c *Emulator) enableMega() {
+	c.Mega.Enabled = true
+	c.Mega.Framebuffer = image.NewRGBA(image.Rect(0, 0, MegaWidth, MegaHeight))
+	c.Mega.Indexes = make([]byte, MegaWidth*MegaHeight)
+	c.clearMega()
+}
+
+// clearMega clears the MegaChip screen.
+func (c *Emulator) clearMega() {
+	for i := range c.Mega.Indexes {
+		c.Mega.Indexes[i] = 0
+	}
+
+	black := color.RGBA{A: 0xFF}
+	for y := 0; y < MegaHeight; y++ {
+		for x := 0; x < MegaWidth; x++ {
+			c.Mega.Framebuffer.SetRGBA(x, y, black)
+		}
+	}
+}
+
+// megachipInstructions returns the instructions of the MegaChip8.
+func megachipInstructions() []*Instruction {
+	return []*Instruction{
+		{Mask: 0xFFFF, Pattern: 0x0010, Mnemonic: "MEGAOFF", Format: "MEGAOFF", Cycles: 1, Exec: opMegaOff},
+		{Mask: 0xFFFF, Pattern: 0x0011, Mnemonic: "MEGAON", Format: "MEGAON", Cycles: 1, Exec: opMegaOn},
+		{Mask: 0xFFF0, Pattern: 0x00B0, Mnemonic: "SCRU", Format: "SCRU {n}", Cycles: 1, Exec: opScrollUp},
+		{Mask: 0xFFF0, Pattern: 0x00C0, Mnemonic: "SCRD", Format: "SCRD {n}", Cycles: 1, Exec: opScrollDown},
+		{Mask: 0xFF00, Pattern: 0x0100, Mnemonic: "LDHI", Format: "LDHI I, {nn}", Cycles: 1, Branch: true, Exec: opLdLongI},
+		{Mask: 0xFF00, Pattern: 0x0200, Mnemonic: "LDPAL", Format: "LDPAL {nn}", Cycles: 1, Exec: opLdPalette},
+		{Mask: 0xFF00, Pattern: 0x0300, Mnemonic: "SPRW", Format: "SPRW {nn}", Cycles: 1, Exec: opSpriteWidth},
+		{Mask: 0xFF00, Pattern: 0x0400, Mnemonic: "SPRH", Format: "SPRH {nn}", Cycles: 1, Exec: opSpriteHeight},
+		{Mask: 0xFF00, Pattern: 0x0500, Mnemonic: "ALPHA", Format: "ALPHA {nn}", Cycles: 1, Exec: opAlpha},
+		{Mask: 0xFFF0, Pattern: 0x0600, Mnemonic: "DIGISND", Format: "DIGISND {n}", Cycles: 1, Exec: opPlaySound},
+		{Mask: 0xFFFF, Pattern: 0x0700, Mnemonic: "STOPSND", Format: "STOPSND", Cycles: 1, Exec: opStopSound},
+		{Mask: 0xFFF0, Pattern: 0x0800, Mnemonic: "BMODE", Format: "BMODE {n}", Cycles: 1, Exec: opBlendMode},
+		{Mask: 0xFF00, Pattern: 0x0900, Mnemonic: "CCOL", Format: "CCOL {nn}", Cycles: 1, Exec: opCollisionColor},
+		{Mask: 0xF000, Pattern: 0xD000, Mnemonic: "DRW", Format: "DRW V{x}, V{y}, {n}", Cycles: 1, Exec: opDrawMega},
+	}
+}
+
+func opMegaOff(c *Emulator, a byte, b byte) error {
+	c.resetMega()
+	c.Display = c.machine().Modes[0]
+	c.clearVideo()
+	return nil
+}
+
+func opMegaOn(c *Emulator, a byte, b byte) error {
+	c.enableMega()
+	return nil
+}
+
+func opScrollUp(c *Emulator, a byte, b byte) error {
+	c.scroll(-int(b & lsnMask))
+	return nil
+}
+
+func opScrollDown(c *Emulator, a byte, b byte) error {
+	c.scroll(int(b & lsnMask))
+	return nil
+}
+
+func opLdLongI(c *Emulator, a byte, b byte) error {
+	low, err := c.load(int(c.PC), 2)
+	if err != nil {
+		return err
+	}
+
+	c.Mega.IHigh = b
+	c.I = uint16(low[0])<<8 | uint16(low[1])
+	c.PC += 2
+	return nil
+}
+
+func opLdPalette(c *Emulator, a byte, b byte) error {
+	return c.loadMegaPalette(int(b))
+}
+
+func opSpriteWidth(c *Emulator, a byte, b byte) error {
+	c.Mega.SpriteWidth = megaSize(b)
+	return nil
+}
+
+func opSpriteHeight(c *Emulator, a byte, b byte) error {
+	c.Mega.SpriteHeight = megaSize(b)
+	return nil
+}
+
+func opAlpha(c *Emulator, a byte, b byte) error {
+	c.Mega.Alpha = b
+	return nil
+}
+
+func opPlaySound(c *Emulator, a byte, b byte) error {
+	return c.playMegaSound(b&lsnMask == 0)
+}
+
+func opStopSound(c *Emulator, a byte, b byte) error {
+	c.Mega.Sound.Playing = false
+	return nil
+}
+
+func opBlendMode(c *Emulator, a byte, b byte) error {
+	c.Mega.Blend = BlendMode(b & lsnMask)
+	return nil
+}
+
+func opCollisionColor(c *Emulator, a byte, b byte) error {
+	c.Mega.CollisionColor = b
+	return nil
+}
+
+func opDrawMega(c *Emulator, a byte, b byte) error {
+	if !c.Mega.Enabled {
+		return opDraw(c, a, b)
+	}
+
+	return c.drawMega(int(c.V[a&lsnMask]), int(c.V[b&msnMask>>4]))
+}
+
+// megaSize converts the size given to 03nn and 04nn to pixels.
+func megaSize(n byte) int {
+	if n == 0 {
+		return 256
+	}
+
+	return int(n)
+}
+
+// loadMegaPalette loads count colors from the memory pointed by I, to
+// the palette, starting at index 1. Each color is stored as ARGB.
+func (c *Emulator) loadMegaPalette(count int) error {
+	colors, err := c.load(c.megaI(), count*4)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < count; i++ {
+		argb := colors[i*4:]
+		c.Mega.Palette[(i+1)&0xFF] = color.RGBA{R: argb[1], G: argb[2], B: argb[3], A: argb[0]}
+	}
+
+	return nil
+}
+
+// playMegaSound starts playing the digitized sound pointed by I. The
+// sound starts with a 6 byte header: the sample rate (2 bytes), the
+// number of samples (3 bytes) and one unused byte.
+func (c *Emulator) playMegaSound(loop bool) error {
+	const headerSize = 6
+
+	addr := c.megaI()
+	header, err := c.load(addr, headerSize)
+	if err != nil {
+		return err
+	}
+
+	sound := MegaSound{
+		Playing: true,
+		Loop:    loop,
+		Addr:    addr + headerSize,
+		Rate:    int(header[0])<<8 | int(header[1]),
+		Length:  int(header[2])<<16 | int(header[3])<<8 | int(header[4]),
+	}
+
+	if !c.grow(sound.Addr + sound.Length) {
+		return ErrInvalidAddress
+	}
+
+	c.Mega.Sound = sound
+	return nil
+}
+
+// scroll moves the screen contents down by the given number of lines
+// (or up, if lines is negative).
+func (c *Emulator) scroll(lines int) {
+	if !c.Mega.Enabled {
+		c.eachPlane(c.planes(), func(video []byte) {
+			shiftRows(video, c.Display.Width/8, lines, nil)
+		})
+
+		return
+	}
+
+	fb := c.Mega.Framebuffer
+	black := make([]byte, fb.Stride)
+	for i := 3; i < len(black); i += 4 {
+		black[i] = 0xFF
+	}
+
+	shiftRows(c.Mega.Indexes, MegaWidth, lines, nil)
+	shiftRows(fb.Pix, fb.Stride, lines, black)
+}
+
+// shiftRows moves the rows of buf down by the given number of lines (or
+// up, if lines is negative). The rows left empty are filled with blank.
+func shiftRows(buf []byte, rowSize int, lines int, blank []byte) {
+	rows := len(buf) / rowSize
+	shifted := make([]byte, len(buf))
+
+	for y := 0; y < rows; y++ {
+		row := shifted[y*rowSize : (y+1)*rowSize]
+
+		if src := y - lines; src >= 0 && src < rows {
+			copy(row, buf[src*rowSize:])
+		} else {
+			copy(row, blank)
+		}
+	}
+
+	copy(buf, shifted)
+}
+
+// drawMega draws a MegaChip sprite at (x, y). Each byte of the sprite is
+// a palette index, where zero is transparent. VF is set to 1 if any pixel
+// with the collision color was overwritten.
+func (c *Emulator) drawMega(x, y int) error {
+	w, h := c.Mega.SpriteWidth, c.Mega.SpriteHeight
+	sprite, err := c.load(c.megaI(), w*h)
+	if err != nil {
+		return err
+	}
+
+	c.V[0xF] = 0
+
+	for row := 0; row < h; row++ {
+		py := y + row
+		if py >= MegaHeight {
+			break
+		}
+
+		for col := 0; col < w; col++ {
+			px := x + col
+			index := sprite[row*w+col]
+			if px >= MegaWidth || index == 0 {
+				continue
+			}
+
+			pos := py*MegaWidth + px
+			if c.Mega.Indexes[pos] == c.Mega.CollisionColor && c.Mega.CollisionColor != 0 {
+				c.V[0xF] = 1
+			}
+
+			c.Mega.Indexes[pos] = index
+			dst := c.Mega.Framebuffer.RGBAAt(px, py)
+			c.Mega.Framebuffer.SetRGBA(px, py, blend(c.Mega.Blend, c.Mega.Palette[index], dst))
+		}
+	}
+
+	return nil
+}
+
+// blend combines the sprite color src with the screen color dst.
+func blend(mode BlendMode, src, dst color.RGBA) color.RGBA {
+	mix := func(s, d byte) byte {
+		switch mode {
+		case Blend25:
+			return byte((int(s) + 3*int(d)) / 4)
+		case Blend50:
+			return byte((int(s) + int(d)) / 2)
+		case BlendAdd:
+			if sum := int(s) + int(d); sum < 0xFF {
+				return byte(sum)
+			}
+			return 0xFF
+		case BlendMultiply:
+			return byte(int(s) * int(d) / 0xFF)
+		default:
+			return s
+		}
+	}
+
+	return color.RGBA{R: mix(src.R, dst.R), G: mix(src.G, dst.G), B: mix(src.B, dst.B), A: 0xFF}
+}
+
+// SamplePlayer plays the MegaChip digitized sound, resampled to
+// SampleRate. Like the Beeper, it is an io.Reader of PCM samples.
+type SamplePlayer struct {
+	SampleRate int     // output sample rate, in Hz
+	Volume     float64 // volume, in the range 0..1
+
+	emu      *Emulator
+	sound    MegaSound
+	position float64 // position on the sample, in samples
+}
+
+// NewSamplePlayer creates a new SamplePlayer for the given emulator,
+// using the default sample rate and volume.
+func NewSamplePlayer(c *Emulator) *SamplePlayer {
+	return &SamplePlayer{
+		SampleRate: DefaultSampleRate,
+		Volume:     DefaultVolume,
+		emu:        c,
+	}
+}
+
+// Read fills b with PCM samples. It never returns io.EOF; when no
+// sound is playing, the samples are silent.
+func (p *SamplePlayer) Read(b []byte) (int, error) {
+	return readSamples(b, p.next)
+}
+
+func (p *SamplePlayer) next() int16 {
+	sound := p.emu.Mega.Sound
+	if !sound.Playing || sound.Length == 0 || sound.Rate == 0 {
+		p.sound = MegaSound{}
+		p.position = 0
+		return 0
+	}
+
+	// a new sound restarts the playback
+	if sound != p.sound {
+		p.sound = sound
+		p.position = 0
+	}
+
+	value := (float64(p.emu.Read(sound.Addr+int(p.position))) - 128) / 128
+
+	p.position += float64(sound.Rate) / float64(p.SampleRate)
+	if p.position >= float64(sound.Length) {
+		if sound.Loop {
+			for p.position >= float64(sound.Length) {
+				p.position -= float64(sound.Length)
+			}
+		} else {
+			// the same sound may be played again before the next sample
+			p.emu.Mega.Sound.Playing = false
+			p.sound = MegaSound{}
+			p.position = 0
+		}
+	}
+
+	return toSample(value * p.Volume)
+}
@@ -0,0 +1,308 @@
+package chip8_test
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+// megaROM builds a MegaChip ROM with the given code, followed by the
+// given data at address 0x300.
+func megaROM(t *testing.T, code []byte, data []byte) *chip8.Emulator {
+	t.Helper()
+
+	if len(code) > 0x100 {
+		t.Fatal("code too big")
+	}
+
+	rom := make([]byte, 0x100, 0x100+len(data))
+	copy(rom, code)
+	rom = append(rom, data...)
+
+	c := chip8.NewEmulator(chip8.MEGACHIP)
+	if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestMegaMode(t *testing.T) {
+	c := megaROM(t, []byte{0x00, 0x11, 0x00, 0x10}, nil)
+
+	if _, err := c.Execute(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if !c.Mega.Enabled {
+		t.Fatal("expected MegaChip mode to be enabled")
+	}
+
+	if img := c.Image(); img.Bounds().Dx() != chip8.MegaWidth || img.Bounds().Dy() != chip8.MegaHeight {
+		t.Fatalf("expected a 256x192 image, but got %v", img.Bounds())
+	}
+
+	if _, err := c.Execute(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Mega.Enabled {
+		t.Fatal("expected MegaChip mode to be disabled")
+	}
+
+	if img := c.Image(); img.Bounds().Dx() != 64 || img.Bounds().Dy() != 32 {
+		t.Fatalf("expected a 64x32 image, but got %v", img.Bounds())
+	}
+}
+
+func TestMegaLongI(t *testing.T) {
+	c := megaROM(t, []byte{0x01, 0xAB, 0xCD, 0xEF, 0x60, 0x01}, nil)
+
+	if _, err := c.Execute(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Mega.IHigh != 0xAB || c.I != 0xCDEF {
+		t.Fatalf("expected I to be 0xABCDEF, but was 0x%02X%04X", c.Mega.IHigh, c.I)
+	}
+
+	if c.V[0] != 1 {
+		t.Fatal("expected the instruction after the 4 byte instruction to be executed")
+	}
+}
+
+func TestMegaDraw(t *testing.T) {
+	code := []byte{
+		0x00, 0x11, // MEGAON
+		0x01, 0x00, 0x03, 0x00, // LDHI I, 0x000300
+		0x02, 0x02, // LDPAL 2
+		0x03, 0x02, // SPRW 2
+		0x04, 0x02, // SPRH 2
+		0x09, 0x01, // COLLISION 1
+		0x01, 0x00, 0x03, 0x08, // LDHI I, 0x000308
+		0x60, 0x0A, // V0 = 10
+		0x61, 0x05, // V1 = 5
+		0xD0, 0x10, // DRW V0, V1
+		0x60, 0x0B, // V0 = 11
+		0xD0, 0x10, // DRW V0, V1 (collision)
+	}
+
+	data := []byte{
+		0xFF, 0xFF, 0x00, 0x00, // color 1: red
+		0xFF, 0x00, 0xFF, 0x00, // color 2: green
+		0x01, 0x00, // sprite: red, transparent
+		0x02, 0x01, // sprite: green, red
+	}
+
+	c := megaROM(t, code, data)
+	if _, err := c.Execute(12); err != nil {
+		t.Fatal(err)
+	}
+
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+	green := color.RGBA{G: 0xFF, A: 0xFF}
+	black := color.RGBA{A: 0xFF}
+
+	img := c.Image()
+	expected := map[[2]int]color.RGBA{
+		{10, 5}: red, {11, 5}: red, {12, 5}: black,
+		{10, 6}: green, {11, 6}: green, {12, 6}: red,
+		{9, 5}: black, {10, 7}: black,
+	}
+
+	for pos, want := range expected {
+		if actual := img.RGBAAt(pos[0], pos[1]); actual != want {
+			t.Fatalf("expected color %v at %v, but found %v", want, pos, actual)
+		}
+	}
+
+	if c.V[0xF] != 1 {
+		t.Fatal("expected a collision on the second draw")
+	}
+}
+
+func TestMegaBlend(t *testing.T) {
+	code := []byte{
+		0x00, 0x11, // MEGAON
+		0x01, 0x00, 0x03, 0x00, // LDHI I, 0x000300
+		0x02, 0x01, // LDPAL 1
+		0x03, 0x01, // SPRW 1
+		0x04, 0x01, // SPRH 1
+		0x01, 0x00, 0x03, 0x04, // LDHI I, 0x000304
+		0xD0, 0x00, // DRW V0, V0
+		0x08, 0x02, // BLEND 50%
+		0xD0, 0x00, // DRW V0, V0
+	}
+
+	data := []byte{
+		0xFF, 0xC8, 0x64, 0x00, // color 1
+		0x01, // sprite
+	}
+
+	c := megaROM(t, code, data)
+	if _, err := c.Execute(9); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := c.Mega.Framebuffer.RGBAAt(0, 0); actual != (color.RGBA{R: 0xC8, G: 0x64, A: 0xFF}) {
+		t.Fatalf("unexpected color after normal draw: %v", actual)
+	}
+
+	if _, err := c.Execute(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := c.Mega.Framebuffer.RGBAAt(0, 0); actual != (color.RGBA{R: 0xC8, G: 0x64, A: 0xFF}) {
+		t.Fatalf("blending a color with itself should not change it, but found %v", actual)
+	}
+}
+
+func TestMegaScroll(t *testing.T) {
+	c := megaROM(t, []byte{0x00, 0x11, 0x00, 0xC2, 0x00, 0xB3}, nil)
+	if _, err := c.Execute(1); err != nil {
+		t.Fatal(err)
+	}
+
+	white := color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+	black := color.RGBA{A: 0xFF}
+	c.Mega.Framebuffer.SetRGBA(7, 10, white)
+	c.Mega.Indexes[10*chip8.MegaWidth+7] = 1
+
+	if _, err := c.Execute(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Mega.Framebuffer.RGBAAt(7, 12) != white || c.Mega.Indexes[12*chip8.MegaWidth+7] != 1 {
+		t.Fatal("expected pixel to be scrolled down 2 lines")
+	}
+
+	if c.Mega.Framebuffer.RGBAAt(7, 10) != black || c.Mega.Framebuffer.RGBAAt(7, 0) != black {
+		t.Fatal("expected the vacated lines to be black")
+	}
+
+	if _, err := c.Execute(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Mega.Framebuffer.RGBAAt(7, 9) != white || c.Mega.Indexes[9*chip8.MegaWidth+7] != 1 {
+		t.Fatal("expected pixel to be scrolled up 3 lines")
+	}
+}
+
+func TestMegaMemory(t *testing.T) {
+	code := []byte{
+		0x01, 0x0F, 0x00, 0x00, // LDHI I, 0x0F0000
+		0x02, 0x01, // LDPAL 1
+		0x60, 0x00, // V0 = 0
+		0xF0, 0x29, // I = sprite of V0
+		0xD0, 0x05, // DRW V0, V0, 5
+	}
+
+	c := megaROM(t, code, nil)
+	if len(c.Memory) != chip8.AddrStart+0x100 {
+		t.Fatalf("expected the memory to end with the ROM, but found %d bytes", len(c.Memory))
+	}
+
+	if _, err := c.Execute(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.Memory) != 0x0F0004 {
+		t.Fatalf("expected the memory to grow up to the palette, but found %d bytes", len(c.Memory))
+	}
+
+	// the video memory is kept apart from the 24-bit address space
+	if _, err := c.Execute(3); err != nil {
+		t.Fatal(err)
+	}
+
+	if !c.Pixel(0, 0) || c.Pixel(4, 0) {
+		t.Fatal("expected the sprite to be drawn")
+	}
+
+	c.Reset()
+	if len(c.Memory) != chip8.AddrStart {
+		t.Fatalf("expected Reset to free the memory, but found %d bytes", len(c.Memory))
+	}
+}
+
+func TestMegaSound(t *testing.T) {
+	code := []byte{
+		0x01, 0x00, 0x03, 0x00, // LDHI I, 0x000300
+		0x06, 0x01, // PLAY (once)
+		0x06, 0x00, // PLAY (loop)
+		0x07, 0x00, // STOP
+	}
+
+	data := []byte{
+		0x1F, 0x40, // 8000 Hz
+		0x00, 0x00, 0x04, // 4 samples
+		0x00,                   // unused
+		0x80, 0xFF, 0x00, 0x80, // samples
+	}
+
+	c := megaROM(t, code, data)
+	if _, err := c.Execute(2); err != nil {
+		t.Fatal(err)
+	}
+
+	sound := c.Mega.Sound
+	if !sound.Playing || sound.Loop || sound.Rate != 8000 || sound.Length != 4 || sound.Addr != 0x306 {
+		t.Fatalf("unexpected sound state: %+v", sound)
+	}
+
+	p := chip8.NewSamplePlayer(c)
+	p.SampleRate = 8000
+	p.Volume = 1
+
+	samples := readSamples(t, p, 6)
+	for i, expected := range []int16{0, 32511, -32767, 0, 0, 0} {
+		if samples[i] != expected {
+			t.Fatalf("expected sample %d to be %d, but was %d", i, expected, samples[i])
+		}
+	}
+
+	if c.Mega.Sound.Playing {
+		t.Fatal("expected sound to stop after the last sample")
+	}
+
+	// playing the same sound again restarts it, even right after the
+	// last sample
+	for i := 0; i < 2; i++ {
+		c.PC = chip8.AddrStart + 4
+		if _, err := c.Execute(1); err != nil {
+			t.Fatal(err)
+		}
+
+		samples = readSamples(t, p, 4)
+	}
+
+	samples = append(samples, readSamples(t, p, 2)...)
+	for i, expected := range []int16{0, 32511, -32767, 0, 0, 0} {
+		if samples[i] != expected {
+			t.Fatalf("expected restarted sample %d to be %d, but was %d", i, expected, samples[i])
+		}
+	}
+
+	// looping
+	if _, err := c.Execute(1); err != nil {
+		t.Fatal(err)
+	}
+
+	samples = readSamples(t, p, 6)
+	for i, expected := range []int16{0, 32511, -32767, 0, 0, 32511} {
+		if samples[i] != expected {
+			t.Fatalf("expected looped sample %d to be %d, but was %d", i, expected, samples[i])
+		}
+	}
+
+	if _, err := c.Execute(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Mega.Sound.Playing {
+		t.Fatal("expected sound to be stopped")
+	}
+}
@@ -3,6 +3,7 @@ package chip8
 import (
 	"errors"
 	"io"
+	"sort"
 )
 
 // Common addresses used by the CHIP-8 emulator.
@@ -12,6 +13,11 @@ const (
 	AddrStart  = 0x200
 )
 
+// eagerMemory is the largest memory allocated at once by Reset; the
+// memory of bigger machines (ex: the 16MB of the MegaChip) grows as it
+// is used, starting with the memory reserved for the interpreter.
+const eagerMemory = 0x10000
+
 // ErrLoadOverflow is the error returned when the emulator tries to
 // load a image that is bigger than the maximum available memory.
 var ErrLoadOverflow = errors.New("error loading ROM: size exceeds CHIP-8 memory limit")
@@ -24,36 +30,63 @@ var ErrInvalidAddress = errors.New("invalid memory address")
 // write to the reserved memory of the emulator.
 var ErrMemWrite = errors.New("cannot write into reserved memory address")
 
-// static sprite data
-var sprites = [80]byte{
-	0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
-	0x20, 0x60, 0x20, 0x20, 0x70, // 1
-	0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
-	0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
-	0x90, 0x90, 0xF0, 0x10, 0x10, // 4
-	0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
-	0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
-	0xF0, 0x10, 0x20, 0x40, 0x40, // 7
-	0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
-	0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
-	0xF0, 0x90, 0xF0, 0x90, 0x90, // A
-	0xE0, 0x90, 0xE0, 0x90, 0xE0, // B
-	0xF0, 0x80, 0x80, 0x80, 0xF0, // C
-	0xE0, 0x90, 0x90, 0x90, 0xE0, // D
-	0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
-	0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+// Access is the kind of access allowed to a region of memory.
+type Access int
+
+// Kinds of memory access.
+const (
+	ReadWrite Access = iota // programs can read and write
+	ReadOnly                // reserved for the interpreter; programs can only read
+	Unmapped                // outside of the addressable memory
+)
+
+func (a Access) String() string {
+	switch a {
+	case ReadWrite:
+		return "read/write"
+	case ReadOnly:
+		return "read-only"
+	default:
+		return "unmapped"
+	}
+}
+
+// MemoryRegion is a region of the memory map of a machine.
+type MemoryRegion struct {
+	Region
+	Access Access
 }
 
-// Reset resets the emulator state. This clears all memory and
-// resets all registers to the initial values.
+// Reset resets the emulator state. This clears (and, if needed, allocates)
+// the memory, resets all registers to the initial values and sets the
+// display to the default mode of the machine.
 func (c *Emulator) Reset() {
+	m := c.machine()
+
 	// clear memory
+	c.flushCache()
+	if len(c.Memory) != m.memoryLen() {
+		c.Memory = make([]byte, m.memoryLen())
+	}
+
 	for i := 0; i < len(c.Memory); i++ {
-		if i >= AddrSprite && i < AddrSprite+len(sprites) {
-			c.Memory[i] = sprites[i-AddrSprite]
-		} else {
-			c.Memory[i] = 0
-		}
+		c.Memory[i] = 0
+	}
+
+	// video memory placed after the addressable memory
+	if m.VideoAddr < m.MemorySize {
+		c.video = nil
+	} else if len(c.video) != m.videoLen() {
+		c.video = make([]byte, m.videoLen())
+	}
+
+	for i := range c.video {
+		c.video[i] = 0
+	}
+
+	if m.Font != nil {
+		copy(c.Memory[m.SpriteAddr:], m.Font.Small)
+		copy(c.Memory[m.SpriteAddr+len(m.Font.Small):], m.Font.Big)
 	}
 
 	// clear Vx registers
@@ -62,6 +95,10 @@ func (c *Emulator) Reset() {
 	}
 
 	// clear stack
+	if m.StackDepth > 0 && len(c.Stack) != m.StackDepth {
+		c.Stack = make([]uint16, m.StackDepth)
+	}
+
 	for i := range c.Stack {
 		c.Stack[i] = 0
 	}
@@ -70,37 +107,223 @@ func (c *Emulator) Reset() {
 	c.DT = 0
 	c.ST = 0
 	c.SP = 0
-	c.PC = AddrStart
+	c.PC = uint16(m.StartAddr)
+	c.delaying = false
+	c.Cycles = 0
+	c.frameCycles = 0
+	c.Display = m.Modes[0]
+
+	// clear audio
+	for i := range c.Pattern {
+		c.Pattern[i] = 0
+	}
+
+	c.Pitch = DefaultPatternPitch
+	c.Planes = 1
+
+	// CHIP-8X colors
+	c.resetColors()
+
+	// MegaChip
+	c.resetMega()
 }
 
-// LoadROM loads a given ROM to the emulator memory. Before
-// loading, Reset is called to keep the emulator in a 'clean'
-// state.
-func (c *Emulator) LoadROM(rom io.Reader) error {
-	c.Reset()
+// MemoryMap returns the memory map of the machine, sorted by address:
+// the regions reserved for the interpreter are read-only, the rest of
+// the addressable memory is read/write, and the addresses after it (up
+// to 64K) are unmapped.
+func (m *Machine) MemoryMap() []MemoryRegion {
+	reserved := make([]Region, 0, len(m.Reserved))
+	for _, r := range m.Reserved {
+		if r.End > m.MemorySize {
+			r.End = m.MemorySize
+		}
+
+		if r.Start < r.End {
+			reserved = append(reserved, r)
+		}
+	}
 
-	buffer := make([]byte, 4096-AddrStart)
-	addr := AddrStart
+	sort.SliceStable(reserved, func(i, j int) bool {
+		return reserved[i].Start < reserved[j].Start
+	})
 
-	for {
-		count, err := rom.Read(buffer)
-		var i int
+	var regions []MemoryRegion
+	addr := 0
 
-		for i = 0; i < count && addr < len(c.Memory); i++ {
-			c.Memory[addr] = buffer[i]
-			addr++
+	for _, r := range reserved {
+		if r.Start < addr {
+			r.Start = addr // overlaps the previous region
 		}
 
-		if i < count {
-			return ErrLoadOverflow
+		if r.Start >= r.End {
+			continue
 		}
 
-		if err == io.EOF {
-			return nil
+		if r.Start > addr {
+			regions = append(regions, MemoryRegion{
+				Region: Region{Name: "program", Start: addr, End: r.Start},
+				Access: ReadWrite,
+			})
 		}
 
-		if err != nil {
-			return err
+		if last := len(regions) - 1; last >= 0 && regions[last].Name == r.Name && regions[last].End == r.Start {
+			regions[last].End = r.End
+		} else {
+			regions = append(regions, MemoryRegion{Region: r, Access: ReadOnly})
 		}
+
+		addr = r.End
+	}
+
+	if addr < m.MemorySize {
+		regions = append(regions, MemoryRegion{
+			Region: Region{Name: "program", Start: addr, End: m.MemorySize},
+			Access: ReadWrite,
+		})
 	}
+
+	if m.MemorySize < 0x10000 {
+		regions = append(regions, MemoryRegion{
+			Region: Region{Name: "unmapped", Start: m.MemorySize, End: 0x10000},
+			Access: Unmapped,
+		})
+	}
+
+	return regions
+}
+
+// access returns the access allowed to the address.
+func (m *Machine) access(addr int) Access {
+	if addr < 0 || addr >= m.MemorySize {
+		return Unmapped
+	}
+
+	for _, r := range m.Reserved {
+		if r.Contains(addr) {
+			return ReadOnly
+		}
+	}
+
+	return ReadWrite
+}
+
+// access returns the access allowed to the address, where the devices
+// are always read/write.
+func (c *Emulator) access(addr int) Access {
+	if c.devices != nil && c.deviceAt(addr) != nil {
+		return ReadWrite
+	}
+
+	return c.machine().access(addr)
+}
+
+// store writes data to the memory at addr, through the bus. Unless the
+// memory protection is disabled, writing to a reserved region returns
+// ErrMemWrite; writing outside of the addressable memory always does.
+func (c *Emulator) store(addr int, data ...byte) error {
+	for i := range data {
+		access := c.access(addr + i)
+		if access == Unmapped || access == ReadOnly && !c.Unprotected {
+			return ErrMemWrite
+		}
+	}
+
+	if c.devices == nil {
+		c.grow(addr + len(data))
+		copy(c.Memory[addr:], data)
+		c.InvalidateCache(addr, len(data))
+		return nil
+	}
+
+	for i, value := range data {
+		c.Write(addr+i, value)
+	}
+
+	return nil
+}
+
+// load reads size bytes of the memory at addr, through the bus. Every
+// address must be mapped to a device or be in the addressable memory;
+// otherwise, it returns ErrInvalidAddress. The returned slice must not be
+// changed.
+func (c *Emulator) load(addr int, size int) ([]byte, error) {
+	if c.devices == nil || !c.mapped(addr, size) {
+		if addr < 0 || !c.grow(addr+size) {
+			return nil, ErrInvalidAddress
+		}
+
+		return c.Memory[addr : addr+size], nil
+	}
+
+	data := make([]byte, size)
+	for i := range data {
+		if c.deviceAt(addr+i) == nil && (addr+i < 0 || !c.grow(addr+i+1)) {
+			return nil, ErrInvalidAddress
+		}
+
+		data[i] = c.Read(addr + i)
+	}
+
+	return data, nil
+}
+
+// grow allocates the memory up to end, on the machines whose memory
+// grows as it is used (see eagerMemory). It reports whether end is within
+// the memory.
+func (c *Emulator) grow(end int) bool {
+	if end <= len(c.Memory) {
+		return true
+	}
+
+	if end > c.machine().MemorySize {
+		return false
+	}
+
+	c.Memory = append(c.Memory, make([]byte, end-len(c.Memory))...)
+	return true
+}
+
+// jump sets PC to addr, returning ErrInvalidAddress if there is no
+// instruction there (the address is not mapped, or the instruction
+// would cross the end of the memory).
+func (c *Emulator) jump(addr int) error {
+	if c.access(addr) == Unmapped || c.access(addr+1) == Unmapped {
+		return ErrInvalidAddress
+	}
+
+	c.PC = uint16(addr)
+	return nil
+}
+
+// LoadROM loads a given ROM to the emulator memory, at the start
+// address of the emulated machine. Before loading, Reset is called
+// to keep the emulator in a 'clean' state.
+func (c *Emulator) LoadROM(rom io.Reader) error {
+	c.Reset()
+
+	m := c.machine()
+	addr := m.StartAddr
+
+	fits := m.MemorySize - addr
+
+	// read one byte more than fits, to know if the ROM is too big
+	data, err := io.ReadAll(io.LimitReader(rom, int64(fits)+1))
+	size := len(data)
+	if size > fits {
+		size = fits
+	}
+
+	c.grow(addr + size)
+	copy(c.Memory[addr:], data[:size])
+
+	if err != nil {
+		return err
+	}
+
+	if len(data) > fits {
+		return ErrLoadOverflow
+	}
+
+	return nil
 }
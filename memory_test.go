@@ -10,7 +10,7 @@ import (
 )
 
 func corruptedEmulator() *chip8.Emulator {
-	c := chip8.Emulator{}
+	c := chip8.Emulator{Memory: make([]byte, 4096)}
 
 	// Intentionally corrupt the memory and registers. The values don't matter,
 	// the point is testing if 'reset' goes to a valid state
@@ -154,3 +154,144 @@ func TestLoadBigROM(t *testing.T) {
 		t.Fatalf("expected last memory byte to be 0x%X but was 0x%X", expected, actual)
 	}
 }
+
+func TestLoadROMETI660(t *testing.T) {
+	rom := []byte{0x00, 0x1A, 0x1B, 0x1C, 0x1D, 0xF0}
+	c := corruptedEmulator()
+	c.Machine = chip8.ETI660
+
+	if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.PC != 0x600 {
+		t.Fatalf("expected PC to be 0x600, but was 0x%X", c.PC)
+	}
+
+	for offset := 0; offset < len(rom); offset++ {
+		addr := 0x600 + offset
+		if c.Memory[addr] != rom[offset] {
+			t.Fatalf("address 0x%X should be 0x%X, but was 0x%X", addr, rom[offset], c.Memory[addr])
+		}
+	}
+
+	// the area between the sprites and the start address should be clear
+	for i := chip8.ETI660.SpriteAddr + 80; i < 0x600; i++ {
+		if c.Memory[i] != 0 {
+			t.Fatalf("memory address 0x%X should be zero, but was 0x%X", i, c.Memory[i])
+		}
+	}
+
+	if c.Memory[chip8.ETI660.SpriteAddr] != 0xF0 {
+		t.Fatalf("expected sprite data at address 0x%X", chip8.ETI660.SpriteAddr)
+	}
+}
+
+func TestLoadBigROMETI660(t *testing.T) {
+	tests := []struct {
+		name  string
+		size  int
+		isErr bool
+	}{
+		{name: "Fit", size: 4096 - 0x600},
+		{name: "Overflow", size: 4096 - 0x600 + 1, isErr: true},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			c := chip8.Emulator{Machine: chip8.ETI660}
+			err := c.LoadROM(bytes.NewReader(make([]byte, test.size)))
+
+			if test.isErr && !errors.Is(err, chip8.ErrLoadOverflow) {
+				t.Fatalf("expected ROM overflow error, but got '%v'", err)
+			}
+
+			if !test.isErr && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestLoadBigROMXOCHIP(t *testing.T) {
+	c := chip8.NewEmulator(chip8.XOCHIP)
+
+	if err := c.LoadROM(bytes.NewReader(make([]byte, 0x10000-chip8.AddrStart))); err != nil {
+		t.Fatal(err)
+	}
+
+	err := c.LoadROM(bytes.NewReader(make([]byte, 0x10000-chip8.AddrStart+1)))
+	if !errors.Is(err, chip8.ErrLoadOverflow) {
+		t.Fatalf("expected ROM overflow error, but got '%v'", err)
+	}
+}
+
+func TestMemoryMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		machine *chip8.Machine
+		regions []chip8.MemoryRegion
+	}{
+		{name: "VIP", machine: chip8.COSMACVIP, regions: []chip8.MemoryRegion{
+			{Region: chip8.Region{Name: "interpreter", Start: 0x000, End: 0x200}, Access: chip8.ReadOnly},
+			{Region: chip8.Region{Name: "program", Start: 0x200, End: 0x1000}, Access: chip8.ReadWrite},
+			{Region: chip8.Region{Name: "unmapped", Start: 0x1000, End: 0x10000}, Access: chip8.Unmapped},
+		}},
+		{name: "XO-CHIP", machine: chip8.XOCHIP, regions: []chip8.MemoryRegion{
+			{Region: chip8.Region{Name: "interpreter", Start: 0x000, End: 0x200}, Access: chip8.ReadOnly},
+			{Region: chip8.Region{Name: "program", Start: 0x200, End: 0x10000}, Access: chip8.ReadWrite},
+		}},
+		{name: "MegaChip", machine: chip8.MEGACHIP, regions: []chip8.MemoryRegion{
+			{Region: chip8.Region{Name: "interpreter", Start: 0x000, End: 0x200}, Access: chip8.ReadOnly},
+			{Region: chip8.Region{Name: "program", Start: 0x200, End: 0x1000000}, Access: chip8.ReadWrite},
+		}},
+		{name: "ETI-660", machine: chip8.ETI660, regions: []chip8.MemoryRegion{
+			{Region: chip8.Region{Name: "interpreter", Start: 0x000, End: 0x600}, Access: chip8.ReadOnly},
+			{Region: chip8.Region{Name: "program", Start: 0x600, End: 0x1000}, Access: chip8.ReadWrite},
+			{Region: chip8.Region{Name: "unmapped", Start: 0x1000, End: 0x10000}, Access: chip8.Unmapped},
+		}},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			regions := test.machine.MemoryMap()
+			if len(regions) != len(test.regions) {
+				t.Fatalf("expected %d regions, but got %+v", len(test.regions), regions)
+			}
+
+			for i, r := range regions {
+				if r != test.regions[i] {
+					t.Fatalf("expected region %+v, but got %+v", test.regions[i], r)
+				}
+			}
+		})
+	}
+}
+
+func TestUnprotected(t *testing.T) {
+	var c chip8.Emulator
+	c.Unprotected = true
+	if err := c.LoadROM(bytes.NewReader([]byte{0x60, 0x42, 0xF0, 0x55})); err != nil {
+		t.Fatal(err)
+	}
+
+	c.I = 0x1F0
+	if _, err := c.Execute(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Memory[0x1F0] != 0x42 {
+		t.Fatal("expected the write to the interpreter area")
+	}
+
+	// unmapped memory is never writable
+	c.PC = chip8.AddrStart + 2
+	c.I = 4096
+	if _, err := c.Execute(1); !errors.Is(err, chip8.ErrMemWrite) {
+		t.Fatalf("expected memory write error, but got %v", err)
+	}
+}
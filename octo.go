@@ -0,0 +1,1322 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrOctoSource is returned when the Octo source of a program can not be
+// compiled. The error tells the line and the reason.
+var ErrOctoSource = errors.New("invalid Octo source")
+
+// a token of the Octo source, with the line where it was found
+type octoToken struct {
+	text string
+	line int
+}
+
+// the kinds of references to addresses, resolved when the address of the
+// label is known
+type octoRef int
+
+const (
+	refAddr       octoRef = iota // the nnn of an instruction
+	refLong                      // the word after F000 (i := long)
+	refUnpack                    // v0 := nibble and the high bits, v1 := the low byte (:unpack)
+	refUnpackLong                // v0 := the high byte, v1 := the low byte (:unpack long)
+)
+
+// a reference to an address, at the address of the instruction
+type octoFixup struct {
+	kind   octoRef
+	addr   int
+	nibble byte
+	line   int
+}
+
+// a user defined macro (:macro)
+type octoMacro struct {
+	args []string
+	body []octoToken
+}
+
+// an open loop, with the jumps of its 'while' statements
+type octoLoop struct {
+	start  int
+	whiles []int
+}
+
+// a parsed condition of an 'if' or 'while' statement
+type octoCondition struct {
+	x       byte
+	op      string
+	y       byte
+	n       byte
+	literal bool
+}
+
+// maximum number of macro expansions, to stop recursive macros
+const octoMaxExpansions = 100000
+
+type octoCompiler struct {
+	tokens     []octoToken
+	pos        int
+	line       int
+	rom        []byte
+	pc         int
+	mainSlot   bool // the first instruction is reserved for the jump to main
+	labels     map[string]int
+	consts     map[string]float64
+	aliases    map[string]byte
+	macros     map[string]*octoMacro
+	fixups     map[string][]octoFixup
+	loops      []*octoLoop
+	blocks     []int // jumps of the open 'if ... begin' blocks
+	expansions int
+}
+
+// CompileOcto compiles the source of an Octo program to a ROM, loaded at
+// the usual start address (0x200). The whole Octo language is supported,
+// including macros and :calc expressions, except for strings (and so
+// :stringmode). Like in Octo, the expressions of :calc are evaluated from
+// right to left, without operator precedence.
+//
+// The errors are returned as ErrOctoSource, with the line of the error.
+func CompileOcto(source string) ([]byte, error) {
+	c := &octoCompiler{
+		tokens:  tokenizeOcto(source),
+		pc:      AddrStart,
+		labels:  map[string]int{},
+		consts:  map[string]float64{},
+		aliases: map[string]byte{},
+		macros:  map[string]*octoMacro{},
+		fixups:  map[string][]octoFixup{},
+	}
+
+	// a jump to main, dropped if the program starts with main
+	c.mainSlot = true
+	if err := c.emitOp(0x1000); err != nil {
+		return nil, err
+	}
+
+	if err := c.reference("main", refAddr, AddrStart); err != nil {
+		return nil, err
+	}
+
+	for c.pos < len(c.tokens) {
+		if err := c.statement(); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case len(c.loops) > 0:
+		return nil, c.errorf("loop without again")
+	case len(c.blocks) > 0:
+		return nil, c.errorf("begin without end")
+	}
+
+	if _, ok := c.labels["main"]; !ok {
+		return nil, fmt.Errorf("%w: missing main label", ErrOctoSource)
+	}
+
+	for name, list := range c.fixups {
+		c.line = list[0].line
+		return nil, c.errorf("undefined name %s", name)
+	}
+
+	return c.rom, nil
+}
+
+// tokenizeOcto splits the source in tokens, dropping the comments.
+func tokenizeOcto(source string) []octoToken {
+	var tokens []octoToken
+
+	for i, line := range strings.Split(source, "\n") {
+		for _, field := range strings.Fields(line) {
+			if strings.HasPrefix(field, "#") {
+				break
+			}
+
+			tokens = append(tokens, octoToken{text: field, line: i + 1})
+		}
+	}
+
+	return tokens
+}
+
+// parseOctoNumber parses a number in the syntax of Octo: decimal, or
+// hexadecimal and binary with the 0x and 0b prefixes, with an optional
+// minus sign.
+func parseOctoNumber(token string) (int64, error) {
+	digits := strings.TrimPrefix(token, "-")
+	sign := int64(1)
+	if digits != token {
+		sign = -1
+	}
+
+	var value int64
+	var err error
+
+	switch {
+	case strings.HasPrefix(digits, "0x"), strings.HasPrefix(digits, "0X"):
+		value, err = strconv.ParseInt(digits[2:], 16, 64)
+	case strings.HasPrefix(digits, "0b"), strings.HasPrefix(digits, "0B"):
+		value, err = strconv.ParseInt(digits[2:], 2, 64)
+	default:
+		value, err = strconv.ParseInt(digits, 10, 64)
+	}
+
+	return sign * value, err
+}
+
+func (c *octoCompiler) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: line %d: %s", ErrOctoSource, c.line, fmt.Sprintf(format, args...))
+}
+
+// next returns the next token.
+func (c *octoCompiler) next() (string, error) {
+	if c.pos >= len(c.tokens) {
+		return "", c.errorf("unexpected end of the source")
+	}
+
+	token := c.tokens[c.pos]
+	c.pos++
+	c.line = token.line
+	return token.text, nil
+}
+
+// peek returns the next token, without consuming it.
+func (c *octoCompiler) peek() string {
+	if c.pos >= len(c.tokens) {
+		return ""
+	}
+
+	return c.tokens[c.pos].text
+}
+
+// expect consumes the next token, which must be the given one.
+func (c *octoCompiler) expect(text string) error {
+	token, err := c.next()
+	if err == nil && token != text {
+		err = c.errorf("expected %s, but found %s", text, token)
+	}
+
+	return err
+}
+
+// emit writes the bytes at the current address.
+func (c *octoCompiler) emit(data ...byte) error {
+	for _, b := range data {
+		if c.pc < AddrStart || c.pc > 0xFFFF {
+			return c.errorf("address 0x%X is out of the memory", c.pc)
+		}
+
+		i := c.pc - AddrStart
+		for len(c.rom) <= i {
+			c.rom = append(c.rom, 0)
+		}
+
+		c.rom[i] = b
+		c.pc++
+	}
+
+	return nil
+}
+
+// emitOp writes an instruction.
+func (c *octoCompiler) emitOp(opcode uint16) error {
+	return c.emit(byte(opcode>>8), byte(opcode))
+}
+
+// define creates a label at the given address.
+func (c *octoCompiler) define(name string, addr int) error {
+	if _, ok := c.labels[name]; ok {
+		return c.errorf("label %s is already defined", name)
+	}
+
+	if !isOctoName(name) {
+		return c.errorf("invalid label name %s", name)
+	}
+
+	// a program starting with main does not need the jump to it
+	if name == "main" && c.mainSlot && c.pc == AddrStart+2 && len(c.rom) == 2 {
+		c.rom, c.pc, c.mainSlot = nil, AddrStart, false
+		delete(c.fixups, "main")
+		addr = AddrStart
+	}
+
+	c.labels[name] = addr
+	for _, f := range c.fixups[name] {
+		if err := c.resolve(f, addr); err != nil {
+			return err
+		}
+	}
+
+	delete(c.fixups, name)
+	return nil
+}
+
+// reference refers to the address of the label (or of the constant) on
+// the instruction at addr; it is resolved when the label is defined.
+func (c *octoCompiler) reference(name string, kind octoRef, addr int) error {
+	return c.referenceNibble(name, kind, addr, 0)
+}
+
+func (c *octoCompiler) referenceNibble(name string, kind octoRef, addr int, nibble byte) error {
+	f := octoFixup{kind: kind, addr: addr, nibble: nibble, line: c.line}
+
+	if value, ok := c.labels[name]; ok {
+		return c.resolve(f, value)
+	}
+
+	if value, ok := c.constant(name); ok {
+		return c.resolve(f, int(value))
+	}
+
+	if !isOctoName(name) {
+		return c.errorf("invalid address %s", name)
+	}
+
+	c.fixups[name] = append(c.fixups[name], f)
+	return nil
+}
+
+// resolve writes the address on the instruction of the reference.
+func (c *octoCompiler) resolve(f octoFixup, value int) error {
+	max := 0xFFF
+	if f.kind == refLong || f.kind == refUnpackLong {
+		max = 0xFFFF
+	}
+
+	if value < 0 || value > max {
+		c.line = f.line
+		return c.errorf("address 0x%X is out of range", value)
+	}
+
+	i := f.addr - AddrStart
+	switch f.kind {
+	case refAddr:
+		c.rom[i] = c.rom[i]&0xF0 | byte(value>>8)
+		c.rom[i+1] = byte(value)
+	case refLong:
+		c.rom[i], c.rom[i+1] = byte(value>>8), byte(value)
+	case refUnpack:
+		c.rom[i+1] = f.nibble<<4 | byte(value>>8)
+		c.rom[i+3] = byte(value)
+	case refUnpackLong:
+		c.rom[i+1], c.rom[i+3] = byte(value>>8), byte(value)
+	}
+
+	return nil
+}
+
+// emitAddr writes an instruction with an address (ex: jump).
+func (c *octoCompiler) emitAddr(opcode uint16) error {
+	name, err := c.next()
+	if err != nil {
+		return err
+	}
+
+	if err := c.emitOp(opcode); err != nil {
+		return err
+	}
+
+	return c.reference(name, refAddr, c.pc-2)
+}
+
+// isOctoName reports whether the token may be the name of a label or
+// constant.
+func isOctoName(token string) bool {
+	if token == "" || strings.ContainsAny(token[:1], ":\"{}()0123456789-") {
+		return false
+	}
+
+	_, keyword := octoKeywords[token]
+	return !keyword
+}
+
+// the words of the language, which can not be used as names
+var octoKeywords = map[string]bool{
+	":=": true, "+=": true, "-=": true, "=-": true, "|=": true, "&=": true, "^=": true, ">>=": true, "<<=": true,
+	"==": true, "!=": true, "<": true, ">": true, "<=": true, ">=": true, "key": true, "-key": true,
+	"if": true, "then": true, "begin": true, "else": true, "end": true, "loop": true, "while": true, "again": true,
+	"i": true, "hex": true, "bighex": true, "long": true, "random": true, "delay": true, "buzzer": true, "pitch": true,
+	"clear": true, "return": true, ";": true, "exit": true, "hires": true, "lores": true, "audio": true, "plane": true,
+	"scroll-up": true, "scroll-down": true, "scroll-left": true, "scroll-right": true, "bcd": true,
+	"save": true, "load": true, "saveflags": true, "loadflags": true, "sprite": true, "jump": true, "jump0": true, "native": true,
+}
+
+// register returns the number of the register named by the token (ex:
+// "v3", or an alias of it).
+func (c *octoCompiler) register(token string) (byte, bool) {
+	if x, ok := c.aliases[token]; ok {
+		return x, true
+	}
+
+	if len(token) == 2 && (token[0] == 'v' || token[0] == 'V') {
+		if x, err := strconv.ParseUint(token[1:], 16, 8); err == nil {
+			return byte(x), true
+		}
+	}
+
+	return 0, false
+}
+
+// nextRegister returns the register of the next token.
+func (c *octoCompiler) nextRegister() (byte, error) {
+	token, err := c.next()
+	if err != nil {
+		return 0, err
+	}
+
+	x, ok := c.register(token)
+	if !ok {
+		return 0, c.errorf("expected a register, but found %s", token)
+	}
+
+	return x, nil
+}
+
+// constant returns the value of a number or of a constant.
+func (c *octoCompiler) constant(token string) (float64, bool) {
+	if value, err := parseOctoNumber(token); err == nil {
+		return float64(value), true
+	}
+
+	value, ok := c.consts[token]
+	return value, ok
+}
+
+// value returns the value of the token, which must be a number, a
+// constant or a label that is already defined.
+func (c *octoCompiler) value(token string) (float64, error) {
+	if value, ok := c.constant(token); ok {
+		return value, nil
+	}
+
+	if addr, ok := c.labels[token]; ok {
+		return float64(addr), nil
+	}
+
+	return 0, c.errorf("undefined value %s", token)
+}
+
+// nextByte returns the value of the next token, which must fit in a
+// byte (negative values are stored in two's complement).
+func (c *octoCompiler) nextByte() (byte, error) {
+	token, err := c.next()
+	if err != nil {
+		return 0, err
+	}
+
+	value, ok := c.constant(token)
+	if !ok {
+		return 0, c.errorf("undefined value %s", token)
+	}
+
+	if value < -128 || value > 255 {
+		return 0, c.errorf("value %s does not fit in a byte", token)
+	}
+
+	return byte(int(value)), nil
+}
+
+// nextNibble returns the value of the next token, which must fit in 4
+// bits.
+func (c *octoCompiler) nextNibble() (byte, error) {
+	token, err := c.next()
+	if err != nil {
+		return 0, err
+	}
+
+	value, ok := c.constant(token)
+	if !ok {
+		return 0, c.errorf("undefined value %s", token)
+	}
+
+	if value < 0 || value > 15 {
+		return 0, c.errorf("value %s does not fit in 4 bits", token)
+	}
+
+	return byte(value), nil
+}
+
+// statement compiles the next statement.
+func (c *octoCompiler) statement() error {
+	token, err := c.next()
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(token, ":") && token != ":" {
+		return c.directive(token)
+	}
+
+	if op, ok := octoSimple[token]; ok {
+		return c.emitOp(op)
+	}
+
+	switch token {
+	case ":":
+		name, err := c.next()
+		if err != nil {
+			return err
+		}
+
+		return c.define(name, c.pc)
+	case "scroll-down", "scroll-up":
+		n, err := c.nextNibble()
+		if err != nil {
+			return err
+		}
+
+		if token == "scroll-down" {
+			return c.emitOp(0x00C0 | uint16(n))
+		}
+
+		return c.emitOp(0x00D0 | uint16(n))
+	case "plane":
+		n, err := c.nextNibble()
+		if err != nil {
+			return err
+		}
+
+		return c.emitOp(0xF001 | uint16(n)<<8)
+	case "jump":
+		return c.emitAddr(0x1000)
+	case "jump0":
+		return c.emitAddr(0xB000)
+	case "native":
+		return c.emitAddr(0x0000)
+	case "bcd", "saveflags", "loadflags":
+		x, err := c.nextRegister()
+		if err != nil {
+			return err
+		}
+
+		return c.emitOp(map[string]uint16{"bcd": 0xF033, "saveflags": 0xF075, "loadflags": 0xF085}[token] | uint16(x)<<8)
+	case "save", "load":
+		return c.saveLoad(token == "save")
+	case "sprite":
+		return c.sprite()
+	case "delay", "buzzer", "pitch":
+		if err := c.expect(":="); err != nil {
+			return err
+		}
+
+		x, err := c.nextRegister()
+		if err != nil {
+			return err
+		}
+
+		return c.emitOp(map[string]uint16{"delay": 0xF015, "buzzer": 0xF018, "pitch": 0xF03A}[token] | uint16(x)<<8)
+	case "i":
+		return c.index()
+	case "if":
+		return c.ifStatement()
+	case "else":
+		return c.elseStatement()
+	case "end":
+		return c.endStatement()
+	case "loop":
+		c.loops = append(c.loops, &octoLoop{start: c.pc})
+		return nil
+	case "while":
+		return c.whileStatement()
+	case "again":
+		return c.againStatement()
+	}
+
+	if m, ok := c.macros[token]; ok {
+		return c.expand(m)
+	}
+
+	if x, ok := c.register(token); ok {
+		return c.registerOp(x)
+	}
+
+	// numbers are data, and names are calls to subroutines
+	if value, ok := c.constant(token); ok {
+		if value < -128 || value > 255 {
+			return c.errorf("value %s does not fit in a byte", token)
+		}
+
+		return c.emit(byte(int(value)))
+	}
+
+	if !isOctoName(token) {
+		return c.errorf("unexpected %s", token)
+	}
+
+	if err := c.emitOp(0x2000); err != nil {
+		return err
+	}
+
+	return c.reference(token, refAddr, c.pc-2)
+}
+
+// the statements without operands
+var octoSimple = map[string]uint16{
+	"clear":        0x00E0,
+	"return":       0x00EE,
+	";":            0x00EE,
+	"exit":         0x00FD,
+	"lores":        0x00FE,
+	"hires":        0x00FF,
+	"scroll-right": 0x00FB,
+	"scroll-left":  0x00FC,
+	"audio":        0xF002,
+}
+
+// directive compiles the statements starting with a colon.
+func (c *octoCompiler) directive(token string) error {
+	switch token {
+	case ":const":
+		name, err := c.next()
+		if err != nil {
+			return err
+		}
+
+		text, err := c.next()
+		if err != nil {
+			return err
+		}
+
+		value, err := c.value(text)
+		if err != nil {
+			return err
+		}
+
+		return c.defineConst(name, value)
+	case ":calc":
+		name, err := c.next()
+		if err != nil {
+			return err
+		}
+
+		value, err := c.block()
+		if err != nil {
+			return err
+		}
+
+		return c.defineConst(name, value)
+	case ":alias":
+		name, err := c.next()
+		if err != nil {
+			return err
+		}
+
+		x, err := c.nextRegister()
+		if err != nil {
+			return err
+		}
+
+		if !isOctoName(name) {
+			return c.errorf("invalid alias name %s", name)
+		}
+
+		c.aliases[name] = x
+		return nil
+	case ":next":
+		name, err := c.next()
+		if err != nil {
+			return err
+		}
+
+		return c.define(name, c.pc+1)
+	case ":org":
+		value, err := c.operand()
+		if err != nil {
+			return err
+		}
+
+		c.pc = int(value)
+		return nil
+	case ":byte":
+		value, err := c.operand()
+		if err != nil {
+			return err
+		}
+
+		return c.emit(byte(int(value)))
+	case ":call":
+		return c.emitAddr(0x2000)
+	case ":unpack":
+		return c.unpack()
+	case ":macro":
+		return c.macro()
+	case ":breakpoint":
+		_, err := c.next()
+		return err
+	case ":monitor":
+		for i := 0; i < 2; i++ {
+			if _, err := c.operand(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case ":assert":
+		value, err := c.block()
+		if err == nil && value == 0 {
+			err = c.errorf("assertion failed")
+		}
+
+		return err
+	}
+
+	return c.errorf("unsupported directive %s", token)
+}
+
+// defineConst creates a constant.
+func (c *octoCompiler) defineConst(name string, value float64) error {
+	if !isOctoName(name) {
+		return c.errorf("invalid constant name %s", name)
+	}
+
+	c.consts[name] = value
+	return nil
+}
+
+// operand returns the value of the next token, or of the expression
+// between braces.
+func (c *octoCompiler) operand() (float64, error) {
+	if c.peek() == "{" {
+		return c.block()
+	}
+
+	token, err := c.next()
+	if err != nil {
+		return 0, err
+	}
+
+	return c.value(token)
+}
+
+// unpack compiles ':unpack nibble label' and ':unpack long label'.
+func (c *octoCompiler) unpack() error {
+	token, err := c.next()
+	if err != nil {
+		return err
+	}
+
+	kind, nibble := refUnpackLong, byte(0)
+	if token != "long" {
+		value, ok := c.constant(token)
+		if !ok || value < 0 || value > 15 {
+			return c.errorf("invalid nibble %s", token)
+		}
+
+		kind, nibble = refUnpack, byte(value)
+	}
+
+	name, err := c.next()
+	if err != nil {
+		return err
+	}
+
+	addr := c.pc
+	if err := c.emit(0x60, 0x00, 0x61, 0x00); err != nil {
+		return err
+	}
+
+	return c.referenceNibble(name, kind, addr, nibble)
+}
+
+// macro records a macro: ':macro name args { body }'.
+func (c *octoCompiler) macro() error {
+	name, err := c.next()
+	if err != nil {
+		return err
+	}
+
+	m := &octoMacro{}
+	for {
+		token, err := c.next()
+		if err != nil {
+			return err
+		}
+
+		if token == "{" {
+			break
+		}
+
+		m.args = append(m.args, token)
+	}
+
+	for depth := 1; ; {
+		if _, err := c.next(); err != nil {
+			return err
+		}
+
+		token := c.tokens[c.pos-1]
+
+		switch token.text {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+
+		if depth == 0 {
+			break
+		}
+
+		m.body = append(m.body, token)
+	}
+
+	if !isOctoName(name) {
+		return c.errorf("invalid macro name %s", name)
+	}
+
+	c.macros[name] = m
+	return nil
+}
+
+// expand replaces a macro by its body, with the arguments replaced by
+// the tokens after it.
+func (c *octoCompiler) expand(m *octoMacro) error {
+	if c.expansions++; c.expansions > octoMaxExpansions {
+		return c.errorf("too many macro expansions")
+	}
+
+	args := map[string]string{}
+	for _, arg := range m.args {
+		token, err := c.next()
+		if err != nil {
+			return err
+		}
+
+		args[arg] = token
+	}
+
+	body := make([]octoToken, len(m.body))
+	for i, token := range m.body {
+		if value, ok := args[token.text]; ok {
+			token.text = value
+		}
+
+		body[i] = token
+	}
+
+	// the tokens already compiled are not needed anymore
+	c.tokens, c.pos = append(body, c.tokens[c.pos:]...), 0
+	return nil
+}
+
+// block evaluates the expression between braces (see calc).
+func (c *octoCompiler) block() (float64, error) {
+	if err := c.expect("{"); err != nil {
+		return 0, err
+	}
+
+	return c.calc("}")
+}
+
+// calc evaluates an expression up to the closing token. The operators
+// are applied from right to left, like in Octo.
+func (c *octoCompiler) calc(closing string) (float64, error) {
+	left, err := c.calcTerm()
+	if err != nil {
+		return 0, err
+	}
+
+	op, err := c.next()
+	if err != nil || op == closing {
+		return left, err
+	}
+
+	right, err := c.calc(closing)
+	if err != nil {
+		return 0, err
+	}
+
+	return c.binary(op, left, right)
+}
+
+// calcTerm evaluates a value of an expression, with its unary operators.
+func (c *octoCompiler) calcTerm() (float64, error) {
+	token, err := c.next()
+	if err != nil {
+		return 0, err
+	}
+
+	if f, ok := octoUnary[token]; ok {
+		value, err := c.calcTerm()
+		return f(value), err
+	}
+
+	switch token {
+	case "(":
+		return c.calc(")")
+	case "@":
+		addr, err := c.calcTerm()
+		if i := int(addr) - AddrStart; err == nil && i >= 0 && i < len(c.rom) {
+			return float64(c.rom[i]), nil
+		}
+
+		return 0, err
+	case "HERE":
+		return float64(c.pc), nil
+	case "PI":
+		return math.Pi, nil
+	case "E":
+		return math.E, nil
+	}
+
+	return c.value(token)
+}
+
+// the unary operators of the expressions
+var octoUnary = map[string]func(float64) float64{
+	"-":     func(a float64) float64 { return -a },
+	"~":     func(a float64) float64 { return float64(^int64(a)) },
+	"!":     func(a float64) float64 { return octoBool(a == 0) },
+	"sin":   math.Sin,
+	"cos":   math.Cos,
+	"tan":   math.Tan,
+	"exp":   math.Exp,
+	"log":   math.Log,
+	"abs":   math.Abs,
+	"sqrt":  math.Sqrt,
+	"ceil":  math.Ceil,
+	"floor": math.Floor,
+	"sign": func(a float64) float64 {
+		switch {
+		case a > 0:
+			return 1
+		case a < 0:
+			return -1
+		default:
+			return 0
+		}
+	},
+}
+
+// binary applies a binary operator of the expressions.
+func (c *octoCompiler) binary(op string, a, b float64) (float64, error) {
+	switch op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		return a / b, nil
+	case "%":
+		return math.Mod(a, b), nil
+	case "&":
+		return float64(int64(a) & int64(b)), nil
+	case "|":
+		return float64(int64(a) | int64(b)), nil
+	case "^":
+		return float64(int64(a) ^ int64(b)), nil
+	case "<<":
+		return float64(int64(a) << uint64(b)), nil
+	case ">>":
+		return float64(int64(a) >> uint64(b)), nil
+	case "pow":
+		return math.Pow(a, b), nil
+	case "min":
+		return math.Min(a, b), nil
+	case "max":
+		return math.Max(a, b), nil
+	case "<":
+		return octoBool(a < b), nil
+	case ">":
+		return octoBool(a > b), nil
+	case "<=":
+		return octoBool(a <= b), nil
+	case ">=":
+		return octoBool(a >= b), nil
+	case "==":
+		return octoBool(a == b), nil
+	case "!=":
+		return octoBool(a != b), nil
+	}
+
+	return 0, c.errorf("unknown operator %s", op)
+}
+
+func octoBool(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// saveLoad compiles 'save vx', 'load vx' and the ranges of the XO-CHIP
+// ('save vx - vy').
+func (c *octoCompiler) saveLoad(save bool) error {
+	x, err := c.nextRegister()
+	if err != nil {
+		return err
+	}
+
+	if c.peek() != "-" {
+		if save {
+			return c.emitOp(0xF055 | uint16(x)<<8)
+		}
+
+		return c.emitOp(0xF065 | uint16(x)<<8)
+	}
+
+	c.pos++
+	y, err := c.nextRegister()
+	if err != nil {
+		return err
+	}
+
+	if save {
+		return c.emitOp(0x5002 | uint16(x)<<8 | uint16(y)<<4)
+	}
+
+	return c.emitOp(0x5003 | uint16(x)<<8 | uint16(y)<<4)
+}
+
+// sprite compiles 'sprite vx vy n'.
+func (c *octoCompiler) sprite() error {
+	x, err := c.nextRegister()
+	if err != nil {
+		return err
+	}
+
+	y, err := c.nextRegister()
+	if err != nil {
+		return err
+	}
+
+	n, err := c.nextNibble()
+	if err != nil {
+		return err
+	}
+
+	return c.emitOp(0xD000 | uint16(x)<<8 | uint16(y)<<4 | uint16(n))
+}
+
+// index compiles the statements of the I register.
+func (c *octoCompiler) index() error {
+	op, err := c.next()
+	if err != nil {
+		return err
+	}
+
+	if op == "+=" {
+		x, err := c.nextRegister()
+		if err != nil {
+			return err
+		}
+
+		return c.emitOp(0xF01E | uint16(x)<<8)
+	}
+
+	if op != ":=" {
+		return c.errorf("unknown operator i %s", op)
+	}
+
+	switch c.peek() {
+	case "hex", "bighex":
+		token, _ := c.next()
+
+		x, err := c.nextRegister()
+		if err != nil {
+			return err
+		}
+
+		if token == "hex" {
+			return c.emitOp(0xF029 | uint16(x)<<8)
+		}
+
+		return c.emitOp(0xF030 | uint16(x)<<8)
+	case "long":
+		c.pos++
+
+		name, err := c.next()
+		if err != nil {
+			return err
+		}
+
+		if err := c.emit(0xF0, 0x00, 0x00, 0x00); err != nil {
+			return err
+		}
+
+		return c.reference(name, refLong, c.pc-2)
+	}
+
+	return c.emitAddr(0xA000)
+}
+
+// registerOp compiles the statements of the Vx registers.
+func (c *octoCompiler) registerOp(x byte) error {
+	op, err := c.next()
+	if err != nil {
+		return err
+	}
+
+	operand, err := c.next()
+	if err != nil {
+		return err
+	}
+
+	vx := uint16(x) << 8
+	y, isRegister := c.register(operand)
+	vy := uint16(y) << 4
+
+	if op == ":=" && !isRegister {
+		switch operand {
+		case "random":
+			n, err := c.nextByte()
+			if err != nil {
+				return err
+			}
+
+			return c.emitOp(0xC000 | vx | uint16(n))
+		case "key":
+			return c.emitOp(0xF00A | vx)
+		case "delay":
+			return c.emitOp(0xF007 | vx)
+		}
+	}
+
+	if logic, ok := octoLogic[op]; ok {
+		if !isRegister {
+			return c.errorf("expected a register, but found %s", operand)
+		}
+
+		return c.emitOp(logic | vx | vy)
+	}
+
+	if isRegister {
+		switch op {
+		case ":=":
+			return c.emitOp(0x8000 | vx | vy)
+		case "+=":
+			return c.emitOp(0x8004 | vx | vy)
+		case "-=":
+			return c.emitOp(0x8005 | vx | vy)
+		}
+
+		return c.errorf("unknown operator %s", op)
+	}
+
+	c.pos--
+	n, err := c.nextByte()
+	if err != nil {
+		return err
+	}
+
+	switch op {
+	case ":=":
+		return c.emitOp(0x6000 | vx | uint16(n))
+	case "+=":
+		return c.emitOp(0x7000 | vx | uint16(n))
+	case "-=":
+		return c.emitOp(0x7000 | vx | uint16(-n))
+	}
+
+	return c.errorf("unknown operator %s", op)
+}
+
+// the operators between registers only
+var octoLogic = map[string]uint16{
+	"|=":  0x8001,
+	"&=":  0x8002,
+	"^=":  0x8003,
+	"=-":  0x8007,
+	">>=": 0x8006,
+	"<<=": 0x800E,
+}
+
+// condition parses the condition of an 'if' or 'while' statement.
+func (c *octoCompiler) condition() (*octoCondition, error) {
+	x, err := c.nextRegister()
+	if err != nil {
+		return nil, err
+	}
+
+	op, err := c.next()
+	if err != nil {
+		return nil, err
+	}
+
+	cond := &octoCondition{x: x, op: op}
+	switch op {
+	case "key", "-key":
+		return cond, nil
+	case "==", "!=", "<", ">", "<=", ">=":
+	default:
+		return nil, c.errorf("unknown comparison %s", op)
+	}
+
+	operand, err := c.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if y, ok := c.register(operand); ok {
+		cond.y = y
+		return cond, nil
+	}
+
+	c.pos--
+	cond.literal = true
+	cond.n, err = c.nextByte()
+	return cond, err
+}
+
+// skip writes the instructions that skip the next one when the condition
+// is false (or when it is true, if negated). The ordering comparisons
+// use VF.
+func (c *octoCompiler) skip(cond *octoCondition, negated bool) error {
+	vx, vy, n := uint16(cond.x)<<8, uint16(cond.y)<<4, uint16(cond.n)
+
+	switch cond.op {
+	case "key", "-key":
+		if (cond.op == "key") != negated {
+			return c.emitOp(0xE0A1 | vx)
+		}
+
+		return c.emitOp(0xE09E | vx)
+	case "==", "!=":
+		skipUnequal := (cond.op == "==") != negated
+		switch {
+		case cond.literal && skipUnequal:
+			return c.emitOp(0x4000 | vx | n)
+		case cond.literal:
+			return c.emitOp(0x3000 | vx | n)
+		case skipUnequal:
+			return c.emitOp(0x9000 | vx | vy)
+		default:
+			return c.emitOp(0x5000 | vx | vy)
+		}
+	}
+
+	// VF := the operand
+	load := 0x8F00 | vy
+	if cond.literal {
+		load = 0x6F00 | n
+	}
+
+	if err := c.emitOp(load); err != nil {
+		return err
+	}
+
+	// VF = Vx >= the operand (VF =- Vx), or the operand >= Vx (VF -= Vx)
+	compare, trueIfSet := uint16(0x8F05), cond.op == "<="
+	if cond.op == "<" || cond.op == ">=" {
+		compare, trueIfSet = 0x8F07, cond.op == ">="
+	}
+
+	if err := c.emitOp(compare | vx>>4); err != nil {
+		return err
+	}
+
+	if trueIfSet == negated {
+		return c.emitOp(0x4F00)
+	}
+
+	return c.emitOp(0x3F00)
+}
+
+// ifStatement compiles 'if ... then' and 'if ... begin'.
+func (c *octoCompiler) ifStatement() error {
+	cond, err := c.condition()
+	if err != nil {
+		return err
+	}
+
+	token, err := c.next()
+	if err != nil {
+		return err
+	}
+
+	switch token {
+	case "then":
+		return c.skip(cond, false)
+	case "begin":
+		if err := c.skip(cond, true); err != nil {
+			return err
+		}
+
+		c.blocks = append(c.blocks, c.pc)
+		return c.emitOp(0x1000)
+	}
+
+	return c.errorf("expected then or begin, but found %s", token)
+}
+
+func (c *octoCompiler) elseStatement() error {
+	if len(c.blocks) == 0 {
+		return c.errorf("else without begin")
+	}
+
+	jump := c.pc
+	if err := c.emitOp(0x1000); err != nil {
+		return err
+	}
+
+	top := len(c.blocks) - 1
+	if err := c.resolve(octoFixup{kind: refAddr, addr: c.blocks[top], line: c.line}, c.pc); err != nil {
+		return err
+	}
+
+	c.blocks[top] = jump
+	return nil
+}
+
+func (c *octoCompiler) endStatement() error {
+	if len(c.blocks) == 0 {
+		return c.errorf("end without begin")
+	}
+
+	top := len(c.blocks) - 1
+	jump := c.blocks[top]
+	c.blocks = c.blocks[:top]
+	return c.resolve(octoFixup{kind: refAddr, addr: jump, line: c.line}, c.pc)
+}
+
+func (c *octoCompiler) whileStatement() error {
+	if len(c.loops) == 0 {
+		return c.errorf("while without loop")
+	}
+
+	cond, err := c.condition()
+	if err != nil {
+		return err
+	}
+
+	if err := c.skip(cond, true); err != nil {
+		return err
+	}
+
+	loop := c.loops[len(c.loops)-1]
+	loop.whiles = append(loop.whiles, c.pc)
+	return c.emitOp(0x1000)
+}
+
+func (c *octoCompiler) againStatement() error {
+	if len(c.loops) == 0 {
+		return c.errorf("again without loop")
+	}
+
+	loop := c.loops[len(c.loops)-1]
+	c.loops = c.loops[:len(c.loops)-1]
+
+	if err := c.emitOp(0x1000 | uint16(loop.start)); err != nil {
+		return err
+	}
+
+	for _, jump := range loop.whiles {
+		if err := c.resolve(octoFixup{kind: refAddr, addr: jump, line: c.line}, c.pc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package chip8_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+func TestCompileOcto(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		rom    []byte
+	}{
+		{"Empty", ": main", nil},
+		{"Simple", ": main clear ; return exit hires lores scroll-left scroll-right audio", []byte{0x00, 0xE0, 0x00, 0xEE, 0x00, 0xEE, 0x00, 0xFD, 0x00, 0xFF, 0x00, 0xFE, 0x00, 0xFC, 0x00, 0xFB, 0xF0, 0x02}},
+		{"Scroll", ": main scroll-down 3 scroll-up 0xA", []byte{0x00, 0xC3, 0x00, 0xDA}},
+		{"Registers", ": main v1 := 0x22 v1 := v2 v1 += 3 v1 -= 3 v1 += v2 v1 -= v2 v1 =- v2", []byte{0x61, 0x22, 0x81, 0x20, 0x71, 0x03, 0x71, 0xFD, 0x81, 0x24, 0x81, 0x25, 0x81, 0x27}},
+		{"Logic", ": main v1 |= v2 v1 &= v2 v1 ^= v2 v1 >>= v2 v1 <<= v2", []byte{0x81, 0x21, 0x81, 0x22, 0x81, 0x23, 0x81, 0x26, 0x81, 0x2E}},
+		{"Sources", ": main vA := random 0x0F vB := key vC := delay", []byte{0xCA, 0x0F, 0xFB, 0x0A, 0xFC, 0x07}},
+		{"Timers", ": main delay := v1 buzzer := v2 pitch := v3", []byte{0xF1, 0x15, 0xF2, 0x18, 0xF3, 0x3A}},
+		{"Memory", ": main bcd v1 save v2 load v3 save v1 - v4 load v4 - v1 saveflags v5 loadflags v6", []byte{0xF1, 0x33, 0xF2, 0x55, 0xF3, 0x65, 0x51, 0x42, 0x54, 0x13, 0xF5, 0x75, 0xF6, 0x85}},
+		{"Index", ": main i := 0x123 i := hex v1 i := bighex v2 i += v3 i := long 0x1234", []byte{0xA1, 0x23, 0xF1, 0x29, 0xF2, 0x30, 0xF3, 0x1E, 0xF0, 0x00, 0x12, 0x34}},
+		{"Sprite", ": main sprite v1 v2 15 plane 3", []byte{0xD1, 0x2F, 0xF3, 0x01}},
+		{"Jumps", ": main jump main jump0 0x300 native 0x123 :call main", []byte{0x12, 0x00, 0xB3, 0x00, 0x01, 0x23, 0x22, 0x00}},
+		{"JumpToMain", ": sub ; : main sub", []byte{0x12, 0x04, 0x00, 0xEE, 0x22, 0x02}},
+		{"Forward", ": main sub ; : sub ;", []byte{0x22, 0x04, 0x00, 0xEE, 0x00, 0xEE}},
+		{"Bytes", ": main 1 -1 0xFF 0b101", []byte{0x01, 0xFF, 0xFF, 0x05}},
+		{"Comments", ": main # clear\nreturn # the end", []byte{0x00, 0xEE}},
+		{"Const", ":const N 5 :alias x v7 : main x := N N", []byte{0x67, 0x05, 0x05}},
+		{"Calc", ":calc N { 2 * 3 + 1 } :calc M { ( 2 * 3 ) + 1 } : main N M", []byte{0x08, 0x07}},
+		{"Org", ": main :org 0x204 :byte 7 :byte { 1 + 1 }", []byte{0x00, 0x00, 0x00, 0x00, 0x07, 0x02}},
+		{"Next", ": main :next value v1 := 5", []byte{0x61, 0x05}},
+		{"Unpack", ": main :unpack 0xA data :unpack long data : data", []byte{0x60, 0xA2, 0x61, 0x08, 0x60, 0x02, 0x61, 0x08}},
+		{"Macro", ":macro twice op { op op } : main twice clear", []byte{0x00, 0xE0, 0x00, 0xE0}},
+		{"IfThen", ": main if v1 == 2 then clear if v1 != v2 then clear if v1 key then clear if v1 -key then clear", []byte{0x41, 0x02, 0x00, 0xE0, 0x51, 0x20, 0x00, 0xE0, 0xE1, 0xA1, 0x00, 0xE0, 0xE1, 0x9E, 0x00, 0xE0}},
+		{"Compare", ": main if v1 < 2 then clear if v1 >= v2 then clear if v1 > 2 then clear if v1 <= 2 then clear", []byte{
+			0x6F, 0x02, 0x8F, 0x17, 0x4F, 0x00, 0x00, 0xE0,
+			0x8F, 0x20, 0x8F, 0x17, 0x3F, 0x00, 0x00, 0xE0,
+			0x6F, 0x02, 0x8F, 0x15, 0x4F, 0x00, 0x00, 0xE0,
+			0x6F, 0x02, 0x8F, 0x15, 0x3F, 0x00, 0x00, 0xE0,
+		}},
+		{"Begin", ": main if v1 == 2 begin clear end", []byte{0x31, 0x02, 0x12, 0x06, 0x00, 0xE0}},
+		{"Else", ": main if v1 == 2 begin clear else return end", []byte{0x31, 0x02, 0x12, 0x08, 0x00, 0xE0, 0x12, 0x0A, 0x00, 0xEE}},
+		{"Loop", ": main loop clear while v1 != 0 again", []byte{0x00, 0xE0, 0x41, 0x00, 0x12, 0x08, 0x12, 0x00}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rom, err := chip8.CompileOcto(test.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(rom, test.rom) {
+				t.Fatalf("expected % X, but got % X", test.rom, rom)
+			}
+		})
+	}
+}
+
+func TestCompileOctoErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		line   string
+	}{
+		{"NoMain", "clear", ""},
+		{"Undefined", ": main\nsub", "line 2"},
+		{"Duplicate", ": main\n: main", "line 2"},
+		{"Word", ": main 0x1202", "line 1"},
+		{"Register", ": main v1 := vz", "line 1"},
+		{"Operator", ": main\nv1 ** 2", "line 2"},
+		{"Nibble", ": main sprite v1 v2 16", "line 1"},
+		{"Address", ": main jump 0x1000", "line 1"},
+		{"Loop", ": main loop clear", ""},
+		{"Again", ": main again", "line 1"},
+		{"Begin", ": main if v1 == 1 begin", ""},
+		{"End", ": main end", "line 1"},
+		{"Then", ": main if v1 == 1 clear", "line 1"},
+		{"Truncated", ": main v1 :=", "line 1"},
+		{"StringMode", ":stringmode x \"a\" { }\n: main", "line 1"},
+		{"Assert", ":assert { 1 == 2 }\n: main", "line 1"},
+		{"Unterminated", ": main :macro twice op { op op", "line 1"},
+		{"Recursive", ":macro forever { forever } : main forever", "line 1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := chip8.CompileOcto(test.source)
+			if !errors.Is(err, chip8.ErrOctoSource) {
+				t.Fatalf("expected ErrOctoSource, but got %v", err)
+			}
+
+			if !strings.Contains(err.Error(), test.line) {
+				t.Fatalf("expected the error on %s, but got %v", test.line, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// ErrPatchFormat is returned when applying a patch that is not a valid
+// IPS or BPS file.
+var ErrPatchFormat = errors.New("invalid patch file")
+
+// ErrPatchChecksum is returned when the checksums of a BPS patch do not
+// match the patch, the ROM being patched or the patched ROM.
+var ErrPatchChecksum = errors.New("patch checksum mismatch")
+
+// headers of the patch formats
+var (
+	ipsHeader = []byte("PATCH")
+	ipsEOF    = []byte("EOF")
+	bpsHeader = []byte("BPS1")
+)
+
+// ApplyPatch applies an IPS or BPS patch to the ROM, choosing the format
+// by the header of the patch. The ROM is not changed; the patched ROM is
+// returned.
+func ApplyPatch(rom, patch []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(patch, ipsHeader):
+		return ApplyIPS(rom, patch)
+	case bytes.HasPrefix(patch, bpsHeader):
+		return ApplyBPS(rom, patch)
+	default:
+		return nil, ErrPatchFormat
+	}
+}
+
+// ApplyIPS applies an IPS patch to the ROM, including the RLE records
+// and the truncation extension. IPS files have no checksums, so a patch
+// made for another ROM is not detected.
+func ApplyIPS(rom, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, ipsHeader) {
+		return nil, ErrPatchFormat
+	}
+
+	out := append([]byte{}, rom...)
+	p := patch[len(ipsHeader):]
+
+	for {
+		if len(p) < 3 {
+			return nil, ErrPatchFormat
+		}
+
+		if bytes.Equal(p[:3], ipsEOF) {
+			p = p[3:]
+			break
+		}
+
+		if len(p) < 5 {
+			return nil, ErrPatchFormat
+		}
+
+		offset := int(p[0])<<16 | int(p[1])<<8 | int(p[2])
+		size := int(binary.BigEndian.Uint16(p[3:]))
+		p = p[5:]
+
+		var data []byte
+		if size > 0 {
+			if len(p) < size {
+				return nil, ErrPatchFormat
+			}
+
+			data, p = p[:size], p[size:]
+		} else {
+			// RLE record: the size and the byte to repeat
+			if len(p) < 3 {
+				return nil, ErrPatchFormat
+			}
+
+			data = bytes.Repeat(p[2:3], int(binary.BigEndian.Uint16(p)))
+			p = p[3:]
+		}
+
+		if end := offset + len(data); end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+
+		copy(out[offset:], data)
+	}
+
+	// the optional size of the patched ROM, after the end of the patch
+	if len(p) == 3 {
+		size := int(p[0])<<16 | int(p[1])<<8 | int(p[2])
+		if size < len(out) {
+			out = out[:size]
+		}
+	}
+
+	return out, nil
+}
+
+// bpsReader reads the values of a BPS patch.
+type bpsReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// number reads a variable length number.
+func (r *bpsReader) number() int {
+	n, shift := 0, 1
+
+	for {
+		if r.pos >= len(r.data) || shift > 1<<42 {
+			r.err = ErrPatchFormat
+			return 0
+		}
+
+		b := r.data[r.pos]
+		r.pos++
+
+		n += int(b&0x7F) * shift
+		if b&0x80 != 0 {
+			return n
+		}
+
+		shift <<= 7
+		n += shift
+	}
+}
+
+// signed reads a variable length number with a sign bit.
+func (r *bpsReader) signed() int {
+	n := r.number()
+	if n&1 != 0 {
+		return -(n >> 1)
+	}
+
+	return n >> 1
+}
+
+// ApplyBPS applies a BPS patch to the ROM, validating the checksums of
+// the patch, of the ROM and of the patched ROM.
+func ApplyBPS(rom, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, bpsHeader) || len(patch) < len(bpsHeader)+12 {
+		return nil, ErrPatchFormat
+	}
+
+	footer := patch[len(patch)-12:]
+	if crc32.ChecksumIEEE(patch[:len(patch)-4]) != binary.LittleEndian.Uint32(footer[8:]) {
+		return nil, ErrPatchChecksum
+	}
+
+	if crc32.ChecksumIEEE(rom) != binary.LittleEndian.Uint32(footer) {
+		return nil, ErrPatchChecksum
+	}
+
+	r := &bpsReader{data: patch[:len(patch)-12], pos: len(bpsHeader)}
+	sourceSize := r.number()
+	targetSize := r.number()
+	r.pos += r.number() // metadata
+
+	if r.err != nil || sourceSize != len(rom) || targetSize > MEGACHIP.MemorySize || r.pos > len(r.data) {
+		return nil, ErrPatchFormat
+	}
+
+	out := make([]byte, 0, targetSize)
+	sourceOffset, targetOffset := 0, 0
+
+	for r.pos < len(r.data) && r.err == nil {
+		action := r.number()
+		length := action>>2 + 1
+
+		if len(out)+length > targetSize {
+			return nil, ErrPatchFormat
+		}
+
+		switch action & 0x03 {
+		case 0: // source read
+			if len(out)+length > len(rom) {
+				return nil, ErrPatchFormat
+			}
+
+			out = append(out, rom[len(out):len(out)+length]...)
+		case 1: // target read
+			if r.pos+length > len(r.data) {
+				return nil, ErrPatchFormat
+			}
+
+			out = append(out, r.data[r.pos:r.pos+length]...)
+			r.pos += length
+		case 2: // source copy
+			sourceOffset += r.signed()
+			if sourceOffset < 0 || sourceOffset+length > len(rom) {
+				return nil, ErrPatchFormat
+			}
+
+			out = append(out, rom[sourceOffset:sourceOffset+length]...)
+			sourceOffset += length
+		case 3: // target copy, which may repeat the bytes being written
+			targetOffset += r.signed()
+			if targetOffset < 0 || targetOffset >= len(out) {
+				return nil, ErrPatchFormat
+			}
+
+			for i := 0; i < length; i++ {
+				out = append(out, out[targetOffset])
+				targetOffset++
+			}
+		}
+	}
+
+	if r.err != nil || len(out) != targetSize {
+		return nil, ErrPatchFormat
+	}
+
+	if crc32.ChecksumIEEE(out) != binary.LittleEndian.Uint32(footer[4:]) {
+		return nil, ErrPatchChecksum
+	}
+
+	return out, nil
+}
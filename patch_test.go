@@ -0,0 +1,207 @@
+package chip8_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+var patchROM = []byte("ABCDEFGH")
+
+// ipsRecord returns an IPS record writing the data at the offset.
+func ipsRecord(offset int, data ...byte) []byte {
+	return append([]byte{byte(offset >> 16), byte(offset >> 8), byte(offset), byte(len(data) >> 8), byte(len(data))}, data...)
+}
+
+// ipsRLE returns an IPS record repeating the value at the offset.
+func ipsRLE(offset, count int, value byte) []byte {
+	return []byte{byte(offset >> 16), byte(offset >> 8), byte(offset), 0, 0, byte(count >> 8), byte(count), value}
+}
+
+func ips(records ...[]byte) []byte {
+	patch := []byte("PATCH")
+	for _, r := range records {
+		patch = append(patch, r...)
+	}
+
+	return append(patch, "EOF"...)
+}
+
+func TestApplyIPS(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch []byte
+		want  string
+	}{
+		{"empty", ips(), "ABCDEFGH"},
+		{"record", ips(ipsRecord(2, 'x', 'y')), "ABxyEFGH"},
+		{"records", ips(ipsRecord(0, 'x'), ipsRecord(7, 'y')), "xBCDEFGy"},
+		{"rle", ips(ipsRLE(1, 3, 'z')), "AzzzEFGH"},
+		{"grow", ips(ipsRecord(10, 'x')), "ABCDEFGH\x00\x00x"},
+		{"truncate", append(ips(ipsRecord(0, 'x')), 0, 0, 4), "xBCD"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rom := append([]byte{}, patchROM...)
+
+			got, err := chip8.ApplyPatch(rom, test.patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+
+			if !bytes.Equal(rom, patchROM) {
+				t.Errorf("the original ROM was changed to %q", rom)
+			}
+		})
+	}
+}
+
+func TestApplyIPSErrors(t *testing.T) {
+	valid := ips(ipsRecord(2, 'x', 'y'))
+
+	tests := []struct {
+		name  string
+		patch []byte
+	}{
+		{"header", append([]byte("PATCJ"), valid[5:]...)},
+		{"no eof", valid[:len(valid)-3]},
+		{"short record", valid[:len(valid)-4]},
+		{"short rle", append([]byte("PATCH"), ipsRLE(0, 2, 'z')[:6]...)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := chip8.ApplyIPS(patchROM, test.patch); !errors.Is(err, chip8.ErrPatchFormat) {
+				t.Errorf("got %v, want %v", err, chip8.ErrPatchFormat)
+			}
+		})
+	}
+}
+
+// bpsNumber encodes a variable length number of a BPS patch.
+func bpsNumber(n int) []byte {
+	var out []byte
+
+	for {
+		x := byte(n & 0x7F)
+		n >>= 7
+
+		if n == 0 {
+			return append(out, x|0x80)
+		}
+
+		out = append(out, x)
+		n--
+	}
+}
+
+// bpsAction encodes an action of a BPS patch.
+func bpsAction(kind, length int, data ...byte) []byte {
+	return append(bpsNumber((length-1)<<2|kind), data...)
+}
+
+// bpsOffset encodes a relative offset of a BPS patch.
+func bpsOffset(n int) []byte {
+	if n < 0 {
+		return bpsNumber(-n<<1 | 1)
+	}
+
+	return bpsNumber(n << 1)
+}
+
+// bps returns a BPS patch from source to target, with the actions.
+func bps(source, target []byte, actions ...[]byte) []byte {
+	patch := []byte("BPS1")
+	patch = append(patch, bpsNumber(len(source))...)
+	patch = append(patch, bpsNumber(len(target))...)
+	patch = append(patch, bpsNumber(4)...)
+	patch = append(patch, "meta"...)
+
+	for _, a := range actions {
+		patch = append(patch, a...)
+	}
+
+	patch = appendCRC(patch, source)
+	patch = appendCRC(patch, target)
+	return appendCRC(patch, patch)
+}
+
+// appendCRC appends the CRC32 of the data to the patch.
+func appendCRC(patch, data []byte) []byte {
+	var sum [4]byte
+	binary.LittleEndian.PutUint32(sum[:], crc32.ChecksumIEEE(data))
+	return append(patch, sum[:]...)
+}
+
+// bpsTarget is the ROM made by bpsActions from patchROM.
+var bpsTarget = []byte("ABxyGHHHHCD")
+
+var bpsActions = [][]byte{
+	bpsAction(0, 2),                           // source read: AB
+	bpsAction(1, 2, 'x', 'y'),                 // target read: xy
+	append(bpsAction(2, 2), bpsOffset(6)...),  // source copy: GH
+	append(bpsAction(3, 3), bpsOffset(5)...),  // target copy: HHH
+	append(bpsAction(2, 2), bpsOffset(-6)...), // source copy: CD
+}
+
+func TestApplyBPS(t *testing.T) {
+	patch := bps(patchROM, bpsTarget, bpsActions...)
+
+	got, err := chip8.ApplyPatch(patchROM, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, bpsTarget) {
+		t.Errorf("got %q, want %q", got, bpsTarget)
+	}
+}
+
+func TestApplyBPSErrors(t *testing.T) {
+	valid := bps(patchROM, bpsTarget, bpsActions...)
+
+	corrupt := append([]byte{}, valid...)
+	corrupt[10] ^= 0xFF
+
+	wrongTarget := bps(patchROM, []byte("ABxyGHHHHCE"), bpsActions...)
+	longTarget := bps(patchROM, append(bpsTarget, 'Z'), bpsActions...)
+	badCopy := bps(patchROM, bpsTarget, append(bpsAction(2, 2), bpsOffset(7)...))
+
+	tests := []struct {
+		name  string
+		rom   []byte
+		patch []byte
+		err   error
+	}{
+		{"header", patchROM, append([]byte("BPS2"), valid[4:]...), chip8.ErrPatchFormat},
+		{"short", patchROM, valid[:10], chip8.ErrPatchFormat},
+		{"corrupt patch", patchROM, corrupt, chip8.ErrPatchChecksum},
+		{"wrong source", []byte("ABCDEFGX"), valid, chip8.ErrPatchChecksum},
+		{"wrong target", patchROM, wrongTarget, chip8.ErrPatchChecksum},
+		{"missing actions", patchROM, longTarget, chip8.ErrPatchFormat},
+		{"copy past source", patchROM, badCopy, chip8.ErrPatchFormat},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := chip8.ApplyBPS(test.rom, test.patch); !errors.Is(err, test.err) {
+				t.Errorf("got %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestApplyPatchUnknown(t *testing.T) {
+	if _, err := chip8.ApplyPatch(patchROM, []byte("UPS1")); !errors.Is(err, chip8.ErrPatchFormat) {
+		t.Errorf("got %v, want %v", err, chip8.ErrPatchFormat)
+	}
+}
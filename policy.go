@@ -0,0 +1,83 @@
+package chip8
+
+import (
+	"errors"
+	"log"
+)
+
+// ErrorAction is what the emulator does when an instruction fails.
+type ErrorAction int
+
+// Actions of an ErrorPolicy.
+const (
+	ActionHalt ErrorAction = iota // stop, returning the error from Execute
+	ActionSkip                    // ignore the error and keep running
+	ActionLog                     // log the error and keep running
+	ActionTrap                    // call the Trap function of the policy
+)
+
+// ErrorPolicy sets how the emulator handles the failures of the
+// instructions, for each kind of error. The zero value halts on all of
+// them.
+//
+// When an error is ignored, the instruction still counts as executed,
+// and the program continues on the next instruction. Errors not covered
+// by the policy (like ErrStopped) always halt.
+type ErrorPolicy struct {
+	UnknownOpcode  ErrorAction // NoOpError
+	InvalidAddress ErrorAction // ErrInvalidAddress
+	ReservedWrite  ErrorAction // ErrMemWrite
+	StackFault     ErrorAction // ErrStackOverflow and ErrStackUnderflow
+
+	// Logger receives the errors of ActionLog. Nil means the standard
+	// logger.
+	Logger *log.Logger
+
+	// Trap is called on the errors of ActionTrap. If it returns nil the
+	// program keeps running; otherwise, Execute returns the error. A nil
+	// Trap halts.
+	Trap func(err *ExecError) error
+}
+
+// action returns the action for the error.
+func (p *ErrorPolicy) action(err error) ErrorAction {
+	var noop NoOpError
+
+	switch {
+	case errors.As(err, &noop):
+		return p.UnknownOpcode
+	case errors.Is(err, ErrInvalidAddress):
+		return p.InvalidAddress
+	case errors.Is(err, ErrMemWrite):
+		return p.ReservedWrite
+	case errors.Is(err, ErrStackOverflow), errors.Is(err, ErrStackUnderflow):
+		return p.StackFault
+	default:
+		return ActionHalt
+	}
+}
+
+// apply applies the policy to the error, returning nil if the program
+// should keep running.
+func (p *ErrorPolicy) apply(err *ExecError) error {
+	switch p.action(err.Err) {
+	case ActionSkip:
+		return nil
+	case ActionLog:
+		if p.Logger != nil {
+			p.Logger.Print(err)
+		} else {
+			log.Print(err)
+		}
+
+		return nil
+	case ActionTrap:
+		if p.Trap == nil {
+			return err
+		}
+
+		return p.Trap(err)
+	default:
+		return err
+	}
+}
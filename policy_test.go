@@ -0,0 +1,113 @@
+package chip8_test
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+// policyROM has an unknown instruction followed by V2 = 7.
+var policyROM = []byte{0x61, 0x05, 0x81, 0x2F, 0x62, 0x07}
+
+func TestErrorPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		action   chip8.ErrorAction
+		trap     func(err *chip8.ExecError) error
+		executed int
+		halts    bool
+		logged   bool
+	}{
+		{"Halt", chip8.ActionHalt, nil, 1, true, false},
+		{"Skip", chip8.ActionSkip, nil, 3, false, false},
+		{"Log", chip8.ActionLog, nil, 3, false, true},
+		{"TrapContinue", chip8.ActionTrap, func(err *chip8.ExecError) error { return nil }, 3, false, false},
+		{"TrapHalt", chip8.ActionTrap, func(err *chip8.ExecError) error { return err }, 1, true, false},
+		{"TrapNil", chip8.ActionTrap, nil, 1, true, false},
+	}
+
+	for _, test := range tests {
+		for _, engine := range engines {
+			t.Run(test.name+"/"+engine.name, func(t *testing.T) {
+				var logs bytes.Buffer
+
+				c := chip8.NewEmulator(chip8.COSMACVIP)
+				c.Engine = engine.engine
+				c.Policy.UnknownOpcode = test.action
+				c.Policy.Trap = test.trap
+				c.Policy.Logger = log.New(&logs, "", 0)
+				if err := c.LoadROM(bytes.NewReader(policyROM)); err != nil {
+					t.Fatal(err)
+				}
+
+				executed, err := c.Execute(3)
+				if executed != test.executed || (err != nil) != test.halts {
+					t.Fatalf("expected %d instructions (halt: %v), but got %d (%v)", test.executed, test.halts, executed, err)
+				}
+
+				var noop chip8.NoOpError
+				if test.halts && !errors.As(err, &noop) {
+					t.Fatalf("expected NoOpError, but got %v", err)
+				}
+
+				if !test.halts && c.V[2] != 0x07 {
+					t.Fatal("expected the program to continue after the unknown instruction")
+				}
+
+				if logged := strings.Contains(logs.String(), "0x812F"); logged != test.logged {
+					t.Fatalf("expected logged: %v, but got %q", test.logged, logs.String())
+				}
+			})
+		}
+	}
+}
+
+func TestErrorPolicyKinds(t *testing.T) {
+	// the invalid address is skipped, but not the unknown instruction
+	c := chip8.NewEmulator(chip8.COSMACVIP)
+	c.Policy.InvalidAddress = chip8.ActionSkip
+	if err := c.LoadROM(bytes.NewReader([]byte{0xD0, 0x0F, 0x81, 0x2F})); err != nil {
+		t.Fatal(err)
+	}
+
+	c.I = 0xFFF0
+
+	var trapped *chip8.ExecError
+	c.Policy.UnknownOpcode = chip8.ActionTrap
+	c.Policy.Trap = func(err *chip8.ExecError) error {
+		trapped = err
+		return err
+	}
+
+	executed, err := c.Execute(10)
+	if executed != 1 || trapped == nil || trapped.PC != chip8.AddrStart+2 {
+		t.Fatalf("expected to trap the second instruction, but got %d instructions (%v)", executed, err)
+	}
+}
+
+func TestErrorPolicyFetch(t *testing.T) {
+	for _, engine := range engines {
+		t.Run(engine.name, func(t *testing.T) {
+			// the instruction at the last address crosses the end of the memory
+			c := chip8.NewEmulator(chip8.COSMACVIP)
+			c.Engine = engine.engine
+			c.Reset()
+			c.PC = 0xFFF
+
+			var execErr *chip8.ExecError
+			if _, err := c.Execute(1); !errors.As(err, &execErr) || execErr.PC != 0xFFF || !errors.Is(err, chip8.ErrInvalidAddress) {
+				t.Fatalf("expected ErrInvalidAddress at 0xFFF, but got %v", err)
+			}
+
+			c.PC = 0xFFF
+			c.Policy.InvalidAddress = chip8.ActionSkip
+			if executed, err := c.Execute(1); err != nil || executed != 1 || c.PC != 0x1001 {
+				t.Fatalf("expected the fetch to be skipped, but got %d instructions at 0x%04X (%v)", executed, c.PC, err)
+			}
+		})
+	}
+}
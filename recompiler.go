@@ -0,0 +1,198 @@
+package chip8
+
+// maximum number of instructions on a basic block
+const maxBlockSize = 64
+
+// block is a basic block of the program, translated to Go functions: a
+// sequence of instructions that always run one after the other, where only
+// the last one may change the flow of the program (see Instruction.Branch).
+type block struct {
+	start int // address of the first instruction
+	end   int // address after the last instruction
+	steps []blockStep
+}
+
+// blockStep is one instruction of a block, with its opcode already bound
+// to the handler.
+type blockStep struct {
+	ins  *Instruction
+	a, b byte
+	run  func(c *Emulator) error
+}
+
+// blockPage holds the compiled blocks starting on cachePageSize
+// addresses.
+type blockPage [cachePageSize]*block
+
+// blockCache holds the compiled blocks of a decoder.
+type blockCache struct {
+	pages      []*blockPage // compiled blocks, by start address
+	code       map[int]bool // pages with compiled code
+	interpret  map[int]bool // pages written by the program
+	generation int          // incremented every time blocks are discarded
+}
+
+// executeBlocks is the Execute of the EngineRecompiler. Pages of memory
+// written by the program are never compiled again; instead, they are
+// executed one instruction at a time, like the other engines.
+func (c *Emulator) executeBlocks(d *decoder, cycles int) (int, error) {
+	executed := 0
+
+	for executed < cycles {
+		blk := d.blockAt(c, int(c.PC))
+		if blk == nil {
+			if err := c.step(d, executed); err != nil {
+				return executed, err
+			}
+
+			executed++
+			continue
+		}
+
+		generation := d.blocks.generation
+
+		for i := 0; i < len(blk.steps) && executed < cycles; i++ {
+			s := &blk.steps[i]
+			c.PC += 2
+
+			if c.Trace != nil {
+				c.Trace(c.PC-2, s.ins, s.a, s.b)
+			}
+
+			if err := s.run(c); err != nil {
+				if err = c.fail(err, c.PC-2, s.a, s.b, executed); err != nil {
+					return executed, err
+				}
+			}
+
+			executed++
+
+			// the block itself may have been overwritten
+			if d.blocks.generation != generation {
+				break
+			}
+		}
+	}
+
+	return executed, nil
+}
+
+// blockAt returns the block starting at addr, compiling it if needed. It
+// returns nil if the address is on a page written by the program or
+// mapped to a device, or if the instruction at addr is unknown.
+func (d *decoder) blockAt(c *Emulator, addr int) *block {
+	bc := &d.blocks
+	if addr/cachePageSize >= len(bc.pages) {
+		bc.pages = append(bc.pages, make([]*blockPage, len(c.Memory)/cachePageSize+1-len(bc.pages))...)
+	}
+
+	page := bc.pages[addr/cachePageSize]
+	if page != nil && page[addr%cachePageSize] != nil {
+		return page[addr%cachePageSize]
+	}
+
+	if bc.interpret[addr/cachePageSize] {
+		return nil
+	}
+
+	blk := d.compile(c, addr)
+	if blk == nil {
+		return nil
+	}
+
+	if page == nil {
+		page = &blockPage{}
+		bc.pages[addr/cachePageSize] = page
+	}
+
+	page[addr%cachePageSize] = blk
+
+	if bc.code == nil {
+		bc.code = make(map[int]bool)
+	}
+
+	for p := blk.start / cachePageSize; p <= (blk.end-1)/cachePageSize; p++ {
+		bc.code[p] = true
+	}
+
+	return blk
+}
+
+// compile translates the block starting at addr.
+func (d *decoder) compile(c *Emulator, addr int) *block {
+	blk := &block{start: addr, end: addr}
+
+	for len(blk.steps) < maxBlockSize && blk.end+1 < len(c.Memory) {
+		if d.blocks.interpret[blk.end/cachePageSize] || d.blocks.interpret[(blk.end+1)/cachePageSize] {
+			break
+		}
+
+		if c.devices != nil && c.mapped(blk.end, 2) {
+			break
+		}
+
+		a, b := c.Memory[blk.end], c.Memory[blk.end+1]
+		ins := d.lookup(uint16(a)<<8 | uint16(b))
+		if ins == nil {
+			break
+		}
+
+		exec := ins.Exec
+		blk.steps = append(blk.steps, blockStep{
+			ins: ins,
+			a:   a,
+			b:   b,
+			run: func(c *Emulator) error { return exec(c, a, b) },
+		})
+		blk.end += 2
+
+		if ins.Branch {
+			break
+		}
+	}
+
+	if len(blk.steps) == 0 {
+		return nil
+	}
+
+	return blk
+}
+
+// invalidateBlocks discards all compiled blocks if the given memory range
+// has compiled code, switching its pages to the interpreter.
+func (c *Emulator) invalidateBlocks(addr, size int) {
+	bc := &c.decoder.blocks
+	if len(bc.code) == 0 || size <= 0 {
+		return
+	}
+
+	// instructions starting on the byte before addr include its first byte
+	first, last := (addr-1)/cachePageSize, (addr+size-1)/cachePageSize
+	if addr == 0 {
+		first = 0
+	}
+
+	written := false
+	for page := first; page <= last; page++ {
+		if bc.code[page] {
+			written = true
+			break
+		}
+	}
+
+	if !written {
+		return
+	}
+
+	if bc.interpret == nil {
+		bc.interpret = make(map[int]bool)
+	}
+
+	for page := first; page <= last; page++ {
+		bc.interpret[page] = true
+	}
+
+	bc.pages = nil
+	bc.code = nil
+	bc.generation++
+}
@@ -0,0 +1,151 @@
+package chip8_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+// compareEmulators returns an error describing the first difference
+// between the state of the emulators, if any.
+func compareEmulators(want, got *chip8.Emulator) error {
+	switch {
+	case want.PC != got.PC:
+		return fmt.Errorf("expected PC 0x%03X, but found 0x%03X", want.PC, got.PC)
+	case want.I != got.I:
+		return fmt.Errorf("expected I 0x%03X, but found 0x%03X", want.I, got.I)
+	case want.V != got.V:
+		return fmt.Errorf("expected registers %v, but found %v", want.V, got.V)
+	case want.DT != got.DT || want.ST != got.ST:
+		return fmt.Errorf("expected timers %d/%d, but found %d/%d", want.DT, want.ST, got.DT, got.ST)
+	case !bytes.Equal(want.Memory, got.Memory):
+		return fmt.Errorf("memory differs")
+	}
+
+	return nil
+}
+
+func TestRecompilerDifferential(t *testing.T) {
+	tests := []struct {
+		name    string
+		machine *chip8.Machine
+		rom     []byte
+		i       uint16
+		total   int
+	}{
+		{
+			name:    "straight line",
+			machine: chip8.COSMACVIP,
+			rom:     benchROM(),
+			total:   len(benchROM()) / 2,
+		},
+		{
+			name:    "loop",
+			machine: chip8.COSMACVIP.WithExtensions(chip8.CHIP8E),
+			rom: []byte{
+				0x61, 0x05, // V1 = 5
+				0x62, 0x07, // V2 = 7
+				0x84, 0x20, // V4 = V2
+				0xF4, 0x15, // DT = V4
+				0x51, 0x21, // SGT V1, V2
+				0xBB, 0x06, // JB 6 (loop to V4 = V2)
+			},
+			total: 500,
+		},
+		{
+			name:    "self-modifying loop",
+			machine: chip8.COSMACVIP.WithExtensions(chip8.CHIP8E),
+			rom: []byte{
+				0x61, 0x63, // V1 = 0x63
+				0x62, 0x2A, // V2 = 0x2A
+				0x51, 0x22, // LD [I], V1-V2 (overwrites the next instructions)
+				0x63, 0x11, // V3 = 0x11
+				0xBB, 0x08, // JB 8 (loop to the start)
+			},
+			i:     chip8.AddrStart + 6,
+			total: 1000,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want := chip8.NewEmulator(test.machine)
+			got := chip8.NewEmulator(test.machine)
+			got.Engine = chip8.EngineRecompiler
+
+			for _, c := range []*chip8.Emulator{want, got} {
+				if err := c.LoadROM(bytes.NewReader(test.rom)); err != nil {
+					t.Fatal(err)
+				}
+
+				c.I = test.i
+			}
+
+			// run in batches of different sizes, so the batches end
+			// in the middle of the blocks
+			for total, batch := 0, 1; total < test.total; batch = batch%7 + 1 {
+				wantExecuted, wantErr := want.Execute(batch)
+				gotExecuted, gotErr := got.Execute(batch)
+
+				if wantExecuted != gotExecuted || fmt.Sprint(wantErr) != fmt.Sprint(gotErr) {
+					t.Fatalf("expected (%d, %v), but found (%d, %v)", wantExecuted, wantErr, gotExecuted, gotErr)
+				}
+
+				if err := compareEmulators(want, got); err != nil {
+					t.Fatalf("after %d instructions: %v", total+wantExecuted, err)
+				}
+
+				total += batch
+				want.Tick()
+				got.Tick()
+			}
+		})
+	}
+}
+
+func TestRecompilerUnknownInstruction(t *testing.T) {
+	c := chip8.NewEmulator(chip8.COSMACVIP)
+	c.Engine = chip8.EngineRecompiler
+	if err := c.LoadROM(bytes.NewReader([]byte{0x61, 0x01, 0x81, 0x2F})); err != nil {
+		t.Fatal(err)
+	}
+
+	executed, err := c.Execute(10)
+	var noop chip8.NoOpError
+	if !errors.As(err, &noop) || executed != 1 {
+		t.Fatalf("expected NoOpError after 1 instruction, but found %v after %d", err, executed)
+	}
+
+	if c.V[1] != 0x01 || int(c.PC) != chip8.AddrStart+4 {
+		t.Fatalf("unexpected state: V1=0x%02X, PC=0x%03X", c.V[1], c.PC)
+	}
+}
+
+func TestRecompilerTrace(t *testing.T) {
+	c := chip8.NewEmulator(chip8.COSMACVIP)
+	c.Engine = chip8.EngineRecompiler
+	if err := c.LoadROM(bytes.NewReader(benchROM())); err != nil {
+		t.Fatal(err)
+	}
+
+	traced := 0
+	c.Trace = func(pc uint16, ins *chip8.Instruction, a byte, b byte) {
+		if int(pc) != chip8.AddrStart+traced*2 {
+			t.Fatalf("unexpected address 0x%03X for instruction %d", pc, traced)
+		}
+
+		traced++
+	}
+
+	executed, err := c.Execute(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if traced != executed {
+		t.Fatalf("expected %d traced instructions, but found %d", executed, traced)
+	}
+}
@@ -0,0 +1,350 @@
+package chip8
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// ErrROMFormat is returned when the format of a ROM file is invalid.
+var ErrROMFormat = errors.New("invalid ROM file")
+
+// ErrNoROM is returned when an archive has no ROM, or more than one
+// without a ROM extension to choose from.
+var ErrNoROM = errors.New("no ROM found in archive")
+
+// ErrManyROMs is returned when an archive has more than one file with a
+// ROM extension. The error lists the names of the files.
+var ErrManyROMs = errors.New("more than one ROM found in archive")
+
+// ROMFormat is the format of a ROM file.
+type ROMFormat string
+
+// Formats of the ROM files.
+const (
+	FormatBinary    ROMFormat = "binary"    // the raw bytes of the program
+	FormatZIP       ROMFormat = "zip"       // ZIP archive
+	FormatGzip      ROMFormat = "gzip"      // gzip compressed file
+	FormatIntelHex  ROMFormat = "intel-hex" // Intel HEX records
+	FormatHexText   ROMFormat = "hex"       // plain hexadecimal dump
+	FormatCartridge ROMFormat = "octo"      // Octo cartridge
+)
+
+// romExtensions are the machines of the extensions of the ROM files.
+var romExtensions = map[string]*Machine{
+	".ch8": COSMACVIP,
+	".c8":  COSMACVIP,
+	".c8h": HiResVIP,
+	".c8e": COSMACVIP.WithExtensions(CHIP8E),
+	".c8x": CHIP8X,
+	".sc8": SCHIP,
+	".xo8": XOCHIP,
+	".mc8": MEGACHIP,
+}
+
+// ROMFile is a ROM read from a file.
+type ROMFile struct {
+	Name    string      // name of the ROM file (of the entry, for archives)
+	Formats []ROMFormat // formats of the file, from the outermost one
+	Machine *Machine    // machine inferred from the file, or nil if unknown
+	ROM     []byte      // the program
+}
+
+// Format returns the format of the program itself (ex: the format of a
+// ROM inside of a ZIP archive), or FormatBinary if Formats is empty.
+func (f *ROMFile) Format() ROMFormat {
+	if len(f.Formats) == 0 {
+		return FormatBinary
+	}
+
+	return f.Formats[len(f.Formats)-1]
+}
+
+// ReadROMFile reads a ROM from a file, which may be a ZIP or gzip
+// archive, an Intel HEX or plain hexadecimal text file, an Octo
+// cartridge or the binary program itself. The machine is inferred from
+// the extension of the ROM (ex: ".sc8" for SUPER-CHIP programs) or from
+// the options of the cartridge.
+//
+// The program of the cartridges is compiled from its Octo source (see
+// CompileOcto); if it does not compile, the error is ErrOctoSource.
+func ReadROMFile(path string) (*ROMFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeROM(filepath.Base(path), data)
+}
+
+// DecodeROM decodes the contents of a ROM file with the given name (see
+// ReadROMFile).
+func DecodeROM(name string, data []byte) (*ROMFile, error) {
+	ext := strings.ToLower(filepath.Ext(name))
+
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return decodeZIP(data)
+	case bytes.HasPrefix(data, []byte{0x1F, 0x8B}):
+		return decodeGzip(name, data)
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		cart, err := ReadCartridge(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+
+		return &ROMFile{Name: name, Formats: []ROMFormat{FormatCartridge}, Machine: cart.Options.Machine(), ROM: cart.ROM}, nil
+	}
+
+	f := &ROMFile{Name: name, Formats: []ROMFormat{FormatBinary}, Machine: romExtensions[ext], ROM: data}
+
+	// binary programs can look like text, so the text formats are only
+	// read from text files without the extension of a binary ROM
+	if f.Machine == nil && isText(data) {
+		text := bytes.TrimSpace(data)
+
+		if bytes.HasPrefix(text, []byte(":")) {
+			rom, err := decodeIntelHex(text)
+			if err != nil {
+				return nil, err
+			}
+
+			f.Formats[0], f.ROM = FormatIntelHex, rom
+		} else if rom, err := decodeHexText(text); err == nil && len(rom) > 0 {
+			f.Formats[0], f.ROM = FormatHexText, rom
+		}
+	}
+
+	return f, nil
+}
+
+// decodeZIP decodes the ROM of a ZIP archive: the file with a ROM
+// extension, or the only file of the archive.
+func decodeZIP(data []byte) (*ROMFile, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrROMFormat, err)
+	}
+
+	var found, only *zip.File
+	var roms []string
+	files := 0
+
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		files++
+		only = file
+
+		if romExtensions[strings.ToLower(filepath.Ext(file.Name))] != nil {
+			found = file
+			roms = append(roms, file.Name)
+		}
+	}
+
+	if len(roms) > 1 {
+		return nil, fmt.Errorf("%w: %s", ErrManyROMs, strings.Join(roms, ", "))
+	}
+
+	if found == nil && files == 1 {
+		found = only
+	}
+
+	if found == nil {
+		return nil, ErrNoROM
+	}
+
+	in, err := found.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	entry, err := ioutil.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+
+	return nested(FormatZIP, filepath.Base(found.Name), entry)
+}
+
+// decodeGzip decodes the ROM of a gzip compressed file, named as in the
+// header of the file or as the file without the ".gz" extension.
+func decodeGzip(name string, data []byte) (*ROMFile, error) {
+	in, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrROMFormat, err)
+	}
+	defer in.Close()
+
+	entry, err := ioutil.ReadAll(in)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrROMFormat, err)
+	}
+
+	if in.Name != "" {
+		name = in.Name
+	} else {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	return nested(FormatGzip, name, entry)
+}
+
+// nested decodes a file inside of an archive of the given format.
+func nested(format ROMFormat, name string, data []byte) (*ROMFile, error) {
+	f, err := DecodeROM(name, data)
+	if err != nil {
+		return nil, err
+	}
+
+	f.Formats = append([]ROMFormat{format}, f.Formats...)
+	return f, nil
+}
+
+// decodeIntelHex decodes Intel HEX records. The ROM goes from the lowest
+// to the highest address of the data, with any gaps filled with zeros.
+func decodeIntelHex(text []byte) ([]byte, error) {
+	var records []hexRecord
+	base := 0
+	low, high := -1, 0
+
+	for n, line := range strings.Split(string(text), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		raw, err := hex.DecodeString(strings.TrimPrefix(line, ":"))
+		if !strings.HasPrefix(line, ":") || err != nil || len(raw) < 5 || len(raw) != int(raw[0])+5 {
+			return nil, fmt.Errorf("%w: bad Intel HEX record on line %d", ErrROMFormat, n+1)
+		}
+
+		var sum byte
+		for _, b := range raw {
+			sum += b
+		}
+
+		if sum != 0 {
+			return nil, fmt.Errorf("%w: bad Intel HEX checksum on line %d", ErrROMFormat, n+1)
+		}
+
+		data := raw[4 : len(raw)-1]
+		switch raw[3] {
+		case 0x00:
+			addr := base + (int(raw[1])<<8 | int(raw[2]))
+			records = append(records, hexRecord{addr, data})
+
+			if low < 0 || addr < low {
+				low = addr
+			}
+
+			if addr+len(data) > high {
+				high = addr + len(data)
+			}
+		case 0x01:
+			if high-low > MEGACHIP.MemorySize {
+				return nil, ErrLoadOverflow
+			}
+
+			return intelHexImage(records, low, high), nil
+		case 0x02:
+			if len(data) == 2 {
+				base = (int(data[0])<<8 | int(data[1])) << 4
+			}
+		case 0x04:
+			if len(data) == 2 {
+				base = (int(data[0])<<8 | int(data[1])) << 16
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("%w: missing Intel HEX end of file record", ErrROMFormat)
+}
+
+// isText reports whether the data is made of printable ASCII characters
+// and line breaks.
+func isText(data []byte) bool {
+	for _, b := range data {
+		if (b < 0x20 || b > 0x7E) && b != '\n' && b != '\r' && b != '\t' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hexRecord is a data record of an Intel HEX file.
+type hexRecord struct {
+	addr int
+	data []byte
+}
+
+// intelHexImage places the records on a ROM image.
+func intelHexImage(records []hexRecord, low, high int) []byte {
+	if low < 0 {
+		return []byte{}
+	}
+
+	rom := make([]byte, high-low)
+	for _, r := range records {
+		copy(rom[r.addr-low:], r.data)
+	}
+
+	return rom
+}
+
+// decodeHexText decodes a plain hexadecimal dump: bytes or words in
+// hexadecimal, optionally prefixed by 0x, separated by spaces, commas or
+// line breaks. Comments start with '#' or ';' and go to the end of the
+// line.
+func decodeHexText(text []byte) ([]byte, error) {
+	var rom []byte
+
+	for _, line := range strings.Split(string(text), "\n") {
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+
+		for _, token := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\r' }) {
+			token = strings.TrimPrefix(strings.TrimPrefix(token, "0x"), "0X")
+			if len(token)%2 != 0 {
+				return nil, ErrROMFormat
+			}
+
+			data, err := hex.DecodeString(token)
+			if err != nil {
+				return nil, ErrROMFormat
+			}
+
+			rom = append(rom, data...)
+		}
+	}
+
+	return rom, nil
+}
+
+// LoadROMFile reads a ROM from a file (see ReadROMFile) and loads it. If
+// the emulator has no machine set, it is changed to the machine inferred
+// from the file, if any.
+func (c *Emulator) LoadROMFile(path string) (*ROMFile, error) {
+	f, err := ReadROMFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Machine == nil && f.Machine != nil {
+		c.Machine = f.Machine
+		c.Reset()
+	}
+
+	return f, c.LoadROM(bytes.NewReader(f.ROM))
+}
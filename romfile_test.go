@@ -0,0 +1,189 @@
+package chip8_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+// fileROM clears the screen and loops forever.
+var fileROM = []byte{0x00, 0xE0, 0x12, 0x02}
+
+// ihex returns an Intel HEX record.
+func ihex(addr int, kind byte, data ...byte) string {
+	raw := append([]byte{byte(len(data)), byte(addr >> 8), byte(addr), kind}, data...)
+
+	var sum byte
+	for _, b := range raw {
+		sum += b
+	}
+
+	return fmt.Sprintf(":%X%02X\n", raw, -sum)
+}
+
+func zipFile(t *testing.T, files map[string][]byte) []byte {
+	var out bytes.Buffer
+	w := zip.NewWriter(&out)
+
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := f.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return out.Bytes()
+}
+
+func gzipFile(t *testing.T, name string, data []byte) []byte {
+	var out bytes.Buffer
+	w := gzip.NewWriter(&out)
+	w.Name = name
+
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return out.Bytes()
+}
+
+func TestDecodeROM(t *testing.T) {
+	var cart bytes.Buffer
+	if err := chip8.WriteCartridge(&cart, &chip8.Cartridge{Options: chip8.OctoOptions{MaxSize: 3584}, ROM: fileROM}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		data    []byte
+		formats []chip8.ROMFormat
+		machine string
+		rom     []byte
+	}{
+		{"Binary", "game.ch8", fileROM, []chip8.ROMFormat{chip8.FormatBinary}, "vip", fileROM},
+		{"BinarySCHIP", "game.SC8", fileROM, []chip8.ROMFormat{chip8.FormatBinary}, "schip", fileROM},
+		{"BinaryUnknown", "game.bin", fileROM, []chip8.ROMFormat{chip8.FormatBinary}, "", fileROM},
+		{"BinaryText", "game.ch8", []byte("00E0"), []chip8.ROMFormat{chip8.FormatBinary}, "vip", []byte("00E0")},
+		{"HexText", "game.txt", []byte("# game\n00E0 0x12, 0x02 ; loop\n"), []chip8.ROMFormat{chip8.FormatHexText}, "", fileROM},
+		{"IntelHex", "game.hex", []byte(ihex(0x200, 0, 0x00, 0xE0) + ihex(0x202, 0, 0x12, 0x02) + ihex(0, 1)), []chip8.ROMFormat{chip8.FormatIntelHex}, "", fileROM},
+		{"IntelHexGap", "game.hex", []byte(ihex(0x200, 0, 0x00, 0xE0) + ihex(0x204, 0, 0x12, 0x02) + ihex(0, 1)), []chip8.ROMFormat{chip8.FormatIntelHex}, "", []byte{0x00, 0xE0, 0x00, 0x00, 0x12, 0x02}},
+		{"Gzip", "game.xo8.gz", gzipFile(t, "", fileROM), []chip8.ROMFormat{chip8.FormatGzip, chip8.FormatBinary}, "xochip", fileROM},
+		{"GzipName", "download.gz", gzipFile(t, "game.c8x", fileROM), []chip8.ROMFormat{chip8.FormatGzip, chip8.FormatBinary}, "chip8x", fileROM},
+		{"ZIP", "games.zip", zipFile(t, map[string][]byte{"README.txt": []byte("hello"), "dir/game.sc8": fileROM}), []chip8.ROMFormat{chip8.FormatZIP, chip8.FormatBinary}, "schip", fileROM},
+		{"ZIPOnly", "games.zip", zipFile(t, map[string][]byte{"game.hex": []byte("00E01202")}), []chip8.ROMFormat{chip8.FormatZIP, chip8.FormatHexText}, "", fileROM},
+		{"Cartridge", "game.gif", cart.Bytes(), []chip8.ROMFormat{chip8.FormatCartridge}, "schip", fileROM},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := chip8.DecodeROM(test.file, test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(f.Formats, test.formats) || f.Format() != test.formats[len(test.formats)-1] {
+				t.Fatalf("expected formats %v, but got %v", test.formats, f.Formats)
+			}
+
+			if (f.Machine == nil && test.machine != "") || (f.Machine != nil && f.Machine.ID != test.machine) {
+				t.Fatalf("expected machine %q, but got %v", test.machine, f.Machine)
+			}
+
+			if !bytes.Equal(f.ROM, test.rom) {
+				t.Fatalf("expected ROM %X, but got %X", test.rom, f.ROM)
+			}
+		})
+	}
+}
+
+func TestDecodeROMErrors(t *testing.T) {
+	badChecksum := strings.Replace(ihex(0x200, 0, 0x00, 0xE0), "E0", "E1", 1) + ihex(0, 1)
+
+	tests := []struct {
+		name string
+		file string
+		data []byte
+		err  error
+	}{
+		{"IntelHexChecksum", "game.hex", []byte(badChecksum), chip8.ErrROMFormat},
+		{"IntelHexEOF", "game.hex", []byte(ihex(0x200, 0, 0x00, 0xE0)), chip8.ErrROMFormat},
+		{"ZIPEmpty", "games.zip", zipFile(t, map[string][]byte{"a.txt": nil, "b.txt": nil}), chip8.ErrNoROM},
+		{"ZIPMany", "games.zip", zipFile(t, map[string][]byte{"a.ch8": fileROM, "b.ch8": fileROM}), chip8.ErrManyROMs},
+		{"ZIPManyText", "games.zip", zipFile(t, map[string][]byte{"a.txt": fileROM, "b.txt": fileROM}), chip8.ErrNoROM},
+		{"Gzip", "game.gz", []byte{0x1F, 0x8B, 0x00}, chip8.ErrROMFormat},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := chip8.DecodeROM(test.file, test.data); !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, but got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestDecodeROMManyNames(t *testing.T) {
+	_, err := chip8.DecodeROM("games.zip", zipFile(t, map[string][]byte{"a.ch8": fileROM, "b.sc8": fileROM}))
+	if !errors.Is(err, chip8.ErrManyROMs) || !strings.Contains(err.Error(), "a.ch8") || !strings.Contains(err.Error(), "b.sc8") {
+		t.Fatalf("expected the names of the ROMs, but got %v", err)
+	}
+}
+
+func TestROMFileFormat(t *testing.T) {
+	if format := (&chip8.ROMFile{}).Format(); format != chip8.FormatBinary {
+		t.Fatalf("expected a file without formats to be binary, but got %s", format)
+	}
+}
+
+func TestLoadROMFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sc8.gz")
+	if err := ioutil.WriteFile(path, gzipFile(t, "", fileROM), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &chip8.Emulator{}
+	f, err := c.LoadROMFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Name != "game.sc8" || c.Machine != chip8.SCHIP {
+		t.Fatalf("expected a SUPER-CHIP ROM, but got %s on %v", f.Name, c.Machine)
+	}
+
+	if !bytes.Equal(c.Memory[chip8.AddrStart:chip8.AddrStart+len(fileROM)], fileROM) {
+		t.Fatal("expected the ROM to be loaded")
+	}
+
+	// the machine chosen by the user is kept
+	c = chip8.NewEmulator(chip8.XOCHIP)
+	if _, err := c.LoadROMFile(path); err != nil || c.Machine != chip8.XOCHIP {
+		t.Fatalf("expected the machine to be kept, but got %v (%v)", c.Machine, err)
+	}
+
+	if _, err := c.LoadROMFile(filepath.Join(t.TempDir(), "missing.ch8")); err == nil {
+		t.Fatal("expected an error on a missing file")
+	}
+}
@@ -0,0 +1,85 @@
+package chip8
+
+// StackUnlimited is the StackDepth of a machine without limits on the
+// number of nested calls, useful for debugging programs that overflow
+// the stack of the real machines.
+const StackUnlimited = -1
+
+// AddrStack is the top of the stack of the COSMAC VIP interpreter,
+// which grows down from 0xECF. It is used when the emulator keeps the
+// stack in memory (see Emulator.MemoryStack).
+const AddrStack = 0xED0
+
+// StackFrame is a subroutine call on the stack.
+type StackFrame struct {
+	Call   uint16 // address of the call instruction
+	Return uint16 // address where the subroutine returns to
+}
+
+// StackFrames returns the calls on the stack, from the outermost to the
+// innermost one.
+func (c *Emulator) StackFrames() []StackFrame {
+	frames := make([]StackFrame, c.SP)
+	for i := range frames {
+		ret := c.stackEntry(i)
+		frames[i] = StackFrame{Call: ret - 2, Return: ret}
+	}
+
+	return frames
+}
+
+// memoryStack reports whether the stack is kept in memory.
+func (c *Emulator) memoryStack() bool {
+	return c.MemoryStack && c.machine().StackAddr > 0
+}
+
+// stackEntry returns the i-th entry of the stack, from the bottom.
+func (c *Emulator) stackEntry(i int) uint16 {
+	if c.memoryStack() {
+		addr := c.machine().StackAddr - 2*(i+1)
+		return uint16(c.Read(addr))<<8 | uint16(c.Read(addr+1))
+	}
+
+	if i >= len(c.Stack) {
+		return 0
+	}
+
+	return c.Stack[i]
+}
+
+// push pushes the address on the stack, returning ErrStackOverflow if
+// the stack is full.
+func (c *Emulator) push(addr uint16) error {
+	m := c.machine()
+	if m.StackDepth > 0 && c.SP >= m.StackDepth {
+		return ErrStackOverflow
+	}
+
+	if c.memoryStack() {
+		top := m.StackAddr - 2*(c.SP+1)
+		if top < 0 {
+			return ErrStackOverflow
+		}
+
+		c.Write(top, byte(addr>>8))
+		c.Write(top+1, byte(addr))
+	} else if c.SP < len(c.Stack) {
+		c.Stack[c.SP] = addr
+	} else {
+		c.Stack = append(c.Stack, addr)
+	}
+
+	c.SP++
+	return nil
+}
+
+// pop pops an address from the stack, returning ErrStackUnderflow if the
+// stack is empty.
+func (c *Emulator) pop() (uint16, error) {
+	if c.SP <= 0 {
+		return 0, ErrStackUnderflow
+	}
+
+	c.SP--
+	return c.stackEntry(c.SP), nil
+}
@@ -0,0 +1,153 @@
+package chip8_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+// recursive calls itself forever.
+var recursive = []byte{0x22, 0x00}
+
+func TestStackDepth(t *testing.T) {
+	unlimited := *chip8.COSMACVIP
+	unlimited.StackDepth = chip8.StackUnlimited
+
+	tests := []struct {
+		name    string
+		machine *chip8.Machine
+		memory  bool
+		calls   int
+	}{
+		{"VIP", chip8.COSMACVIP, false, 12},
+		{"VIPMemory", chip8.COSMACVIP, true, 12},
+		{"SCHIP", chip8.SCHIP, false, 16},
+		{"XOCHIP", chip8.XOCHIP, true, 16},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := chip8.NewEmulator(test.machine)
+			c.MemoryStack = test.memory
+			if err := c.LoadROM(bytes.NewReader(recursive)); err != nil {
+				t.Fatal(err)
+			}
+
+			executed, err := c.Execute(100)
+			if !errors.Is(err, chip8.ErrStackOverflow) || executed != test.calls {
+				t.Fatalf("expected stack overflow after %d calls, but got %d (%v)", test.calls, executed, err)
+			}
+		})
+	}
+
+	t.Run("Unlimited", func(t *testing.T) {
+		c := chip8.NewEmulator(&unlimited)
+		if err := c.LoadROM(bytes.NewReader(recursive)); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := c.Execute(1000); err != nil {
+			t.Fatal(err)
+		}
+
+		if c.SP != 1000 || len(c.StackFrames()) != 1000 {
+			t.Fatalf("expected 1000 calls on the stack, but got %d", c.SP)
+		}
+	})
+}
+
+func TestStackUnderflow(t *testing.T) {
+	c := chip8.NewEmulator(chip8.COSMACVIP)
+	if err := c.LoadROM(bytes.NewReader([]byte{0x00, 0xEE, 0x61, 0x01})); err != nil {
+		t.Fatal(err)
+	}
+
+	executed, err := c.Execute(1)
+	var execErr *chip8.ExecError
+	if !errors.Is(err, chip8.ErrStackUnderflow) || !errors.As(err, &execErr) || executed != 0 {
+		t.Fatalf("expected stack underflow, but got %v", err)
+	}
+
+	if execErr.PC != chip8.AddrStart || execErr.Disassembly != "RET" {
+		t.Fatalf("unexpected error location: %v", execErr)
+	}
+
+	// the underflow is a stack fault of the error policy
+	c.Reset()
+	c.Policy.StackFault = chip8.ActionSkip
+	if err := c.LoadROM(bytes.NewReader([]byte{0x00, 0xEE, 0x61, 0x01})); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Execute(2); err != nil || c.V[1] != 1 {
+		t.Fatalf("expected the underflow to be skipped, but got %v", err)
+	}
+}
+
+func TestStackFrames(t *testing.T) {
+	rom := []byte{
+		0x22, 0x04, // 0x200: CALL 0x204
+		0x00, 0x00, // 0x202
+		0x22, 0x08, // 0x204: CALL 0x208
+		0x00, 0x00, // 0x206
+		0x81, 0x2F, // 0x208: unknown instruction
+	}
+
+	for _, memory := range []bool{false, true} {
+		c := chip8.NewEmulator(chip8.COSMACVIP)
+		c.MemoryStack = memory
+		if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := c.Execute(3)
+		var execErr *chip8.ExecError
+		if !errors.As(err, &execErr) {
+			t.Fatalf("expected an ExecError, but got %v", err)
+		}
+
+		expected := []chip8.StackFrame{{Call: 0x200, Return: 0x202}, {Call: 0x204, Return: 0x206}}
+		frames := c.StackFrames()
+		if len(frames) != len(expected) || frames[0] != expected[0] || frames[1] != expected[1] {
+			t.Fatalf("expected frames %v, but got %v (memory: %v)", expected, frames, memory)
+		}
+
+		stack := execErr.Registers.Stack
+		if execErr.Registers.SP != 2 || len(stack) != 2 || stack[0] != 0x202 || stack[1] != 0x206 {
+			t.Fatalf("unexpected stack on the registers: %v", execErr.Registers)
+		}
+	}
+}
+
+func TestMemoryStack(t *testing.T) {
+	// the subroutine changes its own return address, on the memory
+	rom := []byte{
+		0x22, 0x06, // 0x200: CALL 0x206
+		0x61, 0x01, // 0x202: V1 = 1 (skipped)
+		0x62, 0x02, // 0x204: V2 = 2
+		0x60, 0x04, // 0x206: V0 = 4
+		0xAE, 0xCF, // 0x208: I = 0xECF (low byte of the return address)
+		0xF0, 0x55, // 0x20A: LD [I], V0
+		0x00, 0xEE, // 0x20C: RET
+	}
+
+	c := chip8.NewEmulator(chip8.COSMACVIP)
+	c.MemoryStack = true
+	if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Execute(6); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.V[1] != 0 || c.V[2] != 2 || c.PC != 0x206 {
+		t.Fatalf("expected to return to 0x204, but got V1 = %d, V2 = %d, PC = 0x%03X", c.V[1], c.V[2], c.PC)
+	}
+
+	if c.Memory[chip8.AddrStack-2] != 0x02 || c.Memory[chip8.AddrStack-1] != 0x04 {
+		t.Fatal("expected the return address on the memory")
+	}
+}
@@ -0,0 +1,51 @@
+package chip8
+
+import (
+	"image"
+	"io"
+)
+
+// System is the interface shared by the Emulator and the low level
+// emulators of the original computers (like the COSMAC VIP of the vip
+// package and the DREAM 6800 of the dream package), so frontends can
+// switch between them.
+type System interface {
+	// Reset resets the system to its initial state.
+	Reset()
+
+	// LoadROM resets the system and loads a CHIP-8 program.
+	LoadROM(rom io.Reader) error
+
+	// RunFrame runs the system for one frame of its display (1/60 of a
+	// second on most machines), returning the number of instructions
+	// executed.
+	RunFrame() (int, error)
+
+	// PressKey, ReleaseKey and IsPressed control the keypad (see Key0).
+	PressKey(key int)
+	ReleaseKey(key int)
+	IsPressed(key int) bool
+
+	// Pixel reports whether a pixel of the monochrome display is set.
+	Pixel(x, y int) bool
+
+	// Image renders the display to a new image.
+	Image() *image.RGBA
+
+	// Timers returns the values of the delay and sound timers.
+	Timers() (delay, sound byte)
+
+	// SoundOn reports whether the beeper is on.
+	SoundOn() bool
+}
+
+// Timers returns the values of the delay and sound timers.
+func (c *Emulator) Timers() (delay, sound byte) {
+	return c.DT, c.ST
+}
+
+// SoundOn reports whether the beeper is on, that is, if the sound timer
+// is not zero.
+func (c *Emulator) SoundOn() bool {
+	return c.ST > 0
+}
@@ -0,0 +1,53 @@
+package chip8_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+var _ chip8.System = (*chip8.Emulator)(nil)
+
+func TestTimers(t *testing.T) {
+	c := chip8.NewEmulator(chip8.COSMACVIP)
+	c.DT, c.ST = 10, 0
+
+	if delay, sound := c.Timers(); delay != 10 || sound != 0 || c.SoundOn() {
+		t.Fatalf("unexpected timers %d/%d", delay, sound)
+	}
+
+	c.ST = 3
+	if _, sound := c.Timers(); sound != 3 || !c.SoundOn() {
+		t.Fatal("expected the sound to be on")
+	}
+}
+
+func TestSystemMachines(t *testing.T) {
+	for _, m := range chip8.Machines {
+		t.Run(m.ID, func(t *testing.T) {
+			start := uint16(m.StartAddr) + 4
+			rom := []byte{
+				0x60, 0x05, // V0 = 5
+				0xF0, 0x15, // DT = V0
+				0x61, 0x01, // V1 = 1
+				0x10 | byte(start>>8), byte(start), // JP start + 4
+			}
+
+			var s chip8.System = chip8.NewEmulator(m)
+			if err := s.LoadROM(bytes.NewReader(rom)); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 3; i++ {
+				if executed, err := s.RunFrame(); err != nil || executed == 0 {
+					t.Fatalf("expected frame %d to run, but got %d instructions (%v)", i, executed, err)
+				}
+			}
+
+			if delay, _ := s.Timers(); delay >= 5 {
+				t.Fatalf("expected the delay timer to run, but found %d", delay)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package chip8
+
+import "errors"
+
+// ErrNoTiming is returned when running the emulator by machine time on
+// a machine without a timing model.
+var ErrNoTiming = errors.New("machine has no timing model")
+
+// DefaultSpeed is the number of instructions executed on each frame by
+// RunFrame, on the machines without a timing model.
+const DefaultSpeed = 10
+
+// Timing models the time taken by the instructions of a machine, in
+// machine cycles of the original CPU. It allows running the emulator by
+// machine time (see RunCycles), instead of a fixed number of instructions
+// per frame.
+type Timing struct {
+	CyclesPerSecond int // machine cycles per second
+	FrameCycles     int // machine cycles between two 60 Hz interrupts
+	FrameOverhead   int // machine cycles used, on each frame, by the interrupt routine and the video DMA
+	Fetch           int // machine cycles to fetch and decode each instruction
+
+	// Cost returns the machine cycles taken to execute an instruction,
+	// not including the fetch. If nil, the Cycles of the instruction
+	// are used.
+	Cost func(c *Emulator, a byte, b byte) int
+}
+
+// VIPTiming is the timing of the CHIP-8 interpreter on the COSMAC VIP.
+// The CDP1802 runs at 1.7609 MHz, with 8 clock cycles on each machine
+// cycle; the CDP1861 interrupts the CPU 60 times per second, and takes
+// 1024 machine cycles of each frame to show the 128 lines of the display.
+// The costs of the instructions follow the analysis of the interpreter
+// routines; the ones that depend on the data (like draw) are estimated.
+var VIPTiming = &Timing{
+	CyclesPerSecond: 1760900 / 8,
+	FrameCycles:     1760900 / 8 / 60,
+	FrameOverhead:   1024 + 46,
+	Fetch:           40,
+	Cost:            vipCost,
+}
+
+// vipCost returns the cost of each instruction of the VIP interpreter.
+func vipCost(c *Emulator, a byte, b byte) int {
+	x := a & lsnMask
+
+	switch a & msnMask >> 4 {
+	case 0x0:
+		if a == 0x00 && b == 0xE0 {
+			return 3078
+		}
+
+		return 10
+	case 0x1, 0xA:
+		return 12
+	case 0x2:
+		return 26
+	case 0x3, 0x4:
+		return 10
+	case 0x5, 0x9, 0xE:
+		return 14
+	case 0x6:
+		return 6
+	case 0x7:
+		return 10
+	case 0x8:
+		if b&lsnMask == 0 {
+			return 12
+		}
+
+		return 44
+	case 0xB:
+		return 22
+	case 0xC:
+		return 36
+	case 0xD:
+		return vipDrawCost(c, a, b)
+	default:
+		return vipMiscCost(x, b)
+	}
+}
+
+// vipDrawCost returns the cost of Dxyn, which depends on the size of the
+// sprite and on how it is aligned to the bytes of the video memory.
+func vipDrawCost(c *Emulator, a byte, b byte) int {
+	rows := int(b & lsnMask)
+
+	perRow := 34
+	if c.V[a&lsnMask]%8 != 0 {
+		perRow = 46 // each row of the sprite changes two bytes
+	}
+
+	return 26 + rows*perRow
+}
+
+// vipMiscCost returns the cost of the Fxnn instructions.
+func vipMiscCost(x byte, b byte) int {
+	switch b {
+	case 0x0A:
+		return 38
+	case 0x1E, 0x29:
+		return 16
+	case 0x33:
+		return 84
+	case 0x55, 0x65:
+		return 14 + 14*(int(x)+1)
+	default:
+		return 10
+	}
+}
+
+// RunCycles runs the program for the given number of machine cycles,
+// according to the timing model of the machine, and returns the number
+// of instructions executed. The timers are updated on each 60 Hz
+// interrupt, like Tick would; if the WaitVBlank quirk is set, Dxyn waits
+// for the interrupt before drawing.
+//
+// The elapsed time is counted on Cycles. Since instructions are never
+// interrupted, the last one may end after the requested cycles.
+//
+// Like Execute, it returns the errors of the instructions; halting errors
+// (ErrInputHalt and ErrDelayHalt) just skip to the next interrupt, as the
+// machine would be waiting, and the run continues.
+func (c *Emulator) RunCycles(cycles int) (int, error) {
+	t := c.machine().Timing
+	if t == nil {
+		return 0, ErrNoTiming
+	}
+
+	executed := 0
+	end := c.Cycles + uint64(cycles)
+
+	for c.Cycles < end {
+		if c.frameCycles >= t.FrameCycles {
+			c.interrupt(t)
+			continue
+		}
+
+		a, b := c.Read(int(c.PC)), c.Read(int(c.PC)+1)
+		if a&msnMask == 0xD0 && c.machine().Quirks.WaitVBlank {
+			c.wait(t)
+			c.interrupt(t)
+		}
+
+		cost := t.Fetch + c.cost(t, a, b)
+		err := c.step(c.decoderFor(), executed)
+		c.elapse(cost)
+
+		switch {
+		case errors.Is(err, ErrInputHalt), errors.Is(err, ErrDelayHalt):
+			c.wait(t)
+		case err != nil:
+			return executed, err
+		default:
+			executed++
+		}
+	}
+
+	return executed, nil
+}
+
+// RunFrame runs the program until the next 60 Hz interrupt, and returns
+// the number of instructions executed (see RunCycles). On the machines
+// without a timing model, it executes Speed instructions and updates the
+// timers instead; like in RunCycles, the halting errors just end the
+// frame.
+func (c *Emulator) RunFrame() (int, error) {
+	t := c.machine().Timing
+	if t == nil {
+		return c.runSpeed()
+	}
+
+	if c.frameCycles >= t.FrameCycles {
+		c.interrupt(t)
+	}
+
+	return c.RunCycles(t.FrameCycles - c.frameCycles)
+}
+
+// runSpeed runs one frame of Speed instructions, updating the timers.
+func (c *Emulator) runSpeed() (int, error) {
+	speed := c.Speed
+	if speed <= 0 {
+		speed = DefaultSpeed
+	}
+
+	executed, err := c.Execute(speed)
+	if errors.Is(err, ErrInputHalt) || errors.Is(err, ErrDelayHalt) {
+		err = nil
+	}
+
+	c.Tick()
+	return executed, err
+}
+
+// cost returns the machine cycles taken by the instruction.
+func (c *Emulator) cost(t *Timing, a byte, b byte) int {
+	if t.Cost != nil {
+		return t.Cost(c, a, b)
+	}
+
+	if ins := c.decoderFor().lookup(uint16(a)<<8 | uint16(b)); ins != nil {
+		return ins.Cycles
+	}
+
+	return 0
+}
+
+// elapse advances the machine time.
+func (c *Emulator) elapse(cycles int) {
+	c.Cycles += uint64(cycles)
+	c.frameCycles += cycles
+}
+
+// wait advances the machine time to the next interrupt.
+func (c *Emulator) wait(t *Timing) {
+	if c.frameCycles < t.FrameCycles {
+		c.elapse(t.FrameCycles - c.frameCycles)
+	}
+}
+
+// interrupt starts a new frame, updating the timers.
+func (c *Emulator) interrupt(t *Timing) {
+	c.frameCycles -= t.FrameCycles
+	c.Tick()
+	c.elapse(t.FrameOverhead)
+}
@@ -0,0 +1,164 @@
+package chip8_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+func newTimedEmulator(t *testing.T, m *chip8.Machine, rom []byte) *chip8.Emulator {
+	t.Helper()
+
+	c := chip8.NewEmulator(m)
+	if err := c.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestRunCyclesNoTiming(t *testing.T) {
+	c := chip8.NewEmulator(chip8.SCHIP)
+
+	if _, err := c.RunCycles(1000); !errors.Is(err, chip8.ErrNoTiming) {
+		t.Fatalf("expected ErrNoTiming, but got %v", err)
+	}
+
+}
+
+func TestRunFrameSpeed(t *testing.T) {
+	rom := bytes.Repeat([]byte{0x61, 0x01}, 20) // V1 = 1
+	c := newTimedEmulator(t, chip8.SCHIP, rom)
+	c.DT = 5
+
+	if executed, err := c.RunFrame(); err != nil || executed != chip8.DefaultSpeed || c.DT != 4 {
+		t.Fatalf("expected %d instructions and a tick, but got %d (DT = %d, %v)", chip8.DefaultSpeed, executed, c.DT, err)
+	}
+
+	c.Speed = 3
+	if executed, err := c.RunFrame(); err != nil || executed != 3 || int(c.PC) != chip8.AddrStart+2*(chip8.DefaultSpeed+3) {
+		t.Fatalf("expected 3 instructions, but got %d (PC = 0x%03X, %v)", executed, c.PC, err)
+	}
+
+	// waiting for the delay timer ends the frame
+	c = newTimedEmulator(t, chip8.SCHIP.WithExtensions(chip8.CHIP8E), []byte{0x60, 0x05, 0xF0, 0x4F})
+	if executed, err := c.RunFrame(); err != nil || executed != 1 {
+		t.Fatalf("expected the frame to end waiting for the delay timer, but got %d (%v)", executed, err)
+	}
+}
+
+func TestRunCycles(t *testing.T) {
+	rom := bytes.Repeat([]byte{0x61, 0x01}, 20) // V1 = 1
+	c := newTimedEmulator(t, chip8.COSMACVIP, rom)
+
+	// each instruction takes 40 cycles to fetch and 6 to execute
+	executed, err := c.RunCycles(46 * 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if executed != 10 || c.Cycles != 46*10 {
+		t.Fatalf("expected 10 instructions in 460 cycles, but found %d in %d", executed, c.Cycles)
+	}
+
+	// the last instruction ends after the requested time
+	if executed, _ = c.RunCycles(1); executed != 1 || c.Cycles != 46*11 {
+		t.Fatalf("expected 1 instruction in 46 cycles, but found %d in %d", executed, c.Cycles-46*10)
+	}
+}
+
+func TestVIPCost(t *testing.T) {
+	tests := []struct {
+		name   string
+		opcode []byte
+		vx     byte
+		cost   int
+	}{
+		{"CLS", []byte{0x00, 0xE0}, 0, 3078},
+		{"LD Vx, byte", []byte{0x60, 0x12}, 0, 6},
+		{"LD Vx, Vy", []byte{0x80, 0x10}, 0, 12},
+		{"DRW aligned", []byte{0xD0, 0x15}, 8, 26 + 5*34},
+		{"DRW misaligned", []byte{0xD0, 0x15}, 9, 26 + 5*46},
+	}
+
+	for _, test := range tests {
+		c := chip8.NewEmulator(chip8.COSMACVIP)
+		c.V[0] = test.vx
+
+		if cost := chip8.VIPTiming.Cost(c, test.opcode[0], test.opcode[1]); cost != test.cost {
+			t.Errorf("%s: expected %d cycles, but found %d", test.name, test.cost, cost)
+		}
+	}
+}
+
+func TestRunFrame(t *testing.T) {
+	rom := []byte{
+		0x60, 0x05, // V0 = 5
+		0xF0, 0x15, // DT = V0
+		0x61, 0x01, // V1 = 1
+		0xBB, 0x02, // JB 2 (loop forever)
+	}
+
+	c := newTimedEmulator(t, chip8.COSMACVIP.WithExtensions(chip8.CHIP8E), rom)
+	timing := chip8.VIPTiming
+
+	for frame := 1; frame <= 3; frame++ {
+		if _, err := c.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+
+		if c.Cycles < uint64(frame*timing.FrameCycles) || c.Cycles > uint64(frame*timing.FrameCycles+100) {
+			t.Fatalf("frame %d ended after %d cycles", frame, c.Cycles)
+		}
+	}
+
+	// the timer is updated on the start of the frames 2 and 3
+	if c.DT != 3 {
+		t.Fatalf("expected DT to be 3, but was %d", c.DT)
+	}
+}
+
+func TestRunCyclesWaitVBlank(t *testing.T) {
+	rom := []byte{
+		0xD0, 0x15, // DRW V0, V1, 5
+	}
+
+	c := newTimedEmulator(t, chip8.COSMACVIP, rom)
+	c.I = chip8.AddrSprite
+	timing := chip8.VIPTiming
+
+	executed, err := c.RunCycles(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := uint64(timing.FrameCycles + timing.FrameOverhead + timing.Fetch + 26 + 5*34)
+	if executed != 1 || c.Cycles != expected {
+		t.Fatalf("expected draw to end after %d cycles, but found %d", expected, c.Cycles)
+	}
+
+	if !c.Pixel(0, 0) {
+		t.Fatal("expected sprite to be drawn")
+	}
+}
+
+func TestRunCyclesHalt(t *testing.T) {
+	rom := []byte{
+		0x60, 0x03, // V0 = 3
+		0xF0, 0x4F, // DELAY V0
+		0x61, 0x01, // V1 = 1
+	}
+
+	c := newTimedEmulator(t, chip8.COSMACVIP.WithExtensions(chip8.CHIP8E), rom)
+	timing := chip8.VIPTiming
+
+	if _, err := c.RunCycles(5 * timing.FrameCycles); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.V[1] != 1 {
+		t.Fatal("expected the program to continue after the delay")
+	}
+}
@@ -0,0 +1,298 @@
+package vip
+
+// Bus connects the CDP1802 to the memory and to the I/O devices.
+type Bus interface {
+	// Read and Write access the memory.
+	Read(addr uint16) byte
+	Write(addr uint16, value byte)
+
+	// Input and Output are the INP and OUT instructions, for the ports
+	// 1 to 7.
+	Input(port byte) byte
+	Output(port byte, value byte)
+
+	// Flag reports whether the external flag EFn (1 to 4) is asserted.
+	Flag(n int) bool
+}
+
+// CPU is the RCA CDP1802 (COSMAC) microprocessor. Each machine cycle of
+// the CPU takes 8 clock cycles; most instructions take 2 machine cycles,
+// and the long branches and skips take 3.
+type CPU struct {
+	R  [16]uint16 // scratchpad registers
+	P  byte       // designates the program counter
+	X  byte       // designates the data pointer
+	D  byte       // data register (accumulator)
+	DF byte       // data flag (carry), 0 or 1
+	T  byte       // holds X and P during an interrupt
+	IE bool       // interrupts enabled
+	Q  bool       // output flip-flop
+
+	// Idle is set by the IDL instruction; the CPU does nothing until an
+	// interrupt or DMA request.
+	Idle bool
+
+	Bus Bus
+}
+
+// Reset puts the CPU on its initial state: P, X and R0 are zero, Q is
+// off and interrupts are enabled.
+func (cpu *CPU) Reset() {
+	cpu.P = 0
+	cpu.X = 0
+	cpu.R[0] = 0
+	cpu.Q = false
+	cpu.IE = true
+	cpu.Idle = false
+}
+
+// Interrupt services an interrupt request, if interrupts are enabled,
+// and returns the machine cycles taken.
+func (cpu *CPU) Interrupt() int {
+	if !cpu.IE {
+		return 0
+	}
+
+	cpu.T = cpu.X<<4 | cpu.P
+	cpu.X = 2
+	cpu.P = 1
+	cpu.IE = false
+	cpu.Idle = false
+	return 1
+}
+
+// DMAOut services a DMA output request, returning the byte pointed by R0
+// (which is incremented). It takes one machine cycle.
+func (cpu *CPU) DMAOut() byte {
+	value := cpu.Bus.Read(cpu.R[0])
+	cpu.R[0]++
+	cpu.Idle = false
+	return value
+}
+
+// Step executes one instruction, returning the machine cycles taken.
+// When the CPU is idle, a single machine cycle passes.
+func (cpu *CPU) Step() int {
+	if cpu.Idle {
+		return 1
+	}
+
+	op := cpu.fetch()
+	n := op & 0x0F
+
+	switch op >> 4 {
+	case 0x0:
+		if n == 0 {
+			cpu.Idle = true
+		} else {
+			cpu.D = cpu.Bus.Read(cpu.R[n])
+		}
+	case 0x1:
+		cpu.R[n]++
+	case 0x2:
+		cpu.R[n]--
+	case 0x3:
+		cpu.shortBranch(n)
+	case 0x4:
+		cpu.D = cpu.Bus.Read(cpu.R[n])
+		cpu.R[n]++
+	case 0x5:
+		cpu.Bus.Write(cpu.R[n], cpu.D)
+	case 0x6:
+		cpu.io(n)
+	case 0x7:
+		cpu.control(n)
+	case 0x8:
+		cpu.D = byte(cpu.R[n])
+	case 0x9:
+		cpu.D = byte(cpu.R[n] >> 8)
+	case 0xA:
+		cpu.R[n] = cpu.R[n]&0xFF00 | uint16(cpu.D)
+	case 0xB:
+		cpu.R[n] = cpu.R[n]&0x00FF | uint16(cpu.D)<<8
+	case 0xC:
+		cpu.long(n)
+		return 3
+	case 0xD:
+		cpu.P = n
+	case 0xE:
+		cpu.X = n
+	case 0xF:
+		cpu.alu(n)
+	}
+
+	return 2
+}
+
+// fetch reads the byte pointed by the program counter, advancing it.
+func (cpu *CPU) fetch() byte {
+	value := cpu.Bus.Read(cpu.R[cpu.P])
+	cpu.R[cpu.P]++
+	return value
+}
+
+// condition evaluates the conditions of the branch instructions: 0 is
+// always true, 1 is Q, 2 is D == 0, 3 is DF and 4 to 7 are EF1 to EF4.
+func (cpu *CPU) condition(n byte) bool {
+	switch n & 0x07 {
+	case 0:
+		return true
+	case 1:
+		return cpu.Q
+	case 2:
+		return cpu.D == 0
+	case 3:
+		return cpu.DF != 0
+	default:
+		return cpu.Bus.Flag(int(n&0x07) - 3)
+	}
+}
+
+// shortBranch runs the 3N instructions, that branch inside the page.
+// The conditions of 38 to 3F are the negation of 30 to 37 (38 never
+// branches, skipping the next byte).
+func (cpu *CPU) shortBranch(n byte) {
+	pc := &cpu.R[cpu.P]
+
+	if cpu.condition(n) != (n&0x08 != 0) {
+		*pc = *pc&0xFF00 | uint16(cpu.Bus.Read(*pc))
+	} else {
+		*pc++
+	}
+}
+
+// long runs the CN instructions: long branches (C0 to C3 and C8 to CB)
+// and long skips (C4 to C7 and CC to CF).
+func (cpu *CPU) long(n byte) {
+	pc := &cpu.R[cpu.P]
+
+	if n&0x04 == 0 {
+		if cpu.condition(n) != (n&0x08 != 0) {
+			*pc = uint16(cpu.Bus.Read(*pc))<<8 | uint16(cpu.Bus.Read(*pc+1))
+		} else {
+			*pc += 2
+		}
+
+		return
+	}
+
+	var skip bool
+	switch n {
+	case 0x4: // NOP
+	case 0xC: // LSIE
+		skip = cpu.IE
+	case 0x5, 0x6, 0x7: // LSNQ, LSNZ, LSNF
+		skip = !cpu.condition(n & 0x03)
+	default: // LSQ, LSZ, LSDF
+		skip = cpu.condition(n & 0x03)
+	}
+
+	if skip {
+		*pc += 2
+	}
+}
+
+// io runs the 6N instructions: IRX, OUT and INP.
+func (cpu *CPU) io(n byte) {
+	rx := &cpu.R[cpu.X]
+
+	switch {
+	case n == 0: // IRX
+		*rx++
+	case n < 8: // OUT
+		cpu.Bus.Output(n, cpu.Bus.Read(*rx))
+		*rx++
+	case n > 8: // INP
+		cpu.D = cpu.Bus.Input(n - 8)
+		cpu.Bus.Write(*rx, cpu.D)
+	}
+}
+
+// control runs the 7N instructions.
+func (cpu *CPU) control(n byte) {
+	rx := &cpu.R[cpu.X]
+
+	switch n {
+	case 0x0, 0x1: // RET, DIS
+		value := cpu.Bus.Read(*rx)
+		*rx++
+		cpu.X, cpu.P = value>>4, value&0x0F
+		cpu.IE = n == 0
+	case 0x2: // LDXA
+		cpu.D = cpu.Bus.Read(*rx)
+		*rx++
+	case 0x3: // STXD
+		cpu.Bus.Write(*rx, cpu.D)
+		*rx--
+	case 0x4: // ADC
+		cpu.add(cpu.Bus.Read(*rx), cpu.D, cpu.DF)
+	case 0x5: // SDB
+		cpu.add(cpu.Bus.Read(*rx), ^cpu.D, cpu.DF)
+	case 0x6: // SHRC
+		cpu.D, cpu.DF = cpu.D>>1|cpu.DF<<7, cpu.D&0x01
+	case 0x7: // SMB
+		cpu.add(cpu.D, ^cpu.Bus.Read(*rx), cpu.DF)
+	case 0x8: // SAV
+		cpu.Bus.Write(*rx, cpu.T)
+	case 0x9: // MARK
+		cpu.T = cpu.X<<4 | cpu.P
+		cpu.Bus.Write(cpu.R[2], cpu.T)
+		cpu.X = cpu.P
+		cpu.R[2]--
+	case 0xA, 0xB: // REQ, SEQ
+		cpu.Q = n == 0xB
+	case 0xC: // ADCI
+		cpu.add(cpu.fetch(), cpu.D, cpu.DF)
+	case 0xD: // SDBI
+		cpu.add(cpu.fetch(), ^cpu.D, cpu.DF)
+	case 0xE: // SHLC
+		cpu.D, cpu.DF = cpu.D<<1|cpu.DF, cpu.D>>7
+	case 0xF: // SMBI
+		cpu.add(cpu.D, ^cpu.fetch(), cpu.DF)
+	}
+}
+
+// alu runs the FN instructions. F0 to F7 use the memory pointed by RX;
+// F8 to FF use the byte after the instruction.
+func (cpu *CPU) alu(n byte) {
+	switch n {
+	case 0x6: // SHR
+		cpu.D, cpu.DF = cpu.D>>1, cpu.D&0x01
+		return
+	case 0xE: // SHL
+		cpu.D, cpu.DF = cpu.D<<1, cpu.D>>7
+		return
+	}
+
+	var value byte
+	if n < 8 {
+		value = cpu.Bus.Read(cpu.R[cpu.X])
+	} else {
+		value = cpu.fetch()
+	}
+
+	switch n & 0x07 {
+	case 0x0: // LDX, LDI
+		cpu.D = value
+	case 0x1: // OR, ORI
+		cpu.D |= value
+	case 0x2: // AND, ANI
+		cpu.D &= value
+	case 0x3: // XOR, XRI
+		cpu.D ^= value
+	case 0x4: // ADD, ADI
+		cpu.add(value, cpu.D, 0)
+	case 0x5: // SD, SDI
+		cpu.add(value, ^cpu.D, 1)
+	case 0x7: // SM, SMI
+		cpu.add(cpu.D, ^value, 1)
+	}
+}
+
+// add sets D to a + b + carry, and DF to the carry out. Subtractions are
+// done adding the complement, so DF is 1 when there is no borrow.
+func (cpu *CPU) add(a, b, carry byte) {
+	sum := uint16(a) + uint16(b) + uint16(carry)
+	cpu.D = byte(sum)
+	cpu.DF = byte(sum >> 8)
+}
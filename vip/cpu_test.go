@@ -0,0 +1,149 @@
+package vip_test
+
+import (
+	"testing"
+
+	"github.com/ibraimgm/chip8/vip"
+)
+
+// testBus is a bus with 64K of RAM, recording the output ports.
+type testBus struct {
+	mem   [0x10000]byte
+	out   [8]byte
+	in    byte
+	flags [5]bool
+}
+
+func (b *testBus) Read(addr uint16) byte         { return b.mem[addr] }
+func (b *testBus) Write(addr uint16, value byte) { b.mem[addr] = value }
+func (b *testBus) Input(port byte) byte          { return b.in }
+func (b *testBus) Output(port byte, value byte)  { b.out[port] = value }
+func (b *testBus) Flag(n int) bool               { return b.flags[n] }
+
+func newTestCPU(program ...byte) (*vip.CPU, *testBus) {
+	bus := &testBus{}
+	copy(bus.mem[:], program)
+
+	cpu := &vip.CPU{Bus: bus}
+	cpu.Reset()
+	return cpu, bus
+}
+
+func TestCPU(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []byte
+		steps   int
+		setup   func(cpu *vip.CPU, bus *testBus)
+		check   func(cpu *vip.CPU, bus *testBus) bool
+	}{
+		{"LDI", []byte{0xF8, 0x42}, 1, nil,
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.D == 0x42 && cpu.R[0] == 2 }},
+		{"PLO/PHI", []byte{0xF8, 0x34, 0xA5, 0xF8, 0x12, 0xB5}, 4, nil,
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.R[5] == 0x1234 }},
+		{"GLO/GHI", []byte{0x85, 0x95}, 1, func(cpu *vip.CPU, bus *testBus) { cpu.R[5] = 0x1234 },
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.D == 0x34 }},
+		{"INC/DEC", []byte{0x15, 0x15, 0x26}, 3, nil,
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.R[5] == 2 && cpu.R[6] == 0xFFFF }},
+		{"LDA/STR", []byte{0xF8, 0x10, 0xA5, 0x45, 0x56}, 4, func(cpu *vip.CPU, bus *testBus) { bus.mem[0x10] = 0x99; cpu.R[6] = 0x20 },
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.R[5] == 0x11 && bus.mem[0x20] == 0x99 }},
+		{"ADI carry", []byte{0xF8, 0xF0, 0xFC, 0x20}, 2, nil,
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.D == 0x10 && cpu.DF == 1 }},
+		{"SMI borrow", []byte{0xF8, 0x10, 0xFF, 0x20}, 2, nil,
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.D == 0xF0 && cpu.DF == 0 }},
+		{"SDI no borrow", []byte{0xF8, 0x10, 0xFD, 0x20}, 2, nil,
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.D == 0x10 && cpu.DF == 1 }},
+		{"SHR", []byte{0xF8, 0x03, 0xF6}, 2, nil,
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.D == 0x01 && cpu.DF == 1 }},
+		{"SHLC", []byte{0xF8, 0x81, 0x7E}, 2, func(cpu *vip.CPU, bus *testBus) { cpu.DF = 1 },
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.D == 0x03 && cpu.DF == 1 }},
+		{"XRI", []byte{0xF8, 0xFF, 0xFB, 0x0F}, 2, nil,
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.D == 0xF0 }},
+		{"BR", []byte{0x30, 0x40}, 1, nil,
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.R[0] == 0x40 }},
+		{"BZ taken", []byte{0xF8, 0x00, 0x32, 0x40}, 2, nil,
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.R[0] == 0x40 }},
+		{"BNZ not taken", []byte{0xF8, 0x00, 0x3A, 0x40}, 2, nil,
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.R[0] == 0x04 }},
+		{"B3", []byte{0x36, 0x40}, 1, func(cpu *vip.CPU, bus *testBus) { bus.flags[3] = true },
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.R[0] == 0x40 }},
+		{"LBR", []byte{0xC0, 0x12, 0x34}, 1, nil,
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.R[0] == 0x1234 }},
+		{"LSZ", []byte{0xF8, 0x00, 0xCE}, 2, nil,
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.R[0] == 0x05 }},
+		{"SEP", []byte{0xF8, 0x10, 0xA3, 0xD3}, 3, nil,
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.P == 3 && cpu.R[3] == 0x10 }},
+		{"SEX", []byte{0xE4}, 1, nil,
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.X == 4 }},
+		{"SEQ", []byte{0x7B}, 1, nil,
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.Q }},
+		{"OUT", []byte{0xE5, 0x62}, 2, func(cpu *vip.CPU, bus *testBus) { cpu.R[5] = 0x30; bus.mem[0x30] = 0x0A },
+			func(cpu *vip.CPU, bus *testBus) bool { return bus.out[2] == 0x0A && cpu.R[5] == 0x31 }},
+		{"INP", []byte{0xE5, 0x69}, 2, func(cpu *vip.CPU, bus *testBus) { cpu.R[5] = 0x30; bus.in = 0x77 },
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.D == 0x77 && bus.mem[0x30] == 0x77 }},
+		{"STXD", []byte{0xE5, 0xF8, 0x11, 0x73}, 3, func(cpu *vip.CPU, bus *testBus) { cpu.R[5] = 0x30 },
+			func(cpu *vip.CPU, bus *testBus) bool { return bus.mem[0x30] == 0x11 && cpu.R[5] == 0x2F }},
+		{"MARK", []byte{0x79}, 1, func(cpu *vip.CPU, bus *testBus) { cpu.R[2] = 0x30 },
+			func(cpu *vip.CPU, bus *testBus) bool {
+				return bus.mem[0x30] == 0x00 && cpu.R[2] == 0x2F && cpu.T == 0x00
+			}},
+		{"RET", []byte{0xE5, 0x70}, 2, func(cpu *vip.CPU, bus *testBus) { cpu.R[5] = 0x30; bus.mem[0x30] = 0x23; cpu.IE = false },
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.X == 2 && cpu.P == 3 && cpu.IE }},
+		{"IDL", []byte{0x00, 0xF8, 0x01}, 2, nil,
+			func(cpu *vip.CPU, bus *testBus) bool { return cpu.Idle && cpu.D == 0 }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpu, bus := newTestCPU(test.program...)
+			if test.setup != nil {
+				test.setup(cpu, bus)
+			}
+
+			for i := 0; i < test.steps; i++ {
+				cpu.Step()
+			}
+
+			if !test.check(cpu, bus) {
+				t.Fatalf("unexpected state: %+v", *cpu)
+			}
+		})
+	}
+}
+
+func TestCPUCycles(t *testing.T) {
+	cpu, _ := newTestCPU(0xF8, 0x00, 0xC0, 0x00, 0x10, 0x00)
+
+	if cycles := cpu.Step(); cycles != 2 {
+		t.Fatalf("expected 2 cycles for LDI, but got %d", cycles)
+	}
+
+	if cycles := cpu.Step(); cycles != 3 {
+		t.Fatalf("expected 3 cycles for LBR, but got %d", cycles)
+	}
+
+	cpu.R[0] = 5
+	cpu.Step() // IDL
+
+	if cycles := cpu.Step(); cycles != 1 {
+		t.Fatalf("expected 1 cycle while idle, but got %d", cycles)
+	}
+}
+
+func TestCPUInterrupt(t *testing.T) {
+	cpu, _ := newTestCPU()
+	cpu.X, cpu.P = 2, 3
+
+	if cycles := cpu.Interrupt(); cycles != 1 {
+		t.Fatalf("expected 1 cycle, but got %d", cycles)
+	}
+
+	if cpu.T != 0x23 || cpu.X != 2 || cpu.P != 1 || cpu.IE {
+		t.Fatalf("unexpected state: %+v", *cpu)
+	}
+
+	// disabled interrupts are ignored
+	if cycles := cpu.Interrupt(); cycles != 0 || cpu.P != 1 {
+		t.Fatalf("expected the interrupt to be ignored")
+	}
+}
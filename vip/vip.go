@@ -0,0 +1,298 @@
+// Package vip is a low level emulator of the RCA COSMAC VIP, the computer
+// where CHIP-8 was born. Instead of interpreting CHIP-8 programs, it
+// emulates the CDP1802 CPU, the CDP1861 video chip and the hex keypad,
+// running an image of the original CHIP-8 interpreter supplied by the
+// user. It implements chip8.System, so frontends can use it in place of
+// the chip8.Emulator.
+package vip
+
+import (
+	"errors"
+	"image"
+	"io"
+	"io/ioutil"
+
+	"github.com/ibraimgm/chip8"
+)
+
+// ErrInterpreterSize is returned when the CHIP-8 interpreter image does
+// not fit on the space reserved for it, before the programs.
+var ErrInterpreterSize = errors.New("interpreter image too large")
+
+// Memory layout of the VIP.
+const (
+	RAMSize     = 4096   // size of the RAM, in bytes
+	MonitorAddr = 0x8000 // address of the monitor ROM
+	StartAddr   = 0x200  // address where the CHIP-8 programs are loaded
+)
+
+// Timing of the CDP1861. Each line of the frame takes 14 machine cycles;
+// on the 128 lines of the display, 8 of them are used by the DMA that
+// sends a line of 64 pixels to the screen.
+const (
+	CyclesPerSecond = 1760900 / 8
+	CyclesPerLine   = 14
+	LinesPerFrame   = 262
+	FrameCycles     = CyclesPerLine * LinesPerFrame
+
+	displayStart = 80  // first line of the display
+	displayLines = 128 // lines of the display
+	interrupt    = 78  // line where the interrupt is requested
+	dmaCycle     = 4   // cycle of the line where the DMA is requested
+	bytesPerLine = 8   // bytes sent by the DMA on each line
+)
+
+// Size of the display of the CDP1861, in pixels.
+const (
+	Width  = 64
+	Height = displayLines
+)
+
+// VIP is a COSMAC VIP with the CHIP-8 interpreter loaded.
+//
+// The display has the resolution of the CDP1861, 64x128 pixels; the
+// CHIP-8 interpreter repeats each row of its 64x32 display on 4 lines.
+type VIP struct {
+	CPU     CPU
+	RAM     []byte
+	Palette *chip8.Palette // display colors (nil means chip8.DefaultPalette)
+
+	// Monitor is the monitor ROM. It is optional: without it, Reset
+	// starts the interpreter directly, with the registers set like the
+	// monitor would leave them.
+	Monitor []byte
+
+	// Interpreter is the image of the CHIP-8 interpreter, loaded at
+	// address zero by Reset.
+	Interpreter []byte
+
+	Keys    uint16                     // pressed keys, one bit per key
+	Video   [Height][bytesPerLine]byte // last frame sent to the display
+	Display bool                       // whether the CDP1861 is on
+	key     byte                       // key selected on the keypad latch
+	overlay bool                       // monitor mapped at zero, after reset
+	cycle   int                        // machine cycle of the frame
+	dmaLine int                        // next display line for the DMA
+}
+
+// New creates a VIP running the given CHIP-8 interpreter image.
+func New(interpreter io.Reader) (*VIP, error) {
+	image, err := ioutil.ReadAll(interpreter)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(image) > StartAddr {
+		return nil, ErrInterpreterSize
+	}
+
+	v := &VIP{Interpreter: image}
+	v.CPU.Bus = v
+	v.Reset()
+	return v, nil
+}
+
+// Reset clears the memory, loads the interpreter and resets the CPU.
+func (v *VIP) Reset() {
+	if len(v.RAM) != RAMSize {
+		v.RAM = make([]byte, RAMSize)
+	}
+
+	for i := range v.RAM {
+		v.RAM[i] = 0
+	}
+
+	copy(v.RAM, v.Interpreter)
+
+	v.CPU.Bus = v
+	v.CPU.Reset()
+	v.Video = [Height][bytesPerLine]byte{}
+	v.Display = false
+	v.key = 0
+	v.cycle = 0
+	v.dmaLine = displayStart
+
+	// the monitor leaves the last page of the RAM on R1.1, used by the
+	// interpreter to place its variables and the display
+	v.overlay = v.Monitor != nil
+	if !v.overlay {
+		v.CPU.R[1] = uint16(len(v.RAM)/256-1) << 8
+	}
+}
+
+// LoadROM resets the VIP and loads a CHIP-8 program at StartAddr.
+func (v *VIP) LoadROM(rom io.Reader) error {
+	v.Reset()
+
+	data, err := ioutil.ReadAll(rom)
+	if err != nil {
+		return err
+	}
+
+	if StartAddr+len(data) > len(v.RAM) {
+		return chip8.ErrLoadOverflow
+	}
+
+	copy(v.RAM[StartAddr:], data)
+	return nil
+}
+
+// RunFrame runs the VIP for one frame of the CDP1861 (about 1/60 of a
+// second), returning the number of instructions executed by the CPU.
+func (v *VIP) RunFrame() (int, error) {
+	executed := 0
+
+	for v.cycle < FrameCycles {
+		line := v.cycle / CyclesPerLine
+
+		switch {
+		case v.Display && v.dmaLine < displayStart+displayLines && v.cycle >= v.dmaLine*CyclesPerLine+dmaCycle:
+			v.dma()
+		case v.Display && v.CPU.IE && line >= interrupt && line < displayStart:
+			v.cycle += v.CPU.Interrupt()
+		default:
+			if !v.CPU.Idle {
+				executed++
+			}
+
+			v.cycle += v.CPU.Step()
+		}
+	}
+
+	v.cycle -= FrameCycles
+	v.dmaLine = displayStart
+	return executed, nil
+}
+
+// dma sends one line of the display, from the memory pointed by R0.
+func (v *VIP) dma() {
+	row := &v.Video[v.dmaLine-displayStart]
+
+	for i := range row {
+		row[i] = v.CPU.DMAOut()
+	}
+
+	v.cycle += bytesPerLine
+	v.dmaLine++
+}
+
+// Read reads the memory. After a reset, the monitor ROM (if any) is also
+// mapped at address zero, until the CPU accesses an address above it.
+func (v *VIP) Read(addr uint16) byte {
+	if addr >= MonitorAddr {
+		v.overlay = false
+	}
+
+	if (addr >= MonitorAddr || v.overlay) && len(v.Monitor) > 0 {
+		return v.Monitor[int(addr)%len(v.Monitor)]
+	}
+
+	if addr >= MonitorAddr {
+		return 0xFF
+	}
+
+	return v.RAM[int(addr)%len(v.RAM)]
+}
+
+// Write writes to the RAM; writes to the monitor ROM are ignored.
+func (v *VIP) Write(addr uint16, value byte) {
+	if addr < MonitorAddr {
+		v.RAM[int(addr)%len(v.RAM)] = value
+	}
+}
+
+// Input handles the INP instructions: INP 1 turns the display on.
+func (v *VIP) Input(port byte) byte {
+	if port == 1 {
+		v.Display = true
+	}
+
+	return 0
+}
+
+// Output handles the OUT instructions: OUT 1 turns the display off, and
+// OUT 2 selects the key checked by EF3.
+func (v *VIP) Output(port byte, value byte) {
+	switch port {
+	case 1:
+		v.Display = false
+	case 2:
+		v.key = value & 0x0F
+	}
+}
+
+// Flag returns the external flags: EF1 is the display status of the
+// CDP1861, asserted on the 4 lines before the start and the end of the
+// display, and EF3 is the key selected on the keypad.
+func (v *VIP) Flag(n int) bool {
+	switch n {
+	case 1:
+		line := v.cycle / CyclesPerLine
+		start, end := displayStart, displayStart+displayLines
+
+		return v.Display && (line >= start-4 && line < start || line >= end-4 && line < end)
+	case 3:
+		return v.IsPressed(int(v.key))
+	default:
+		return false
+	}
+}
+
+// PressKey presses a key of the hex keypad.
+func (v *VIP) PressKey(key int) {
+	v.Keys |= 1 << uint(key&0x0F)
+}
+
+// ReleaseKey releases a key of the hex keypad.
+func (v *VIP) ReleaseKey(key int) {
+	v.Keys &^= 1 << uint(key&0x0F)
+}
+
+// IsPressed reports whether a key of the hex keypad is pressed.
+func (v *VIP) IsPressed(key int) bool {
+	return v.Keys&(1<<uint(key&0x0F)) != 0
+}
+
+// Pixel reports whether the pixel at the given position was on, on the
+// last frame. Positions outside of the display are never set.
+func (v *VIP) Pixel(x, y int) bool {
+	if x < 0 || y < 0 || x >= Width || y >= Height {
+		return false
+	}
+
+	return v.Video[y][x/8]&(0x80>>uint(x%8)) != 0
+}
+
+// Image renders the last frame to a new 64x128 image.
+func (v *VIP) Image() *image.RGBA {
+	palette := v.Palette
+	if palette == nil {
+		palette = chip8.DefaultPalette
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+
+	for y := 0; y < Height; y++ {
+		for x := 0; x < Width; x++ {
+			if v.Pixel(x, y) {
+				img.SetRGBA(x, y, palette.Foreground)
+			} else {
+				img.SetRGBA(x, y, palette.Background)
+			}
+		}
+	}
+
+	return img
+}
+
+// Timers returns the delay and sound timers of the CHIP-8 interpreter,
+// kept on R8.1 and R8.0, and updated by its interrupt routine.
+func (v *VIP) Timers() (delay, sound byte) {
+	return byte(v.CPU.R[8] >> 8), byte(v.CPU.R[8])
+}
+
+// SoundOn reports whether the beeper, driven by the Q output of the CPU,
+// is on.
+func (v *VIP) SoundOn() bool {
+	return v.CPU.Q
+}
@@ -0,0 +1,176 @@
+package vip_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+	"github.com/ibraimgm/chip8/vip"
+)
+
+var _ chip8.System = (*vip.VIP)(nil)
+
+// displayProgram turns the display on and shows the memory at 0x0C00,
+// using an interrupt routine like the one of the VIP monitor.
+var displayProgram = []byte{
+	0xF8, 0x40, // 00: LDI 40
+	0xA1,       // 02: PLO R1
+	0xF8, 0x00, // 03: LDI 00
+	0xB1,       // 05: PHI R1 (R1 = interrupt routine)
+	0xB3,       // 06: PHI R3
+	0xF8, 0x0B, // 07: LDI 0B
+	0xB2,       // 09: PHI R2
+	0xF8, 0xFF, // 0A: LDI FF
+	0xA2,       // 0C: PLO R2 (R2 = stack)
+	0xF8, 0x11, // 0D: LDI 11
+	0xA3,       // 0F: PLO R3
+	0xD3,       // 10: SEP R3
+	0xE2,       // 11: SEX R2
+	0x69,       // 12: INP 1 (display on)
+	0x30, 0x13, // 13: BR 13
+}
+
+// interruptRoutine is placed at 0x3E; its entry point is 0x40.
+var interruptRoutine = []byte{
+	0x72,       // 3E: LDXA
+	0x70,       // 3F: RET
+	0x22,       // 40: DEC R2
+	0x78,       // 41: SAV
+	0x22,       // 42: DEC R2
+	0x52,       // 43: STR R2
+	0xF8, 0x0C, // 44: LDI 0C
+	0xB0,       // 46: PHI R0
+	0xF8, 0x00, // 47: LDI 00
+	0xA0,       // 49: PLO R0
+	0xC4, 0xC4, // 4A: NOP, NOP (wait for the display)
+	0x30, 0x3E, // 4C: BR 3E
+}
+
+func newVIP(t *testing.T, program ...[]byte) *vip.VIP {
+	t.Helper()
+
+	image := make([]byte, 0x50)
+	copy(image, program[0])
+	if len(program) > 1 {
+		copy(image[0x3E:], program[1])
+	}
+
+	v, err := vip.New(bytes.NewReader(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
+
+func TestNew(t *testing.T) {
+	if _, err := vip.New(bytes.NewReader(make([]byte, 0x201))); !errors.Is(err, vip.ErrInterpreterSize) {
+		t.Fatalf("expected ErrInterpreterSize, but got %v", err)
+	}
+
+	v := newVIP(t, displayProgram)
+	if v.RAM[0] != 0xF8 || v.CPU.R[1] != 0x0F00 || !v.CPU.IE {
+		t.Fatalf("unexpected initial state: %+v", v.CPU)
+	}
+}
+
+func TestLoadROM(t *testing.T) {
+	v := newVIP(t, displayProgram)
+
+	if err := v.LoadROM(bytes.NewReader([]byte{0x12, 0x34})); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.RAM[vip.StartAddr] != 0x12 || v.RAM[vip.StartAddr+1] != 0x34 || v.RAM[0] != 0xF8 {
+		t.Fatal("expected the program after the interpreter")
+	}
+
+	if err := v.LoadROM(bytes.NewReader(make([]byte, vip.RAMSize))); !errors.Is(err, chip8.ErrLoadOverflow) {
+		t.Fatalf("expected ErrLoadOverflow, but got %v", err)
+	}
+}
+
+func TestDisplay(t *testing.T) {
+	v := newVIP(t, displayProgram, interruptRoutine)
+	for i := 0; i < 128*8; i++ {
+		v.RAM[0x0C00+i] = byte(i)
+	}
+
+	if _, err := v.RunFrame(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !v.Display {
+		t.Fatal("expected the display to be on")
+	}
+
+	for y := 0; y < vip.Height; y++ {
+		for x := 0; x < vip.Width; x++ {
+			expected := byte(y*8+x/8)&(0x80>>uint(x%8)) != 0
+			if v.Pixel(x, y) != expected {
+				t.Fatalf("pixel (%d,%d): expected %v", x, y, expected)
+			}
+		}
+	}
+
+	img := v.Image()
+	if img.Bounds().Dx() != vip.Width || img.Bounds().Dy() != vip.Height {
+		t.Fatalf("unexpected image size: %v", img.Bounds())
+	}
+
+	// the DMA is done once per frame
+	if _, err := v.RunFrame(); err != nil || v.CPU.R[0] != 0x1000 {
+		t.Fatalf("expected R0 after the last line, but got %04X (%v)", v.CPU.R[0], err)
+	}
+}
+
+func TestDisplayOff(t *testing.T) {
+	program := append([]byte{}, displayProgram...)
+	program[0x12] = 0xC4 // NOP instead of INP 1
+
+	v := newVIP(t, program, interruptRoutine)
+	v.RAM[0x0C00] = 0xFF
+
+	if _, err := v.RunFrame(); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Display || v.Pixel(0, 0) || v.CPU.R[0] != 0x11 {
+		t.Fatal("expected no interrupts or DMA with the display off")
+	}
+}
+
+func TestKeypad(t *testing.T) {
+	v := newVIP(t, []byte{
+		0x62, 0x05, // 00: OUT 2 (key 5)
+		0x36, 0x06, // 02: B3 06
+		0x30, 0x02, // 04: BR 02
+		0x7B,       // 06: SEQ
+		0x30, 0x07, // 07: BR 07
+	})
+
+	v.PressKey(chip8.Key4)
+	if _, err := v.RunFrame(); err != nil || v.SoundOn() {
+		t.Fatalf("expected the key to be ignored (%v)", err)
+	}
+
+	v.ReleaseKey(chip8.Key4)
+	v.PressKey(chip8.Key5)
+	if !v.IsPressed(chip8.Key5) || v.IsPressed(chip8.Key4) {
+		t.Fatal("unexpected keys")
+	}
+
+	if _, err := v.RunFrame(); err != nil || !v.SoundOn() {
+		t.Fatalf("expected the key to set Q (%v)", err)
+	}
+}
+
+func TestTimers(t *testing.T) {
+	v := newVIP(t, displayProgram)
+	v.CPU.R[8] = 0x1234
+
+	if delay, sound := v.Timers(); delay != 0x12 || sound != 0x34 {
+		t.Fatalf("expected timers 12/34, but got %02X/%02X", delay, sound)
+	}
+}
@@ -0,0 +1,42 @@
+package chip8
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// size of the canonical WAV header
+const wavHeaderSize = 44
+
+// WriteWAV writes the PCM samples produced by the audio generators
+// (see BytesPerSample) as a WAV file, at the given sample rate.
+func WriteWAV(w io.Writer, sampleRate int, pcm []byte) error {
+	const bitsPerSample = BytesPerSample * 8
+	const channels = 1
+
+	header := make([]byte, wavHeaderSize)
+	le := binary.LittleEndian
+
+	copy(header[0:], "RIFF")
+	le.PutUint32(header[4:], uint32(wavHeaderSize-8+len(pcm)))
+	copy(header[8:], "WAVE")
+
+	copy(header[12:], "fmt ")
+	le.PutUint32(header[16:], 16) // size of the fmt chunk
+	le.PutUint16(header[20:], 1)  // PCM format
+	le.PutUint16(header[22:], channels)
+	le.PutUint32(header[24:], uint32(sampleRate))
+	le.PutUint32(header[28:], uint32(sampleRate*channels*BytesPerSample))
+	le.PutUint16(header[32:], channels*BytesPerSample)
+	le.PutUint16(header[34:], bitsPerSample)
+
+	copy(header[36:], "data")
+	le.PutUint32(header[40:], uint32(len(pcm)))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	_, err := w.Write(pcm)
+	return err
+}
@@ -0,0 +1,72 @@
+package chip8_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/ibraimgm/chip8"
+)
+
+func TestWriteWAV(t *testing.T) {
+	pcm := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+
+	var buffer bytes.Buffer
+	if err := chip8.WriteWAV(&buffer, 22050, pcm); err != nil {
+		t.Fatal(err)
+	}
+
+	wav := buffer.Bytes()
+	if len(wav) != 44+len(pcm) {
+		t.Fatalf("expected WAV file to have %d bytes, but it has %d", 44+len(pcm), len(wav))
+	}
+
+	tags := []struct {
+		offset int
+		tag    string
+	}{
+		{offset: 0, tag: "RIFF"},
+		{offset: 8, tag: "WAVE"},
+		{offset: 12, tag: "fmt "},
+		{offset: 36, tag: "data"},
+	}
+
+	for _, tag := range tags {
+		if actual := string(wav[tag.offset : tag.offset+4]); actual != tag.tag {
+			t.Fatalf("expected tag '%s' at offset %d, but found '%s'", tag.tag, tag.offset, actual)
+		}
+	}
+
+	fields := []struct {
+		name     string
+		offset   int
+		size     int
+		expected uint32
+	}{
+		{name: "RIFF size", offset: 4, size: 4, expected: uint32(36 + len(pcm))},
+		{name: "format", offset: 20, size: 2, expected: 1},
+		{name: "channels", offset: 22, size: 2, expected: 1},
+		{name: "sample rate", offset: 24, size: 4, expected: 22050},
+		{name: "byte rate", offset: 28, size: 4, expected: 44100},
+		{name: "block align", offset: 32, size: 2, expected: 2},
+		{name: "bits per sample", offset: 34, size: 2, expected: 16},
+		{name: "data size", offset: 40, size: 4, expected: uint32(len(pcm))},
+	}
+
+	for _, field := range fields {
+		var actual uint32
+		if field.size == 2 {
+			actual = uint32(binary.LittleEndian.Uint16(wav[field.offset:]))
+		} else {
+			actual = binary.LittleEndian.Uint32(wav[field.offset:])
+		}
+
+		if actual != field.expected {
+			t.Fatalf("expected %s to be %d, but was %d", field.name, field.expected, actual)
+		}
+	}
+
+	if !bytes.Equal(wav[44:], pcm) {
+		t.Fatalf("expected PCM data to be %v, but was %v", pcm, wav[44:])
+	}
+}